@@ -0,0 +1,170 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// passwordResetTTL is the lifetime of a password reset token.
+	passwordResetTTL = time.Hour
+	// emailVerificationTTL is the lifetime of an email verification token.
+	emailVerificationTTL = 24 * time.Hour
+)
+
+// forgotPasswordHandler sends a password reset token to the given email address.
+// It always succeeds so that it can't be used to find out which emails are registered.
+func (a *App) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var fields internal.EmailInputs
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	if err := fields.Validate(); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Please ensure that all mandatory fields have been filled out.", nil, err)
+		return
+	}
+
+	user, err := a.requestDB(r).GetUserByEmail(fields.Email)
+	if err == nil {
+		if err := a.sendPasswordResetEmail(user); err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Int("user_id", user.ID).Msg("Failed to send password reset email")
+		}
+	}
+
+	sendJSONResponse(w, http.StatusOK, "If the email is registered, a password reset link has been sent", nil, nil)
+}
+
+// resetPasswordHandler sets a new password using a password reset token.
+func (a *App) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var fields internal.PasswordResetInputs
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	if err := fields.Validate(); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Please ensure that all mandatory fields have been filled out.", nil, err)
+		return
+	}
+
+	user, err := a.VerifyActionToken(fields.Token, models.PasswordResetPurpose)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Failed to reset password", nil, err)
+		return
+	}
+
+	setAuditActor(r, user.ID)
+
+	hashedPassword, err := internal.HashPassword(fields.Password)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Error hashing password:", nil, err)
+		return
+	}
+
+	if err := a.requestDB(r).UpdateUserPassword(user.ID, hashedPassword); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to reset password", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "Password reset successfully", nil, nil)
+}
+
+// verifyEmailHandler marks the user email as verified using an email verification token.
+func (a *App) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var fields internal.TokenInputs
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	if err := fields.Validate(); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Please ensure that all mandatory fields have been filled out.", nil, err)
+		return
+	}
+
+	user, err := a.VerifyActionToken(fields.Token, models.EmailVerificationPurpose)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Failed to verify email", nil, err)
+		return
+	}
+
+	setAuditActor(r, user.ID)
+
+	if err := a.requestDB(r).SetUserEmailVerified(user.ID); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to verify email", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "Email verified successfully", nil, nil)
+}
+
+// resendVerificationHandler sends a new email verification token to an unverified user.
+// Like forgotPasswordHandler, it doesn't reveal whether the email is registered.
+func (a *App) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	var fields internal.EmailInputs
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	if err := fields.Validate(); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Please ensure that all mandatory fields have been filled out.", nil, err)
+		return
+	}
+
+	user, err := a.requestDB(r).GetUserByEmail(fields.Email)
+	if err == nil && !user.EmailVerified {
+		if err := a.sendVerificationEmail(user); err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Int("user_id", user.ID).Msg("Failed to send verification email")
+		}
+	}
+
+	sendJSONResponse(w, http.StatusOK, "If the email is registered and not verified, a verification link has been sent", nil, nil)
+}
+
+// sendPasswordResetEmail generates a password reset token and mails it to the user.
+func (a *App) sendPasswordResetEmail(user models.User) error {
+	token, err := a.GenerateActionToken(user, models.PasswordResetPurpose, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nUse the following token to reset your envserver password, it expires in %s.\n\n%s\n",
+		user.FirstName, passwordResetTTL, a.actionLink("reset-password", token),
+	)
+	return a.Mailer.Send(user.Email, "Reset your envserver password", body)
+}
+
+// sendVerificationEmail generates an email verification token and mails it to the user.
+func (a *App) sendVerificationEmail(user models.User) error {
+	token, err := a.GenerateActionToken(user, models.EmailVerificationPurpose, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nUse the following token to verify your email address, it expires in %s.\n\n%s\n",
+		user.FirstName, emailVerificationTTL, a.actionLink("verify-email", token),
+	)
+	return a.Mailer.Send(user.Email, "Verify your envserver email address", body)
+}
+
+// actionLink returns a dashboard link carrying the token if a base url is configured, otherwise the token itself.
+func (a *App) actionLink(path, token string) string {
+	baseURL := strings.TrimRight(a.Config.Mail.BaseURL, "/")
+	if baseURL == "" {
+		return token
+	}
+	return fmt.Sprintf("%s/%s?token=%s", baseURL, path, url.QueryEscape(token))
+}
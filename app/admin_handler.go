@@ -0,0 +1,101 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// suspendUserHandler handles the HTTP request for suspending a user, suspended users can't sign in and their tokens are rejected.
+func (a *App) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.setUserSuspended(w, r, true)
+}
+
+// unsuspendUserHandler handles the HTTP request for reinstating a suspended user.
+func (a *App) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.setUserSuspended(w, r, false)
+}
+
+func (a *App) setUserSuspended(w http.ResponseWriter, r *http.Request, suspended bool) {
+	user, ok := a.getPathUser(w, r)
+	if !ok {
+		return
+	}
+
+	admin, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return
+	}
+
+	if suspended && admin.ID == user.ID {
+		sendJSONResponse(w, http.StatusBadRequest, "Administrators cannot suspend themselves", nil, nil)
+		return
+	}
+
+	if err := a.requestDB(r).SetUserSuspended(user.ID, suspended); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to update user", nil, err)
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Bool("suspended", suspended).Int("user_id", user.ID).Int("admin_id", admin.ID).Msg("User suspension updated by administrator")
+	user.Suspended = suspended
+	sendJSONResponse(w, http.StatusOK, "User updated successfully", user, nil)
+}
+
+// unlockUserHandler handles the HTTP request for unlocking a user account locked after failed signins.
+func (a *App) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.getPathUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.requestDB(r).ResetFailedSignins(user.ID); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to unlock user", nil, err)
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("event", "account_unlocked").Int("user_id", user.ID).Msg("Account unlocked by administrator")
+	sendJSONResponse(w, http.StatusOK, "User unlocked successfully", nil, nil)
+}
+
+// getConfigHandler returns the effective config, with the secrets masked.
+func (a *App) getConfigHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, http.StatusOK, "Config found", internal.MaskedConfig(a.currentConfig()), nil)
+}
+
+// getPathUser loads the user whose ID is the id path parameter, it sends an error response and returns false if it fails.
+func (a *App) getPathUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	userIDStr := mux.Vars(r)["id"]
+	convertedUserId, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Cannot convert user id to number.", nil, err)
+		return models.User{}, false
+	}
+
+	user, err := a.requestDB(r).GetUserByID(int(convertedUserId))
+	if err != nil {
+		sendJSONResponse(w, http.StatusNotFound, "User not found", nil, err)
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// requireAdmin sends a forbidden response and returns false if the requested user isn't an administrator.
+func (a *App) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return false
+	}
+
+	if !user.IsAdmin {
+		sendJSONResponse(w, http.StatusForbidden, "Forbidden", nil, internal.AdminRequiredError)
+		return false
+	}
+	return true
+}
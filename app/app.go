@@ -3,42 +3,92 @@ package app
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	internal "github.com/Mahmoud-Emad/envserver/internal"
 	"github.com/gorilla/mux"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // App for all dependencies of backend server
 type App struct {
+	// Config the app started with, the reloadable keys may have changed since, see currentConfig.
 	Config internal.Config
 	Server Server
 	DB     internal.Database
+	Mailer internal.Mailer
+	Keys   *internal.KeySet
+	// Streams the recorded audit events to the configured sinks.
+	AuditStreamer *internal.AuditStreamer
+	// Publishes the env changes to the live streams.
+	Changes *internal.ChangeBroker
+	// Sends the queued webhook deliveries.
+	WebhookDispatcher *internal.WebhookDispatcher
+	// Tracks failed signins by client IP, failed signins by account are stored on the user.
+	SigninThrottler *internal.LoginThrottler
+	// Reverse proxies whose X-Forwarded-For header holds the client address.
+	TrustedProxies internal.TrustedProxies
+	// Prometheus metrics of the requests and the database queries.
+	Metrics *internal.Metrics
+	// Flushes and stops the span exporter, nil if tracing isn't set up.
+	shutdownTracing func(context.Context) error
+	// Limits the requests of every client, unless rate limiting is disabled in the config.
+	RateLimiter *internal.RateLimiter
+	// Set when the server starts shutting down, so that the readiness probe fails before it stops accepting requests.
+	shuttingDown atomic.Bool
+	// Set once the readiness probe found no pending migrations.
+	migrated atomic.Bool
+	// Sources of the config, read again when it's reloaded.
+	configSources internal.ConfigSources
+	// Effective config after a reload, nil until the first one.
+	config atomic.Pointer[internal.Config]
+	// Serializes the reloads.
+	reloadMu sync.Mutex
 }
 
-func initZerolog() {
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+// NewApp creates a new App instance using the provided Config file, overridden by the ENVSERVER_ environment variables.
+func NewApp(configFileName string) (*App, error) {
+	return NewAppFromSources(internal.ConfigSources{File: configFileName, Environ: os.Environ()})
 }
 
-// NewApp creates a new App instance using the provided Config file.
-func NewApp(configFileName string) (*App, error) {
+// NewAppFromSources creates a new App instance using the config layered from its sources.
+func NewAppFromSources(sources internal.ConfigSources) (*App, error) {
 	initZerolog()
 
-	log.Info().Msg("Loading config file.")
-	config, err := internal.ReadConfigFromFile(configFileName)
+	log.Info().Str("file", sources.File).Msg("Loading config.")
+	config, err := internal.LoadConfig(sources)
 	if err != nil {
 		log.Error().Msg(err.Error())
 		return nil, err
 	}
-	log.Info().Msg("Config file loaded.")
+	configureLogger(config.Log)
+	log.Info().Msg("Config loaded.")
 
 	server := NewServer(config.Server.Host, config.Server.Port)
+	mailer, err := internal.NewMailer(config.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := internal.LoadKeySet(config.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	auditStreamer, err := internal.NewAuditStreamer(config.Audit)
+	if err != nil {
+		return nil, err
+	}
+
 	db := internal.NewDatabase()
 	err = db.Connect(config.Database)
 
@@ -51,64 +101,266 @@ func NewApp(configFileName string) (*App, error) {
 		return nil, err
 	}
 
+	metrics := internal.NewMetrics()
+	if err := db.Instrument(metrics); err != nil {
+		return nil, err
+	}
+
+	if err := db.Trace(); err != nil {
+		return nil, err
+	}
+	shutdownTracing, err := internal.SetupTracing(context.Background(), config.Tracing)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapAdmins(&db, config.Server)
+
+	// The config is validated, the proxies can't be invalid.
+	trustedProxies, _ := internal.ParseTrustedProxies(config.Server.TrustedProxies)
+
 	return &App{
 		Server: *server,
 		Config: config,
 		DB:     db,
+		Mailer: mailer,
+		Keys:   keys,
+
+		SigninThrottler: internal.NewIPSigninThrottler(config.Server),
+		TrustedProxies:  trustedProxies,
+		AuditStreamer:   auditStreamer,
+		Changes:         internal.NewChangeBroker(internal.DefaultChangeHistorySize),
+
+		WebhookDispatcher: internal.NewWebhookDispatcher(db, config.Webhooks, config.Server.EncryptionKey, config.Server.JWTSecretKey),
+		Metrics:           metrics,
+		RateLimiter:       internal.NewRateLimiter(config.RateLimit, internal.NewMemoryRateLimitStore()),
+		shutdownTracing:   shutdownTracing,
+		configSources:     sources,
 	}, nil
 }
 
-// Start starts the server and listens for incoming requests.
-func (a *App) Start() {
+// Start starts the server and listens for incoming requests until an interrupt or a SIGTERM signal.
+// On shutdown the readiness probe fails first, then the in-flight requests are drained for up to the shutdown timeout
+// before the background workers and the database pool are closed. It returns an error if a server fails to start or stops unexpectedly.
+func (a *App) Start() error {
 	// Create a channel to listen for OS signals
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	handler := a.registerHandlers()
+	tlsConfig, err := internal.NewTLSConfig(a.Config.Server.TLS)
+	if err != nil {
+		return fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+
+	// Listen on every address before serving, so that a port in use fails the start.
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", a.Server.Host, a.Server.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen for the API: %w", err)
+	}
+	var grpcListener net.Listener
+	if a.Config.Server.GRPCPort != 0 {
+		grpcListener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", a.Server.Host, a.Config.Server.GRPCPort))
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen for the gRPC API: %w", err)
+		}
+	}
+	var metricsListener net.Listener
+	if a.Config.Metrics.Address != "" {
+		metricsListener, err = net.Listen("tcp", a.Config.Metrics.Address)
+		if err != nil {
+			listener.Close()
+			if grpcListener != nil {
+				grpcListener.Close()
+			}
+			return fmt.Errorf("failed to listen for the metrics: %w", err)
+		}
+	}
+
+	a.WebhookDispatcher.Start()
+	serveErrors := make(chan error, 3)
+
+	// Reload the config on SIGHUP and when its file changes.
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	go a.watchConfig(stopWatching)
 
-	a.registerHandlers()
+	// Serve the gRPC API on its own port, through the same handlers as the REST API.
+	var grpcServer *grpc.Server
+	if grpcListener != nil {
+		var opts []grpc.ServerOption
+		if tlsConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		grpcServer = NewGRPCServer(handler, opts...)
+		go func() {
+			log.Info().Msgf("gRPC API is listening on %s", grpcListener.Addr())
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				serveErrors <- fmt.Errorf("gRPC server failed: %w", err)
+			}
+		}()
+	}
+
+	// Serve the metrics on their own address, so that they can stay private.
+	var metricsServer *http.Server
+	if metricsListener != nil {
+		metricsRouter := http.NewServeMux()
+		metricsRouter.Handle("/metrics", a.Metrics.Handler())
+		metricsServer = &http.Server{Handler: metricsRouter, ReadHeaderTimeout: a.Config.Server.ReadHeaderTimeoutOrDefault()}
+		go func() {
+			log.Info().Msgf("Metrics are served on http://%s/metrics", metricsListener.Addr())
+			if err := metricsServer.Serve(metricsListener); err != nil && err != http.ErrServerClosed {
+				serveErrors <- fmt.Errorf("metrics server failed: %w", err)
+			}
+		}()
+	}
 
-	// Create a new server
+	// Create a new server, TLS is terminated by the server if a certificate is configured.
 	server := &http.Server{
-		Addr: string(rune(a.Server.Port)),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: a.Config.Server.ReadHeaderTimeoutOrDefault(),
+		ReadTimeout:       a.Config.Server.ReadTimeoutOrDefault(),
+		WriteTimeout:      a.Config.Server.WriteTimeoutOrDefault(),
+		IdleTimeout:       a.Config.Server.IdleTimeoutOrDefault(),
 	}
-	// Start the server in a goroutine
+	// The live env streams never end on their own, end them on shutdown so that the clients reconnect to another replica.
+	server.RegisterOnShutdown(a.Changes.Close)
 	go func() {
-		log.Info().Msgf("Server is listening on http://%s:%d", a.Server.Host, a.Server.Port)
-		if err := http.ListenAndServe(fmt.Sprintf("%s:%d", a.Server.Host, a.Server.Port), nil); err != nil && err != http.ErrServerClosed {
-			log.Error().Msgf("Server failed to start: %v", err)
+		var err error
+		if tlsConfig != nil {
+			log.Info().Msgf("Server is listening on https://%s", listener.Addr())
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			log.Info().Msgf("Server is listening on http://%s", listener.Addr())
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serveErrors <- fmt.Errorf("server failed: %w", err)
 		}
-		log.Fatal().Msg("Stopped serving new connections")
 	}()
 
-	// Wait for the shutdown signal
-	<-stop
+	// Wait for the shutdown signal, or for a server to fail
+	var serveErr error
+	select {
+	case sig := <-stop:
+		log.Info().Msgf("Received %s, shutting down, the readiness probe is failing", sig)
+	case serveErr = <-serveErrors:
+		log.Error().Err(serveErr).Msg("Shutting down")
+	}
+	a.shuttingDown.Store(true)
+
+	// Keep serving while the load balancers see the readiness probe failing and stop routing requests here.
+	if serveErr == nil {
+		time.Sleep(a.Config.Server.ShutdownDelayOrDefault())
+	}
 
 	// Create a context with a timeout for graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.Config.Server.ShutdownTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), a.Config.Server.ShutdownTimeoutOrDefault())
 	defer cancel()
 
-	// Shutdown the server
+	// Stop accepting requests and wait for the in-flight ones
 	if err := server.Shutdown(ctx); err != nil {
 		log.Error().Msgf("Server shutdown error: %v", err)
+		server.Close()
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Error().Msgf("Metrics server shutdown error: %v", err)
+		}
+	}
+	if grpcServer != nil {
+		// WatchEnv streams never end on their own, cancel them if they outlive the timeout.
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcServer.Stop()
+		}
+	}
+	// Wait for the in-flight webhook deliveries and flush the queued audit events.
+	a.WebhookDispatcher.Stop()
+	a.AuditStreamer.Close()
+	if a.shutdownTracing != nil {
+		if err := a.shutdownTracing(ctx); err != nil {
+			log.Error().Msgf("Tracing shutdown error: %v", err)
+		}
+	}
+	if err := a.DB.Close(); err != nil {
+		log.Error().Msgf("Database close error: %v", err)
+	}
+	if serveErr != nil {
+		return serveErr
 	}
 	log.Info().Msgf("Server gracefully stopped")
+	return nil
 }
 
-func (a *App) registerHandlers() {
+// Handler returns the router serving the API, e.g. to run it behind an httptest server.
+func (a *App) Handler() http.Handler {
+	return a.registerHandlers()
+}
+
+func (a *App) registerHandlers() http.Handler {
 	r := mux.NewRouter()
+	r.Use(a.resolveClientIP, a.logRequests, a.handleCORS, a.limitRequests, a.limitBodySize, answerOptions)
+
+	// Health probes of the orchestrator, they're neither authenticated nor audited.
+	r.HandleFunc("/healthz", a.livenessHandler).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/readyz", a.readinessHandler).Methods(http.MethodGet, http.MethodOptions)
+
+	// Public keys used by other services to verify the tokens issued by envserver.
+	r.HandleFunc("/.well-known/jwks.json", a.jwksHandler).Methods(http.MethodGet, http.MethodOptions)
 
 	apiRouter := r.PathPrefix("/api/v1").Subrouter()
+
+	// OpenAPI document of the routes, every route must be documented in apiOperations.
+	apiRouter.HandleFunc("/openapi.json", openAPIHandler(r)).Methods(http.MethodGet, http.MethodOptions)
+
 	userRouter := apiRouter.PathPrefix("/users").Subrouter()
+	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	auditRouter := apiRouter.PathPrefix("/audit").Subrouter()
 	authRouter := apiRouter.PathPrefix("/auth").Subrouter()
 	projectRouter := apiRouter.PathPrefix("/projects").Subrouter()
 	envRouter := apiRouter.PathPrefix("/projects").Subrouter()
 
 	// User routes (protected with authentication)
-	userRouter.HandleFunc("", a.wrapRequest(a.getUsersHandler, true)).Methods(http.MethodGet, http.MethodOptions)
+	userRouter.HandleFunc("", a.wrapAdminRequest(a.getUsersHandler)).Methods(http.MethodGet, http.MethodOptions)
+	userRouter.HandleFunc("/me", a.wrapRequest(a.getCurrentUserHandler, true)).Methods(http.MethodGet, http.MethodOptions)
+	userRouter.HandleFunc("/{id}", a.wrapRequest(a.getUserByIDHandler, true)).Methods(http.MethodGet, http.MethodOptions)
 	userRouter.HandleFunc("/{id}", a.wrapRequest(a.deleteUserByIDHandler, true)).Methods(http.MethodDelete, http.MethodOptions)
 
+	// Admin routes (protected with authentication and restricted to site administrators)
+	adminRouter.HandleFunc("/users", a.wrapAdminRequest(a.getUsersHandler)).Methods(http.MethodGet, http.MethodOptions)
+	adminRouter.HandleFunc("/users/{id}", a.wrapAdminRequest(a.deleteUserByIDHandler)).Methods(http.MethodDelete, http.MethodOptions)
+	adminRouter.HandleFunc("/users/{id}/suspend", a.wrapAdminRequest(a.suspendUserHandler)).Methods(http.MethodPost, http.MethodOptions)
+	adminRouter.HandleFunc("/users/{id}/unsuspend", a.wrapAdminRequest(a.unsuspendUserHandler)).Methods(http.MethodPost, http.MethodOptions)
+	adminRouter.HandleFunc("/users/{id}/unlock", a.wrapAdminRequest(a.unlockUserHandler)).Methods(http.MethodPost, http.MethodOptions)
+	adminRouter.HandleFunc("/config", a.wrapAdminRequest(a.getConfigHandler)).Methods(http.MethodGet, http.MethodOptions)
+	adminRouter.HandleFunc("/audit/sinks", a.wrapAdminRequest(a.getAuditSinksHandler)).Methods(http.MethodGet, http.MethodOptions)
+	adminRouter.HandleFunc("/projects", a.wrapAdminRequest(a.getProjectsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	adminRouter.HandleFunc("/projects/{id}", a.wrapAdminRequest(a.deleteProjectByIDHandler)).Methods(http.MethodDelete, http.MethodOptions)
+
+	// Audit routes (restricted to site administrators)
+	auditRouter.HandleFunc("", a.wrapAdminRequest(a.getAuditEventsHandler)).Methods(http.MethodGet, http.MethodOptions)
+
 	// Auth routes
 	authRouter.HandleFunc("/signup", a.wrapRequest(a.signupHandler, false)).Methods(http.MethodPost, http.MethodOptions)
 	authRouter.HandleFunc("/signin", a.wrapRequest(a.signinHandler, false)).Methods(http.MethodPost, http.MethodOptions)
+	authRouter.HandleFunc("/signin/mfa", a.wrapRequest(a.signinMFAHandler, false)).Methods(http.MethodPost, http.MethodOptions)
+	authRouter.HandleFunc("/password/forgot", a.wrapRequest(a.forgotPasswordHandler, false)).Methods(http.MethodPost, http.MethodOptions)
+	authRouter.HandleFunc("/password/reset", a.wrapRequest(a.resetPasswordHandler, false)).Methods(http.MethodPost, http.MethodOptions)
+	authRouter.HandleFunc("/email/verify", a.wrapRequest(a.verifyEmailHandler, false)).Methods(http.MethodPost, http.MethodOptions)
+	authRouter.HandleFunc("/email/resend", a.wrapRequest(a.resendVerificationHandler, false)).Methods(http.MethodPost, http.MethodOptions)
+	authRouter.HandleFunc("/mfa/enroll", a.wrapRequest(a.enrollMFAHandler, true)).Methods(http.MethodPost, http.MethodOptions)
+	authRouter.HandleFunc("/mfa/verify", a.wrapRequest(a.verifyMFAHandler, true)).Methods(http.MethodPost, http.MethodOptions)
+	authRouter.HandleFunc("/mfa/disable", a.wrapRequest(a.disableMFAHandler, true)).Methods(http.MethodPost, http.MethodOptions)
 
 	// Project routes (protected with authentication)
 	projectRouter.HandleFunc("", a.wrapRequest(a.createProjectHandler, true)).Methods(http.MethodPost, http.MethodOptions)
@@ -117,16 +369,35 @@ func (a *App) registerHandlers() {
 	projectRouter.HandleFunc("/{id}", a.wrapRequest(a.deleteProjectByIDHandler, true)).Methods(http.MethodDelete, http.MethodOptions)
 	projectRouter.HandleFunc("/{id}", a.wrapRequest(a.updateProjectHandler, true)).Methods(http.MethodPut, http.MethodOptions)
 
+	// Project webhook routes (protected with auth, restricted to the project owner)
+	projectRouter.HandleFunc("/{id}/webhooks", a.wrapRequest(a.createWebhookHandler, true)).Methods(http.MethodPost, http.MethodOptions)
+	projectRouter.HandleFunc("/{id}/webhooks", a.wrapRequest(a.getWebhooksHandler, true)).Methods(http.MethodGet, http.MethodOptions)
+	projectRouter.HandleFunc("/{id}/webhooks/{webhookID}", a.wrapRequest(a.deleteWebhookHandler, true)).Methods(http.MethodDelete, http.MethodOptions)
+	projectRouter.HandleFunc("/{id}/webhooks/{webhookID}/deliveries", a.wrapRequest(a.getWebhookDeliveriesHandler, true)).Methods(http.MethodGet, http.MethodOptions)
+	projectRouter.HandleFunc("/{id}/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", a.wrapRequest(a.redeliverWebhookHandler, true)).Methods(http.MethodPost, http.MethodOptions)
+
 	// Project env routes (protected with auth)
+	envRouter.HandleFunc("/{id}/env/stream", a.wrapRequest(a.streamProjectEnvHandler, true)).Methods(http.MethodGet, http.MethodOptions)
 	envRouter.HandleFunc("/{id}/env", a.wrapRequest(a.getProjectEnvHandler, true)).Methods(http.MethodGet, http.MethodOptions)
 	envRouter.HandleFunc("/{id}/env", a.wrapRequest(a.createProjectEnvHandler, true)).Methods(http.MethodPost, http.MethodOptions)
+	envRouter.HandleFunc("/{id}/env", a.wrapRequest(a.bulkProjectEnvHandler, true)).Methods(http.MethodPatch, http.MethodOptions)
 	envRouter.HandleFunc("/{projectID}/env/{envID}", a.wrapRequest(a.updateProjectEnvKeyValueHandler, true)).Methods(http.MethodPut, http.MethodOptions)
 	envRouter.HandleFunc("/{projectID}/env/{envID}", a.wrapRequest(a.getProjectEnvKeyValueHandler, true)).Methods(http.MethodGet, http.MethodOptions)
 	envRouter.HandleFunc("/{projectID}/env/{envID}", a.wrapRequest(a.deleteProjectEnvKeyValueHandler, true)).Methods(http.MethodDelete, http.MethodOptions)
 	// Add the authentication middleware to the protected routes
 	userRouter.Use(a.authenticateMiddleware)
+	adminRouter.Use(a.authenticateMiddleware)
+	auditRouter.Use(a.authenticateMiddleware)
 	projectRouter.Use(a.authenticateMiddleware)
 
-	// Set the router for the application
-	http.Handle("/", r)
+	return r
+}
+
+// bootstrapAdmins grants the administrator role to the users listed in the config who verified their email.
+func bootstrapAdmins(db *internal.Database, config internal.ServerConfig) {
+	for _, email := range config.Admins {
+		if err := db.PromoteAdmin(email, true); err != nil {
+			log.Warn().Msgf("Cannot grant the administrator role to %s, the user must sign up and verify the email before a restart, or be promoted with -promote-admin once the operator checked the account: %v", email, err)
+		}
+	}
 }
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const auditContextKey contextKey = "audit"
+
+// auditInfo holds the audit details that handlers learn while processing a request.
+type auditInfo struct {
+	ActorID int
+	KeyName string
+}
+
+// statusWriter records the status code, size and error of the response written by a handler.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	err    error
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the writer.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to extend the deadlines of the streams.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withAuditInfo adds an empty auditInfo to the request context, unless it already holds one.
+func withAuditInfo(r *http.Request) (*http.Request, *auditInfo) {
+	if info, ok := r.Context().Value(auditContextKey).(*auditInfo); ok {
+		return r, info
+	}
+	info := &auditInfo{}
+	return r.WithContext(context.WithValue(r.Context(), auditContextKey, info)), info
+}
+
+// setAuditActor sets the user performing an unauthenticated request, e.g. a signin.
+func setAuditActor(r *http.Request, userID int) {
+	if info, ok := r.Context().Value(auditContextKey).(*auditInfo); ok {
+		info.ActorID = userID
+	}
+}
+
+// setAuditKey sets the env key name accessed by the request, values must never be audited.
+func setAuditKey(r *http.Request, keyName string) {
+	if info, ok := r.Context().Value(auditContextKey).(*auditInfo); ok {
+		info.KeyName = keyName
+	}
+}
+
+// recordAudit appends the audit event of a handled request.
+func (a *App) recordAudit(r *http.Request, info *auditInfo, status int) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	action := r.Method + " " + routeTemplate(r)
+	result := models.AuditSuccess
+	if status >= http.StatusBadRequest {
+		result = models.AuditFailure
+	}
+
+	event := models.AuditEvent{
+		ActorID:   info.ActorID,
+		Action:    action,
+		ProjectID: requestProjectID(r),
+		KeyName:   info.KeyName,
+		SourceIP:  clientIP(r),
+		UserAgent: r.UserAgent(),
+		Result:    result,
+		Status:    status,
+	}
+
+	if err := a.auditDB(r).AppendAuditEvent(&event, a.auditKey()); err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("action", action).Msg("Failed to record audit event")
+		return
+	}
+	a.AuditStreamer.Publish(event)
+}
+
+// auditDB returns the database writing the audit events of a request. Its context keeps the span of the request
+// but isn't cancelled with it, so that the events of the ended streams and of the disconnected clients are still written.
+func (a *App) auditDB(r *http.Request) *internal.Database {
+	return a.DB.WithContext(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(r.Context())))
+}
+
+// auditKey returns the key of the audit chain, derived from the encryption key.
+func (a *App) auditKey() []byte {
+	return internal.AuditChainKey(a.Config.Server.EncryptionKey)
+}
+
+// isSecretRead returns true if the request reads the env of a project.
+func isSecretRead(method, route string) bool {
+	return method == http.MethodGet && strings.HasPrefix(route, "/api/v1/projects/") && strings.Contains(route, "/env")
+}
+
+// routeTemplate returns the path template of the matched route, or the request path if there's none.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// requestProjectID returns the project ID of the request path, 0 if the request doesn't target a project.
+func requestProjectID(r *http.Request) int {
+	vars := mux.Vars(r)
+	idStr, ok := vars["projectID"]
+	if !ok && strings.Contains(routeTemplate(r), "/projects/{id}") {
+		idStr, ok = vars["id"]
+	}
+	if !ok {
+		return 0
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0
+	}
+	return id
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+)
+
+// getAuditEventsHandler returns the latest audit events, newest first.
+// The events can be filtered with the actor_id, project_id, action, key_name, result, since, until and limit query parameters,
+// since and until are RFC 3339 timestamps.
+func (a *App) getAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid audit filter", nil, err)
+		return
+	}
+
+	events, err := a.requestDB(r).GetAuditEvents(filter)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to retrieve audit events", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "Audit events found", events, nil)
+}
+
+// parseAuditFilter reads the audit filter from the query parameters.
+func parseAuditFilter(query url.Values) (internal.AuditFilter, error) {
+	filter := internal.AuditFilter{
+		Action:  query.Get("action"),
+		KeyName: query.Get("key_name"),
+		Result:  query.Get("result"),
+	}
+
+	ints := map[string]*int{
+		"actor_id":   &filter.ActorID,
+		"project_id": &filter.ProjectID,
+		"limit":      &filter.Limit,
+	}
+	for name, value := range ints {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil {
+				return filter, err
+			}
+			*value = parsed
+		}
+	}
+
+	times := map[string]*time.Time{
+		"since": &filter.Since,
+		"until": &filter.Until,
+	}
+	for name, value := range times {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return filter, err
+			}
+			*value = parsed
+		}
+	}
+
+	return filter, nil
+}
+
+// getAuditSinksHandler returns the back-pressure stats of the audit sinks.
+func (a *App) getAuditSinksHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, http.StatusOK, "Audit sinks found", a.AuditStreamer.Stats(), nil)
+}
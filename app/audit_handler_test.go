@@ -0,0 +1,36 @@
+package app
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAuditFilter(t *testing.T) {
+	t.Run("valid filter", func(t *testing.T) {
+		query, err := url.ParseQuery("actor_id=1&project_id=2&key_name=DATABASE_URL&result=failure&since=2023-08-01T00:00:00Z&limit=10")
+		assert.NoError(t, err)
+
+		filter, err := parseAuditFilter(query)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, filter.ActorID)
+		assert.Equal(t, 2, filter.ProjectID)
+		assert.Equal(t, "DATABASE_URL", filter.KeyName)
+		assert.Equal(t, "failure", filter.Result)
+		assert.Equal(t, 10, filter.Limit)
+		assert.Equal(t, time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC), filter.Since)
+		assert.True(t, filter.Until.IsZero())
+	})
+
+	t.Run("invalid actor id", func(t *testing.T) {
+		_, err := parseAuditFilter(url.Values{"actor_id": {"omda"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid since", func(t *testing.T) {
+		_, err := parseAuditFilter(url.Values{"since": {"yesterday"}})
+		assert.Error(t, err)
+	})
+}
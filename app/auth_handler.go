@@ -2,15 +2,25 @@ package app
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	internal "github.com/Mahmoud-Emad/envserver/internal"
 	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/rs/zerolog/log"
 )
 
 var userFields internal.SignUpInputs
 
 func (a *App) signinHandler(w http.ResponseWriter, r *http.Request) {
+	ip := clientIP(r)
+	if lockedUntil, locked := a.SigninThrottler.LockedUntil(ip); locked {
+		a.sendLockedResponse(w, http.StatusTooManyRequests, lockedUntil, internal.TooManyFailedSigninsError)
+		return
+	}
+
 	// Parse request data
 	var fields internal.SigninInputs
 
@@ -20,25 +30,149 @@ func (a *App) signinHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find the user by email
-	user, err := a.DB.GetUserByEmail(fields.Email)
+	user, err := a.requestDB(r).GetUserByEmail(fields.Email)
 	if err != nil {
+		a.recordFailedSignin(r, fields.Email, nil)
 		sendJSONResponse(w, http.StatusUnauthorized, "Cannot get user object with this email.", nil, err)
 		return
 	}
 
+	setAuditActor(r, user.ID)
+
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		a.recordSigninEvent(r, "signin_locked", user.Email, user.ID)
+		a.sendLockedResponse(w, http.StatusLocked, *user.LockedUntil, internal.AccountLockedError)
+		return
+	}
+
 	// Check if the provided password is correct
 	if !internal.CheckPasswordHash(fields.Password, user.HashedPassword) {
+		a.recordFailedSignin(r, user.Email, &user)
 		sendJSONResponse(w, http.StatusUnauthorized, "Invalid email or password", nil, nil)
 		return
 	}
 
+	if user.Suspended {
+		a.recordSigninEvent(r, "signin_suspended", user.Email, user.ID)
+		sendJSONResponse(w, http.StatusForbidden, "Failed to sign in", nil, internal.UserSuspendedError)
+		return
+	}
+
+	if a.Config.Server.RequireEmailVerification && !user.EmailVerified {
+		sendJSONResponse(w, http.StatusForbidden, "Please verify your email address before signing in", nil, internal.EmailNotVerifiedError)
+		return
+	}
+
+	// Users with two-factor authentication get a challenge token to be exchanged at the mfa signin endpoint.
+	if user.MFAEnabled {
+		mfaToken, err := a.GenerateMFAChallengeToken(user)
+		if err != nil {
+			sendJSONResponse(w, http.StatusInternalServerError, "Failed to generate MFA challenge token", nil, err)
+			return
+		}
+
+		sendJSONResponse(w, http.StatusOK, "Two-factor authentication required", map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		}, nil)
+		return
+	}
+
+	a.completeSignin(w, r, user)
+}
+
+// completeSignin issues the access token of a user who passed every signin step, the password and the MFA code if it's enabled.
+// Only then are the failed signins of the account and the client IP forgotten, so that a password signin never resets
+// the failures of the MFA step.
+func (a *App) completeSignin(w http.ResponseWriter, r *http.Request, user models.User) {
+	if user.FailedSignins > 0 || user.LockedUntil != nil {
+		if err := a.requestDB(r).ResetFailedSignins(user.ID); err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Int("user_id", user.ID).Msg("Failed to reset failed signins")
+		}
+	}
+	// Don't keep locking out the other users behind the same address, e.g. a NAT.
+	a.SigninThrottler.Reset(clientIP(r))
+	a.recordSigninEvent(r, "signin_succeeded", user.Email, user.ID)
+
+	a.sendAccessToken(w, user)
+}
+
+// recordFailedSignin counts a failed signin for the client IP and, if the email matched a user, for the account.
+// Both are locked with exponential backoff once their failures reach the configured maximum.
+func (a *App) recordFailedSignin(r *http.Request, email string, user *models.User) {
+	a.Metrics.AuthFailure(internal.AuthFailureInvalidCredentials)
+	ip := clientIP(r)
+	if lockedUntil := a.SigninThrottler.Fail(ip); !lockedUntil.IsZero() {
+		log.Ctx(r.Context()).Warn().Str("event", "signin_ip_locked").Str("ip", ip).Time("locked_until", lockedUntil).Msg("Too many failed signins from IP")
+	}
+
+	if user == nil {
+		a.recordSigninEvent(r, "signin_failed", email, 0)
+		return
+	}
+	a.recordSigninEvent(r, "signin_failed", email, user.ID)
+
+	failures, err := a.requestDB(r).IncrementFailedSignins(user.ID)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Int("user_id", user.ID).Msg("Failed to record failed signin")
+		return
+	}
+
+	server := a.Config.Server
+	duration := internal.LockoutDuration(failures, server.MaxFailedSigninsOrDefault(), server.LockoutDurationOrDefault(), server.MaxLockoutDurationOrDefault())
+	if duration == 0 {
+		return
+	}
+
+	lockedUntil := time.Now().Add(duration)
+	if err := a.requestDB(r).LockUser(user.ID, lockedUntil); err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Int("user_id", user.ID).Msg("Failed to lock user")
+		return
+	}
+	log.Ctx(r.Context()).Warn().Str("event", "account_locked").Int("user_id", user.ID).Int("failures", failures).Time("locked_until", lockedUntil).Msg("Account locked after failed signins")
+}
+
+// recordSigninEvent logs a signin attempt and appends it to the audit log, e.g. "signin_failed" or "signin_locked".
+// The signin request itself is audited too, its event only holds the response status.
+func (a *App) recordSigninEvent(r *http.Request, event, email string, userID int) {
+	log.Ctx(r.Context()).Info().Str("event", event).Str("email", email).Int("user_id", userID).Str("ip", clientIP(r)).Msg("Signin attempt")
+
+	result := models.AuditFailure
+	if event == "signin_succeeded" {
+		result = models.AuditSuccess
+	}
+	auditEvent := models.AuditEvent{
+		ActorID:   userID,
+		Action:    event,
+		SourceIP:  clientIP(r),
+		UserAgent: r.UserAgent(),
+		Result:    result,
+	}
+	if err := a.auditDB(r).AppendAuditEvent(&auditEvent, a.auditKey()); err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("action", event).Msg("Failed to record audit event")
+		return
+	}
+	a.AuditStreamer.Publish(auditEvent)
+}
+
+// sendLockedResponse rejects a signin while the account or client is locked, with a Retry-After header.
+func (a *App) sendLockedResponse(w http.ResponseWriter, status int, lockedUntil time.Time, err error) {
+	a.Metrics.AuthFailure(internal.AuthFailureLocked)
+	retryAfter := int(math.Ceil(time.Until(lockedUntil).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	sendJSONResponse(w, status, "Signin temporarily locked", map[string]interface{}{"locked_until": lockedUntil}, err)
+}
+
+// sendAccessToken generates a JWT access token for the user and writes it in the response.
+func (a *App) sendAccessToken(w http.ResponseWriter, user models.User) {
 	// Generate a JWT token with user data as the payload
 	payload := map[string]interface{}{
 		"id":    user.ID,
 		"email": user.Email,
+		"exp":   time.Now().Add(a.Config.Server.AccessTokenTTLOrDefault()).Unix(),
 	}
 
-	token, err := a.GenerateJwtToken(payload, a.Config.Server.JWTSecretKey)
+	token, err := a.GenerateJwtToken(payload)
 
 	if err != nil {
 		sendJSONResponse(w, http.StatusInternalServerError, "Failed to generate JWT token", nil, err)
@@ -87,7 +221,7 @@ func (a *App) signupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if the email is already taken
-	found, _ := a.DB.GetUserByEmail(userFields.Email)
+	found, _ := a.requestDB(r).GetUserByEmail(userFields.Email)
 
 	if found.Email == userFields.Email {
 		sendJSONResponse(
@@ -110,7 +244,7 @@ func (a *App) signupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save the user in the database
-	err = a.DB.CreateUser(&user)
+	err = a.requestDB(r).CreateUser(&user)
 	if err != nil {
 		sendJSONResponse(
 			w, http.StatusBadRequest,
@@ -121,6 +255,11 @@ func (a *App) signupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account is created even if the verification email can't be sent, it can be requested again.
+	if err := a.sendVerificationEmail(user); err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Int("user_id", user.ID).Msg("Failed to send verification email")
+	}
+
 	// Return success response
 	sendJSONResponse(w, http.StatusCreated, "User registered successfully", user, nil)
 }
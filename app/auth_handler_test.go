@@ -7,8 +7,10 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -160,3 +162,114 @@ func TestSigninHandler(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+// Test failed MFA codes lock the account even when password signins are sent in between.
+func TestSigninMFALockout(t *testing.T) {
+	tempFile := createConfTempFile(t)
+	defer func() {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+	}()
+
+	app, err := NewApp(tempFile.Name())
+	assert.NoError(t, err)
+
+	email := "mfa-lockout@gmail.com"
+	password := "password123"
+	hashedPassword, err := internal.HashPassword(password)
+	assert.NoError(t, err)
+	secret, err := internal.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	encryptedSecret, err := internal.EncryptAES([]byte(secret), app.Config.Server.EncryptionKey)
+	assert.NoError(t, err)
+
+	user := models.User{FirstName: "omda", LastName: "man", Email: email, HashedPassword: hashedPassword}
+	assert.NoError(t, app.DB.CreateUser(&user))
+	defer app.DB.DeleteUserByEmail(email)
+	assert.NoError(t, app.DB.UpdateUserMFA(user.ID, true, encryptedSecret))
+
+	signin := func() *httptest.ResponseRecorder {
+		jsonPayload, err := json.Marshal(internal.SigninInputs{Email: email, Password: password})
+		assert.NoError(t, err)
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/auth/signin", strings.NewReader(string(jsonPayload)))
+		responseRecorder := httptest.NewRecorder()
+		app.signinHandler(responseRecorder, request)
+		return responseRecorder
+	}
+
+	for i := 0; i < app.Config.Server.MaxFailedSigninsOrDefault(); i++ {
+		responseRecorder := signin()
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+
+		var response struct {
+			Data struct {
+				MFAToken string `json:"mfa_token"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+
+		jsonPayload, err := json.Marshal(internal.MFASigninInputs{MFAToken: response.Data.MFAToken, Code: "invalid"})
+		assert.NoError(t, err)
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/auth/signin/mfa", strings.NewReader(string(jsonPayload)))
+		responseRecorder = httptest.NewRecorder()
+		app.signinMFAHandler(responseRecorder, request)
+		assert.Equal(t, http.StatusUnauthorized, responseRecorder.Code)
+	}
+
+	assert.Equal(t, http.StatusLocked, signin().Code)
+}
+
+// Test an accepted TOTP code can't be replayed to sign in again.
+func TestSigninMFAReplay(t *testing.T) {
+	tempFile := createConfTempFile(t)
+	defer func() {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+	}()
+
+	app, err := NewApp(tempFile.Name())
+	assert.NoError(t, err)
+
+	email := "mfa-replay@gmail.com"
+	password := "password123"
+	hashedPassword, err := internal.HashPassword(password)
+	assert.NoError(t, err)
+	secret, err := internal.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	encryptedSecret, err := internal.EncryptAES([]byte(secret), app.Config.Server.EncryptionKey)
+	assert.NoError(t, err)
+
+	user := models.User{FirstName: "omda", LastName: "man", Email: email, HashedPassword: hashedPassword}
+	assert.NoError(t, app.DB.CreateUser(&user))
+	defer app.DB.DeleteUserByEmail(email)
+	assert.NoError(t, app.DB.UpdateUserMFA(user.ID, true, encryptedSecret))
+
+	code, err := internal.GenerateTOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+
+	signinWithCode := func() int {
+		jsonPayload, err := json.Marshal(internal.SigninInputs{Email: email, Password: password})
+		assert.NoError(t, err)
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/auth/signin", strings.NewReader(string(jsonPayload)))
+		responseRecorder := httptest.NewRecorder()
+		app.signinHandler(responseRecorder, request)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+
+		var response struct {
+			Data struct {
+				MFAToken string `json:"mfa_token"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+
+		jsonPayload, err = json.Marshal(internal.MFASigninInputs{MFAToken: response.Data.MFAToken, Code: code})
+		assert.NoError(t, err)
+		request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/signin/mfa", strings.NewReader(string(jsonPayload)))
+		responseRecorder = httptest.NewRecorder()
+		app.signinMFAHandler(responseRecorder, request)
+		return responseRecorder.Code
+	}
+
+	assert.Equal(t, http.StatusOK, signinWithCode())
+	assert.Equal(t, http.StatusUnauthorized, signinWithCode())
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+)
+
+// handleCORS answers the preflight requests and adds the CORS headers to the responses of the allowed origins.
+// Preflight requests from a denied origin, or asking for a denied method or header, are rejected with a 403 status;
+// the other requests of a denied origin are served without CORS headers, so that the browser hides their response.
+// The preflight requests are never rate limited, the other OPTIONS requests are answered by answerOptions.
+func (a *App) handleCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := a.currentConfig().CORS
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		preflight := isPreflight(r)
+		w.Header().Add("Vary", "Origin")
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		// No origin is allowed if CORS isn't enabled.
+		if !config.AllowsOrigin(origin) {
+			if preflight {
+				sendJSONResponse(w, http.StatusForbidden, "Origin not allowed", nil, nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if config.AllowsAnyOrigin() && !config.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Origin", internal.AnyOrigin)
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(internal.CORSExposedHeaders, ", "))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if method := r.Header.Get("Access-Control-Request-Method"); !config.AllowsMethod(method) {
+			sendJSONResponse(w, http.StatusForbidden, "Method not allowed: "+method, nil, nil)
+			return
+		}
+		for _, header := range requestedHeaders(r) {
+			if !config.AllowsHeader(header) {
+				sendJSONResponse(w, http.StatusForbidden, "Header not allowed: "+header, nil, nil)
+				return
+			}
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(config.AllowedMethodsOrDefault(), ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(config.AllowedHeadersOrDefault(), ", "))
+		if config.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(config.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// answerOptions answers the OPTIONS requests that aren't preflights with an empty response once they passed the rate limit,
+// the routes accept the method for the preflights only and their handlers never serve it.
+func answerOptions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isPreflight returns true if the request is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// requestedHeaders returns the headers listed in the Access-Control-Request-Headers of a preflight request.
+func requestedHeaders(r *http.Request) []string {
+	var headers []string
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				headers = append(headers, header)
+			}
+		}
+	}
+	return headers
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleCORS(t *testing.T) {
+	app := &App{Config: internal.Config{CORS: internal.CORSConfig{
+		AllowedOrigins:   []string{"https://dashboard.example.com"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}}}
+	router := app.registerHandlers().(*mux.Router)
+
+	serve := func(method, path, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			request.Header.Set("Origin", origin)
+		}
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+	preflight := map[string]string{
+		"Access-Control-Request-Method":  http.MethodPut,
+		"Access-Control-Request-Headers": "authorization, content-type",
+	}
+
+	t.Run("Test preflight of every route", func(t *testing.T) {
+		routes, err := routeOperations(router)
+		assert.NoError(t, err)
+
+		variable := regexp.MustCompile(`{[^}]+}`)
+		for _, route := range routes {
+			// Routes are documented as "METHOD /path".
+			path := variable.ReplaceAllString(strings.Fields(route)[1], "1")
+			responseRecorder := serve(http.MethodOptions, path, "https://dashboard.example.com", preflight)
+			assert.Equal(t, http.StatusNoContent, responseRecorder.Code, route)
+			assert.Equal(t, "https://dashboard.example.com", responseRecorder.Header().Get("Access-Control-Allow-Origin"), route)
+			assert.Equal(t, "true", responseRecorder.Header().Get("Access-Control-Allow-Credentials"), route)
+			assert.Contains(t, responseRecorder.Header().Get("Access-Control-Allow-Methods"), http.MethodPut, route)
+			assert.Equal(t, "600", responseRecorder.Header().Get("Access-Control-Max-Age"), route)
+		}
+	})
+
+	t.Run("Test allowed origin", func(t *testing.T) {
+		responseRecorder := serve(http.MethodGet, "/api/v1/openapi.json", "https://dashboard.example.com", nil)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.Equal(t, "https://dashboard.example.com", responseRecorder.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, responseRecorder.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+		assert.Contains(t, responseRecorder.Header().Values("Vary"), "Origin")
+	})
+
+	t.Run("Test denied origin", func(t *testing.T) {
+		responseRecorder := serve(http.MethodOptions, "/api/v1/projects", "https://evil.example.com", preflight)
+		assert.Equal(t, http.StatusForbidden, responseRecorder.Code)
+		assert.Empty(t, responseRecorder.Header().Get("Access-Control-Allow-Origin"))
+
+		responseRecorder = serve(http.MethodGet, "/api/v1/openapi.json", "https://evil.example.com", nil)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.Empty(t, responseRecorder.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Test denied method and header", func(t *testing.T) {
+		responseRecorder := serve(http.MethodOptions, "/api/v1/projects", "https://dashboard.example.com", map[string]string{
+			"Access-Control-Request-Method": "TRACE",
+		})
+		assert.Equal(t, http.StatusForbidden, responseRecorder.Code)
+
+		responseRecorder = serve(http.MethodOptions, "/api/v1/projects", "https://dashboard.example.com", map[string]string{
+			"Access-Control-Request-Method":  http.MethodGet,
+			"Access-Control-Request-Headers": "X-Custom",
+		})
+		assert.Equal(t, http.StatusForbidden, responseRecorder.Code)
+	})
+
+	t.Run("Test OPTIONS requests never reach the handlers", func(t *testing.T) {
+		for _, origin := range []string{"https://dashboard.example.com", "https://evil.example.com", ""} {
+			responseRecorder := serve(http.MethodOptions, "/api/v1/openapi.json", origin, nil)
+			assert.Equal(t, http.StatusNoContent, responseRecorder.Code, origin)
+			assert.Empty(t, responseRecorder.Body.String(), origin)
+		}
+
+		// CORS is disabled without allowed origins.
+		disabled := &App{}
+		responseRecorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodOptions, "/api/v1/openapi.json", nil)
+		request.Header.Set("Origin", "https://dashboard.example.com")
+		request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		disabled.registerHandlers().ServeHTTP(responseRecorder, request)
+		assert.Equal(t, http.StatusForbidden, responseRecorder.Code)
+		assert.Empty(t, responseRecorder.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Test same origin requests", func(t *testing.T) {
+		responseRecorder := serve(http.MethodGet, "/api/v1/openapi.json", "", nil)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.Empty(t, responseRecorder.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Test any origin", func(t *testing.T) {
+		app := &App{Config: internal.Config{CORS: internal.CORSConfig{AllowedOrigins: []string{internal.AnyOrigin}}}}
+		responseRecorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodOptions, "/api/v1/projects", nil)
+		request.Header.Set("Origin", "https://other.example.com")
+		request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		app.registerHandlers().ServeHTTP(responseRecorder, request)
+		assert.Equal(t, http.StatusNoContent, responseRecorder.Code)
+		assert.Equal(t, "*", responseRecorder.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, responseRecorder.Header().Get("Access-Control-Allow-Credentials"))
+	})
+}
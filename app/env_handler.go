@@ -2,18 +2,22 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	internal "github.com/Mahmoud-Emad/envserver/internal"
 	"github.com/Mahmoud-Emad/envserver/models"
 	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
 )
 
 var envFields internal.EnvironmentKeyInputs
 
-// getProjectEnvHandler retrieves a list of project env vars from the database and sends the response as JSON.
+// getProjectEnvHandler retrieves a page of project env vars from the database and sends the response as JSON.
+// The keys can be filtered with the name_prefix and updated_since query parameters, see parseListFilter.
 func (a *App) getProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	if len(vars) == 0 {
@@ -41,19 +45,59 @@ func (a *App) getProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	pId := int(convertedProjectId)
-	env, err := a.DB.GetEnvKeysAndValuesById(pId)
 
+	project, err := a.requestDB(r).GetProjectByID(pId)
 	if err != nil {
 		sendJSONResponse(
 			w,
 			http.StatusNotFound,
+			fmt.Sprintf("Failed to retrieve project with id %s", projectIDStr),
+			nil,
+			err,
+		)
+		return
+	}
+
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return
+	}
+
+	if err := checkProjectMFA(user, project); err != nil {
+		sendJSONResponse(w, http.StatusForbidden, "Failed to retrieve project environment", nil, err)
+		return
+	}
+
+	filter, err := parseListFilter(r.URL.Query())
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid project environment filter", nil, err)
+		return
+	}
+
+	env, nextCursor, err := a.requestDB(r).GetEnvKeysAndValuesById(pId, filter)
+
+	if err != nil {
+		sendJSONResponse(
+			w,
+			listErrorStatus(err),
 			"Failed to retrieve project environment",
 			nil,
 			err,
 		)
 		return
 	}
-	sendJSONResponse(w, http.StatusOK, "Project environment found successfully", env, nil)
+
+	env, undecryptable := a.decryptEnvValues(r, env)
+	if len(undecryptable) > 0 {
+		// Never send an env missing some keys, the clients would take them for deleted keys.
+		sendJSONResponse(w, http.StatusConflict, "Failed to decrypt project environment", map[string]interface{}{
+			"undecryptable_keys": undecryptable,
+		}, internal.UndecryptableEnvValueError)
+		return
+	}
+
+	sendPageResponse(w, "Project environment found successfully", env, nextCursor)
 }
 
 // updateProjectEnvKeyValueHandler is an endpoint to update the key/value of an exist key in the database by providing the object ID.
@@ -83,7 +127,7 @@ func (a *App) updateProjectEnvKeyValueHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	_, err = a.DB.GetProjectByID(int(convertedProjectId))
+	_, err = a.requestDB(r).GetProjectByID(int(convertedProjectId))
 	if err != nil {
 		sendJSONResponse(
 			w,
@@ -121,32 +165,35 @@ func (a *App) updateProjectEnvKeyValueHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	hashedValue, err := internal.HashPassword(envFields.Value)
+	encryptedValue, err := a.encryptEnvValue(envFields.Value)
 	if err != nil {
 		sendJSONResponse(
 			w,
 			http.StatusBadRequest,
-			"Error hashing value:",
+			"Error encrypting value",
 			nil,
 			err,
 		)
 		return
 	}
 
-	existingEnv, err := a.DB.GetProjectEnvByID(int(convertedEnvId))
+	existingEnv, err := a.requestDB(r).GetProjectEnvByID(int(convertedEnvId))
 	if err != nil {
 		sendJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Failed to retrieve project environment with id %s", envIDStr), nil, err)
 		return
 	}
 
+	setAuditKey(r, existingEnv.Key)
 	existingEnv.Key = envFields.Key
-	existingEnv.Value = hashedValue
+	existingEnv.Value = encryptedValue
 
-	err = a.DB.UpdateProjectEnvironment(existingEnv)
+	err = a.requestDB(r).UpdateProjectEnvironment(&existingEnv)
 	if err != nil {
 		sendJSONResponse(w, http.StatusInternalServerError, "Failed to update project environment", nil, err)
 		return
 	}
+	a.notifyChange(r, existingEnv.ProjectID, models.EnvUpdatedEvent, existingEnv.Key, existingEnv.Version)
+	existingEnv.Value = []byte(envFields.Value)
 	sendJSONResponse(w, http.StatusOK, "Project environment updated successfully", existingEnv, nil)
 }
 
@@ -178,7 +225,7 @@ func (a *App) createProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	project, err := a.DB.GetProjectByID(int(convertedProjectId))
+	project, err := a.requestDB(r).GetProjectByID(int(convertedProjectId))
 	if err != nil {
 		sendJSONResponse(
 			w,
@@ -202,6 +249,8 @@ func (a *App) createProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setAuditKey(r, envFields.Key)
+
 	// Validate user data
 	err = envFields.Validate()
 	if err != nil {
@@ -215,12 +264,12 @@ func (a *App) createProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hashedValue, err := internal.HashPassword(envFields.Value)
+	encryptedValue, err := a.encryptEnvValue(envFields.Value)
 	if err != nil {
 		sendJSONResponse(
 			w,
 			http.StatusBadRequest,
-			"Error hashing value:",
+			"Error encrypting value",
 			nil,
 			err,
 		)
@@ -230,11 +279,11 @@ func (a *App) createProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
 	// Create new project environment object.
 	env := models.EnvironmentKey{
 		Key:       envFields.Key,
-		Value:     hashedValue,
+		Value:     encryptedValue,
 		ProjectID: int(convertedProjectId),
 	}
 
-	err = a.DB.CreateEnvKey(&env)
+	err = a.requestDB(r).CreateEnvKey(&env)
 	if err != nil {
 		sendJSONResponse(
 			w, http.StatusBadRequest,
@@ -247,7 +296,7 @@ func (a *App) createProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Update the project's keys.
 	project.Keys = append(project.Keys, &env)
-	err = a.DB.UpdateProject(&project)
+	err = a.requestDB(r).UpdateProject(&project)
 	if err != nil {
 		sendJSONResponse(
 			w, http.StatusBadRequest,
@@ -258,10 +307,92 @@ func (a *App) createProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	env.Value = []byte(envFields.Value)
 	envFields = internal.EnvironmentKeyInputs{}
+	a.notifyChange(r, env.ProjectID, models.EnvCreatedEvent, env.Key, env.Version)
 	sendJSONResponse(w, http.StatusCreated, "Project environment created successfully", env, nil)
 }
 
+// bulkProjectEnvHandler applies a batch of set and delete operations to the project env keys in a single transaction.
+// All the operations are validated first, then either all of them are applied or none, the response holds the result of each operation.
+func (a *App) bulkProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := mux.Vars(r)["id"]
+	projectID, err := strconv.Atoi(projectIDStr)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Cannot convert project id to number", nil, err)
+		return
+	}
+
+	if _, err := a.requestDB(r).GetProjectByID(projectID); err != nil {
+		sendJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Failed to retrieve project with id %s", projectIDStr), nil, err)
+		return
+	}
+
+	var batch internal.EnvBatchInputs
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	keys := make([]string, len(batch.Operations))
+	for i, operation := range batch.Operations {
+		keys[i] = operation.Key
+	}
+	setAuditKey(r, strings.Join(keys, ","))
+
+	errs, err := batch.Validate(a.Config.Server.MaxEnvBatchSizeOrDefault())
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid env operations", envOperationResults(batch.Operations, errs), err)
+		return
+	}
+
+	operations := make([]internal.EnvOperation, len(batch.Operations))
+	for i, operation := range batch.Operations {
+		operations[i] = internal.EnvOperation{Op: operation.Op, Key: operation.Key}
+		if operation.Op == internal.EnvSetOperation {
+			if operations[i].Value, err = a.encryptEnvValue(operation.Value); err != nil {
+				sendJSONResponse(w, http.StatusBadRequest, "Error encrypting value", nil, err)
+				return
+			}
+		}
+	}
+
+	results, err := a.requestDB(r).ApplyEnvOperations(projectID, operations)
+	var operationErr *internal.EnvOperationError
+	if errors.As(err, &operationErr) {
+		errs = make([]error, len(operations))
+		errs[operationErr.Index] = operationErr.Err
+
+		status := http.StatusInternalServerError
+		if errors.Is(err, internal.EnvKeyNotFoundError) {
+			status = http.StatusNotFound
+		}
+		sendJSONResponse(w, status, "Failed to apply the env operations, none were applied", envOperationResults(batch.Operations, errs), err)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to apply the env operations, none were applied", nil, err)
+		return
+	}
+
+	for _, result := range results {
+		a.notifyChange(r, projectID, result.Event, result.Key, result.Version)
+	}
+	sendJSONResponse(w, http.StatusOK, "Env operations applied successfully", results, nil)
+}
+
+// envOperationResults returns the results of operations that weren't applied, with their errors.
+func envOperationResults(operations []internal.EnvOperationInputs, errs []error) []internal.EnvOperationResult {
+	results := make([]internal.EnvOperationResult, len(operations))
+	for i, operation := range operations {
+		results[i] = internal.EnvOperationResult{Op: operation.Op, Key: operation.Key}
+		if i < len(errs) && errs[i] != nil {
+			results[i].Error = errs[i].Error()
+		}
+	}
+	return results
+}
+
 // getProjectEnvKeyValueHandler is an endpoint to get the key/value of an exist key in the database by providing the object ID.
 func (a *App) getProjectEnvKeyValueHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -289,7 +420,7 @@ func (a *App) getProjectEnvKeyValueHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	_, err = a.DB.GetProjectByID(int(convertedProjectId))
+	project, err := a.requestDB(r).GetProjectByID(int(convertedProjectId))
 	if err != nil {
 		sendJSONResponse(
 			w,
@@ -301,6 +432,17 @@ func (a *App) getProjectEnvKeyValueHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return
+	}
+
+	if err := checkProjectMFA(user, project); err != nil {
+		sendJSONResponse(w, http.StatusForbidden, "Failed to retrieve project environment", nil, err)
+		return
+	}
+
 	envIDStr := vars["envID"]
 	convertedEnvId, err := strconv.ParseInt(envIDStr, 10, 64)
 
@@ -315,12 +457,20 @@ func (a *App) getProjectEnvKeyValueHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	existingEnv, err := a.DB.GetProjectEnvByID(int(convertedEnvId))
+	existingEnv, err := a.requestDB(r).GetProjectEnvByID(int(convertedEnvId))
 	if err != nil {
 		sendJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Failed to retrieve project environment with id %s", envIDStr), nil, err)
 		return
 	}
 
+	setAuditKey(r, existingEnv.Key)
+
+	value, err := a.decryptEnvValue(existingEnv.Value)
+	if err != nil {
+		sendJSONResponse(w, http.StatusConflict, "Failed to decrypt project environment", nil, internal.UndecryptableEnvValueError)
+		return
+	}
+	existingEnv.Value = value
 	sendJSONResponse(w, http.StatusOK, "Project environment updated successfully", existingEnv, nil)
 }
 
@@ -351,7 +501,7 @@ func (a *App) deleteProjectEnvKeyValueHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	_, err = a.DB.GetProjectByID(int(convertedProjectId))
+	_, err = a.requestDB(r).GetProjectByID(int(convertedProjectId))
 	if err != nil {
 		sendJSONResponse(
 			w,
@@ -377,12 +527,42 @@ func (a *App) deleteProjectEnvKeyValueHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	existingEnv, err := a.DB.GetProjectEnvByID(int(convertedEnvId))
+	existingEnv, err := a.requestDB(r).GetProjectEnvByID(int(convertedEnvId))
 	if err != nil {
 		sendJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Failed to retrieve project environment with id %s", envIDStr), nil, err)
 		return
 	}
 
-	a.DB.DeleteProjectEnvByID(existingEnv.ID)
+	setAuditKey(r, existingEnv.Key)
+	a.requestDB(r).DeleteProjectEnvByID(existingEnv.ID)
+	a.notifyChange(r, existingEnv.ProjectID, models.EnvDeletedEvent, existingEnv.Key, existingEnv.Version)
 	sendJSONResponse(w, http.StatusNoContent, "Project environment deleted successfully", nil, nil)
 }
+
+// encryptEnvValue encrypts an env value with the server encryption key before it's stored.
+func (a *App) encryptEnvValue(value string) ([]byte, error) {
+	return internal.EncryptAES([]byte(value), a.Config.Server.EncryptionKey)
+}
+
+// decryptEnvValue decrypts a stored env value.
+func (a *App) decryptEnvValue(value []byte) ([]byte, error) {
+	return internal.DecryptAES(value, a.Config.Server.EncryptionKey)
+}
+
+// decryptEnvValues returns the env keys with their decrypted values, and the names of the keys whose value can't be decrypted,
+// e.g. the values hashed by older versions. These keys are logged so that they can be set again.
+func (a *App) decryptEnvValues(r *http.Request, env []models.EnvironmentKey) ([]models.EnvironmentKey, []string) {
+	decrypted := make([]models.EnvironmentKey, 0, len(env))
+	undecryptable := []string{}
+	for _, key := range env {
+		value, err := a.decryptEnvValue(key.Value)
+		if err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Int("env_id", key.ID).Str("key", key.Key).Msg("Env value can't be decrypted, it must be set again")
+			undecryptable = append(undecryptable, key.Key)
+			continue
+		}
+		key.Value = value
+		decrypted = append(decrypted, key)
+	}
+	return decrypted, undecryptable
+}
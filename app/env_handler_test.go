@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/Mahmoud-Emad/envserver/models"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
@@ -76,7 +77,7 @@ func TestProjectEnv(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -98,7 +99,7 @@ func TestProjectEnv(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -119,11 +120,54 @@ func TestProjectEnv(t *testing.T) {
 		assert.Equal(t, responseRecorder.Result().StatusCode, http.StatusOK)
 	})
 
+	t.Run("Test bulk update project env", func(t *testing.T) {
+		app, err := NewApp(tempFile.Name())
+		assert.NoError(t, err)
+
+		endpoint := fmt.Sprintf("/api/v1/projects/%s/env", projectID)
+		patch := func(body string) (*httptest.ResponseRecorder, []internal.EnvOperationResult) {
+			request := httptest.NewRequest(http.MethodPatch, endpoint, strings.NewReader(body))
+			request = mux.SetURLVars(request, map[string]string{"id": fmt.Sprint(projectID)})
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set("Authorization", userToken)
+
+			responseRecorder := httptest.NewRecorder()
+			app.bulkProjectEnvHandler(responseRecorder, request)
+
+			var response struct {
+				Data []internal.EnvOperationResult `json:"data"`
+			}
+			assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+			return responseRecorder, response.Data
+		}
+
+		responseRecorder, results := patch(`{"operations": [{"op": "set", "key": "API_URL", "value": "http://api"}, {"op": "rename", "key": "API_TOKEN"}]}`)
+		assert.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+		assert.Len(t, results, 2)
+		assert.Empty(t, results[0].Error)
+		assert.NotEmpty(t, results[1].Error)
+
+		responseRecorder, results = patch(`{"operations": [{"op": "set", "key": "API_URL", "value": "http://api"}, {"op": "delete", "key": "API_TOKEN"}]}`)
+		assert.Equal(t, http.StatusNotFound, responseRecorder.Result().StatusCode)
+		assert.NotEmpty(t, results[1].Error)
+
+		responseRecorder, results = patch(`{"operations": [{"op": "set", "key": "API_URL", "value": "http://api"}, {"op": "set", "key": "API_TOKEN", "value": "xyz"}]}`)
+		assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+		assert.Equal(t, "env.created", results[0].Event)
+		assert.Equal(t, 1, results[0].Version)
+
+		responseRecorder, results = patch(`{"operations": [{"op": "set", "key": "API_URL", "value": "http://api:8080"}, {"op": "delete", "key": "API_TOKEN"}]}`)
+		assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+		assert.Equal(t, "env.updated", results[0].Event)
+		assert.Equal(t, 2, results[0].Version)
+		assert.Equal(t, "env.deleted", results[1].Event)
+	})
+
 	t.Run("Test success delete project", func(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -147,7 +191,7 @@ func TestProjectEnv(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -186,3 +230,25 @@ func getProjectID(t *testing.T, responseRecorder *httptest.ResponseRecorder) str
 	sID := fmt.Sprintf("%d", int(projectID)) // convert to string
 	return sID
 }
+
+// Test the keys whose value can't be decrypted are reported instead of being left out silently.
+func TestDecryptEnvValues(t *testing.T) {
+	app := &App{Config: internal.Config{Server: internal.ServerConfig{JWTSecretKey: "xyz", EncryptionKey: "abc"}}}
+
+	encrypted, err := app.encryptEnvValue("postgres://localhost")
+	assert.NoError(t, err)
+	hashed, err := internal.HashPassword("legacy")
+	assert.NoError(t, err)
+	otherKey, err := internal.EncryptAES([]byte("signed with the jwt key"), "xyz")
+	assert.NoError(t, err)
+
+	env, undecryptable := app.decryptEnvValues(httptest.NewRequest(http.MethodGet, "/", nil), []models.EnvironmentKey{
+		{ID: 1, Key: "DATABASE_URL", Value: encrypted},
+		{ID: 2, Key: "LEGACY", Value: hashed},
+		{ID: 3, Key: "OTHER", Value: otherKey},
+	})
+	assert.Len(t, env, 1)
+	assert.Equal(t, "DATABASE_URL", env[0].Key)
+	assert.Equal(t, "postgres://localhost", string(env[0].Value))
+	assert.Equal(t, []string{"LEGACY", "OTHER"}, undecryptable)
+}
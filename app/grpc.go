@@ -0,0 +1,504 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/Mahmoud-Emad/envserver/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcService implements the gRPC API by serving every RPC in-process through the REST router,
+// so that both APIs share the same validation, authorization, audit and change notification code paths.
+type grpcService struct {
+	pb.UnimplementedEnvserverServer
+	handler http.Handler
+}
+
+// NewGRPCServer creates the gRPC server of the API served by the handler, see App.Handler, with options such as the TLS credentials.
+func NewGRPCServer(handler http.Handler, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	pb.RegisterEnvserverServer(server, &grpcService{handler: handler})
+	return server
+}
+
+func (s *grpcService) Signup(ctx context.Context, req *pb.SignupRequest) (*pb.User, error) {
+	var user models.User
+	err := s.call(ctx, http.MethodPost, "/api/v1/auth/signup", internal.SignUpInputs{
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		Email:        req.Email,
+		Password:     req.Password,
+		ProjectOwner: req.IsOwner,
+	}, &user)
+	return userToPB(user), err
+}
+
+func (s *grpcService) Signin(ctx context.Context, req *pb.SigninRequest) (*pb.SigninResponse, error) {
+	var response signinResponse
+	err := s.call(ctx, http.MethodPost, "/api/v1/auth/signin", internal.SigninInputs{Email: req.Email, Password: req.Password}, &response)
+	return &pb.SigninResponse{Token: response.Token, MfaRequired: response.MFARequired, MfaToken: response.MFAToken}, err
+}
+
+func (s *grpcService) SigninMFA(ctx context.Context, req *pb.SigninMFARequest) (*pb.SigninResponse, error) {
+	var response signinResponse
+	err := s.call(ctx, http.MethodPost, "/api/v1/auth/signin/mfa", internal.MFASigninInputs{MFAToken: req.MfaToken, Code: req.Code}, &response)
+	return &pb.SigninResponse{Token: response.Token}, err
+}
+
+func (s *grpcService) GetCurrentUser(ctx context.Context, _ *emptypb.Empty) (*pb.User, error) {
+	var user models.User
+	err := s.call(ctx, http.MethodGet, "/api/v1/users/me", nil, &user)
+	return userToPB(user), err
+}
+
+func (s *grpcService) ListProjects(ctx context.Context, req *pb.ListProjectsRequest) (*pb.ListProjectsResponse, error) {
+	var projects []models.Project
+	page := &bridgePage{data: &projects}
+	query := listQuery(req.Options, url.Values{
+		"name_prefix":      {req.NamePrefix},
+		"owner":            {strconv.FormatInt(req.Owner, 10)},
+		"environment_name": {req.EnvironmentName},
+	})
+	if err := s.call(ctx, http.MethodGet, "/api/v1/projects"+query, nil, page); err != nil {
+		return nil, err
+	}
+
+	response := &pb.ListProjectsResponse{NextCursor: page.nextCursor}
+	for _, project := range projects {
+		response.Projects = append(response.Projects, projectToPB(project))
+	}
+	return response, nil
+}
+
+func (s *grpcService) GetProject(ctx context.Context, req *pb.ProjectRequest) (*pb.Project, error) {
+	var project models.Project
+	err := s.call(ctx, http.MethodGet, fmt.Sprintf("/api/v1/projects/%d", req.Id), nil, &project)
+	return projectToPB(project), err
+}
+
+func (s *grpcService) CreateProject(ctx context.Context, req *pb.CreateProjectRequest) (*pb.Project, error) {
+	var project models.Project
+	err := s.call(ctx, http.MethodPost, "/api/v1/projects", internal.ProjectInputs{Name: req.Name}, &project)
+	return projectToPB(project), err
+}
+
+func (s *grpcService) UpdateProject(ctx context.Context, req *pb.Project) (*pb.Project, error) {
+	var project models.Project
+	err := s.call(ctx, http.MethodPut, fmt.Sprintf("/api/v1/projects/%d", req.Id), models.Project{
+		Name:            req.Name,
+		EnvironmentName: req.EnvironmentName,
+		Owner:           int(req.Owner),
+		RequireMFA:      req.RequireMfa,
+	}, &project)
+	return projectToPB(project), err
+}
+
+func (s *grpcService) DeleteProject(ctx context.Context, req *pb.ProjectRequest) (*emptypb.Empty, error) {
+	err := s.call(ctx, http.MethodDelete, fmt.Sprintf("/api/v1/projects/%d", req.Id), nil, nil)
+	return &emptypb.Empty{}, err
+}
+
+func (s *grpcService) ListEnv(ctx context.Context, req *pb.ListEnvRequest) (*pb.ListEnvResponse, error) {
+	var env []models.EnvironmentKey
+	page := &bridgePage{data: &env}
+	query := listQuery(req.Options, url.Values{"name_prefix": {req.NamePrefix}})
+	if err := s.call(ctx, http.MethodGet, fmt.Sprintf("/api/v1/projects/%d/env", req.ProjectId)+query, nil, page); err != nil {
+		return nil, err
+	}
+
+	response := &pb.ListEnvResponse{NextCursor: page.nextCursor}
+	for _, key := range env {
+		response.Keys = append(response.Keys, envKeyToPB(key))
+	}
+	return response, nil
+}
+
+func (s *grpcService) GetEnvKey(ctx context.Context, req *pb.EnvKeyRequest) (*pb.EnvKey, error) {
+	var env models.EnvironmentKey
+	err := s.call(ctx, http.MethodGet, fmt.Sprintf("/api/v1/projects/%d/env/%d", req.ProjectId, req.Id), nil, &env)
+	return envKeyToPB(env), err
+}
+
+func (s *grpcService) CreateEnvKey(ctx context.Context, req *pb.CreateEnvKeyRequest) (*pb.EnvKey, error) {
+	var env models.EnvironmentKey
+	err := s.call(ctx, http.MethodPost, fmt.Sprintf("/api/v1/projects/%d/env", req.ProjectId), internal.EnvironmentKeyInputs{Key: req.Key, Value: req.Value}, &env)
+	return envKeyToPB(env), err
+}
+
+func (s *grpcService) UpdateEnvKey(ctx context.Context, req *pb.UpdateEnvKeyRequest) (*pb.EnvKey, error) {
+	var env models.EnvironmentKey
+	err := s.call(ctx, http.MethodPut, fmt.Sprintf("/api/v1/projects/%d/env/%d", req.ProjectId, req.Id), internal.EnvironmentKeyInputs{Key: req.Key, Value: req.Value}, &env)
+	return envKeyToPB(env), err
+}
+
+func (s *grpcService) DeleteEnvKey(ctx context.Context, req *pb.EnvKeyRequest) (*emptypb.Empty, error) {
+	err := s.call(ctx, http.MethodDelete, fmt.Sprintf("/api/v1/projects/%d/env/%d", req.ProjectId, req.Id), nil, nil)
+	return &emptypb.Empty{}, err
+}
+
+func (s *grpcService) ApplyEnv(ctx context.Context, req *pb.ApplyEnvRequest) (*pb.ApplyEnvResponse, error) {
+	batch := internal.EnvBatchInputs{}
+	for _, operation := range req.Operations {
+		batch.Operations = append(batch.Operations, internal.EnvOperationInputs{Op: operation.Op, Key: operation.Key, Value: operation.Value})
+	}
+
+	var results []internal.EnvOperationResult
+	if err := s.call(ctx, http.MethodPatch, fmt.Sprintf("/api/v1/projects/%d/env", req.ProjectId), batch, &results); err != nil {
+		return nil, err
+	}
+
+	response := &pb.ApplyEnvResponse{}
+	for _, result := range results {
+		response.Results = append(response.Results, &pb.EnvOperationResult{
+			Op:      result.Op,
+			Key:     result.Key,
+			Event:   result.Event,
+			Version: int64(result.Version),
+		})
+	}
+	return response, nil
+}
+
+// WatchEnv serves the SSE env stream of the project and forwards its events to the gRPC stream.
+func (s *grpcService) WatchEnv(req *pb.WatchEnvRequest, stream pb.Envserver_WatchEnvServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	request, err := newBridgeRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/projects/%d/env/stream", req.ProjectId), nil)
+	if err != nil {
+		return err
+	}
+	if req.LastEventId > 0 {
+		request.Header.Set("Last-Event-ID", strconv.FormatUint(req.LastEventId, 10))
+	}
+
+	w := &eventStreamWriter{bridgeResponse: newBridgeResponse(), send: func(change *pb.EnvChange) error {
+		if err := stream.Send(change); err != nil {
+			// The client is gone, stop the stream handler.
+			cancel()
+			return err
+		}
+		return nil
+	}}
+	s.handler.ServeHTTP(w, request)
+
+	if w.status != http.StatusOK {
+		return w.decode(nil)
+	}
+	if err := stream.Context().Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
+
+// call serves the REST request through the handler and decodes the data of the response into out, if not nil.
+func (s *grpcService) call(ctx context.Context, method, path string, body, out interface{}) error {
+	request, err := newBridgeRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	w := newBridgeResponse()
+	s.handler.ServeHTTP(w, request)
+	return w.decode(out)
+}
+
+// newBridgeRequest creates the REST request of an RPC, carrying the token, user agent and address of the gRPC client.
+func newBridgeRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var payload io.Reader = http.NoBody
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		payload = bytes.NewReader(content)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, path, payload)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			request.Header.Set("Authorization", values[0])
+		}
+		if values := md.Get("user-agent"); len(values) > 0 {
+			request.Header.Set("User-Agent", values[0])
+		}
+		if values := md.Get("x-request-id"); len(values) > 0 {
+			request.Header.Set(requestIDHeader, values[0])
+		}
+		// Continue the trace of the caller.
+		if values := md.Get("traceparent"); len(values) > 0 {
+			request.Header.Set("Traceparent", values[0])
+		}
+		if values := md.Get("tracestate"); len(values) > 0 {
+			request.Header.Set("Tracestate", values[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		request.RemoteAddr = p.Addr.String()
+		// Client certificates authenticate the RPCs as they do the REST requests.
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			request.TLS = &info.State
+		}
+	}
+	return request, nil
+}
+
+// bridgeResponse buffers the REST response of an RPC.
+type bridgeResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBridgeResponse() *bridgeResponse {
+	return &bridgeResponse{header: http.Header{}}
+}
+
+func (w *bridgeResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *bridgeResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bridgeResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// decode decodes the data of the Response envelope into out, error responses are converted to gRPC status errors.
+func (w *bridgeResponse) decode(out interface{}) error {
+	var response struct {
+		Message    string          `json:"message"`
+		Data       json.RawMessage `json:"data"`
+		NextCursor string          `json:"next_cursor"`
+	}
+	if w.body.Len() > 0 {
+		if err := json.Unmarshal(w.body.Bytes(), &response); err != nil {
+			if w.status >= 400 {
+				// Errors of the router itself, e.g. unknown routes, aren't wrapped in the Response envelope.
+				return status.Error(grpcCode(w.status), strings.TrimSpace(w.body.String()))
+			}
+			return status.Errorf(codes.Internal, "cannot decode response: %v", err)
+		}
+	}
+
+	if w.status >= 400 {
+		return status.Error(grpcCode(w.status), response.Message)
+	}
+	if page, ok := out.(*bridgePage); ok {
+		page.nextCursor = response.NextCursor
+		out = page.data
+	}
+	if out == nil || len(response.Data) == 0 || string(response.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(response.Data, out); err != nil {
+		return status.Errorf(codes.Internal, "cannot decode response data: %v", err)
+	}
+	return nil
+}
+
+// bridgePage receives the data and the next cursor of a list response.
+type bridgePage struct {
+	data       interface{}
+	nextCursor string
+}
+
+// listQuery returns the query string of the list options and filters.
+func listQuery(options *pb.ListOptions, filters url.Values) string {
+	if options != nil {
+		if options.Sort != "" {
+			filters.Set("sort", options.Sort)
+		}
+		if options.Cursor != "" {
+			filters.Set("cursor", options.Cursor)
+		}
+		if options.Limit != 0 {
+			filters.Set("limit", strconv.Itoa(int(options.Limit)))
+		}
+		if options.UpdatedSince != nil {
+			filters.Set("updated_since", options.UpdatedSince.AsTime().Format(time.RFC3339))
+		}
+	}
+	for name, values := range filters {
+		if len(values) == 1 && (values[0] == "" || values[0] == "0") {
+			delete(filters, name)
+		}
+	}
+
+	if len(filters) == 0 {
+		return ""
+	}
+	return "?" + filters.Encode()
+}
+
+// eventStreamWriter forwards the Server-Sent Events written by the stream handler as env changes.
+// Error responses are buffered like any other response.
+type eventStreamWriter struct {
+	*bridgeResponse
+	send    func(*pb.EnvChange) error
+	mu      sync.Mutex
+	pending bytes.Buffer
+}
+
+func (w *eventStreamWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.status != http.StatusOK {
+		return w.body.Write(b)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending.Write(b)
+
+	for {
+		event, rest, found := bytes.Cut(w.pending.Bytes(), []byte("\n\n"))
+		if !found {
+			return len(b), nil
+		}
+		change, err := parseChangeEvent(event)
+		w.pending = *bytes.NewBuffer(append([]byte{}, rest...))
+		if err != nil {
+			return 0, err
+		}
+		if change == nil {
+			continue
+		}
+		if err := w.send(change); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush is a no-op, the events are sent as soon as they are written.
+func (w *eventStreamWriter) Flush() {}
+
+// parseChangeEvent parses a Server-Sent Event, nil is returned for comments.
+func parseChangeEvent(event []byte) (*pb.EnvChange, error) {
+	var eventType string
+	var data []byte
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			eventType = string(value)
+		case "data":
+			data = append(data, value...)
+		}
+	}
+
+	if eventType == "" {
+		return nil, nil
+	}
+	if eventType == streamResetEvent {
+		return &pb.EnvChange{Type: eventType}, nil
+	}
+
+	var change internal.EnvChange
+	if err := json.Unmarshal(data, &change); err != nil {
+		return nil, err
+	}
+	return &pb.EnvChange{
+		Id:         change.ID,
+		ProjectId:  int64(change.ProjectID),
+		Key:        change.Key,
+		Version:    int64(change.Version),
+		Type:       change.Type,
+		OccurredAt: timestamppb.New(change.OccurredAt),
+	}, nil
+}
+
+// grpcCode returns the gRPC code of an HTTP status.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusLocked:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	if httpStatus >= 500 {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+func userToPB(user models.User) *pb.User {
+	return &pb.User{
+		Id:            int64(user.ID),
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Email:         user.Email,
+		IsOwner:       user.IsOwner,
+		MfaEnabled:    user.MFAEnabled,
+		EmailVerified: user.EmailVerified,
+		IsAdmin:       user.IsAdmin,
+		Suspended:     user.Suspended,
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+		UpdatedAt:     timestamppb.New(user.UpdatedAt),
+	}
+}
+
+func projectToPB(project models.Project) *pb.Project {
+	return &pb.Project{
+		Id:              int64(project.ID),
+		Name:            project.Name,
+		EnvironmentName: project.EnvironmentName,
+		Owner:           int64(project.Owner),
+		RequireMfa:      project.RequireMFA,
+		CreatedAt:       timestamppb.New(project.CreatedAt),
+		UpdatedAt:       timestamppb.New(project.UpdatedAt),
+	}
+}
+
+func envKeyToPB(env models.EnvironmentKey) *pb.EnvKey {
+	return &pb.EnvKey{
+		Id:        int64(env.ID),
+		ProjectId: int64(env.ProjectID),
+		Key:       env.Key,
+		Value:     string(env.Value),
+		Version:   int64(env.Version),
+		CreatedAt: timestamppb.New(env.CreatedAt),
+		UpdatedAt: timestamppb.New(env.UpdatedAt),
+	}
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/Mahmoud-Emad/envserver/pb"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPC(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		sendJSONResponse(w, http.StatusOK, "Project deleted", nil, nil)
+	}).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token" {
+			sendJSONResponse(w, http.StatusUnauthorized, "Unauthorized", nil, nil)
+			return
+		}
+		if mux.Vars(r)["id"] != "1" {
+			sendJSONResponse(w, http.StatusNotFound, "Project not found", nil, nil)
+			return
+		}
+		sendJSONResponse(w, http.StatusOK, "Project found", models.Project{ID: 1, Name: "backend", EnvironmentName: "production"}, nil)
+	})
+	r.HandleFunc("/api/v1/projects", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "back", query.Get("name_prefix"))
+		assert.Equal(t, "-name", query.Get("sort"))
+		assert.Equal(t, "10", query.Get("limit"))
+		assert.False(t, query.Has("owner"))
+		sendPageResponse(w, "Projects found successfully", []models.Project{{ID: 1, Name: "backend"}}, "next")
+	})
+	r.HandleFunc("/api/v1/projects/{id}/env/stream", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Last-Event-ID") == "" {
+			sendJSONResponse(w, http.StatusLocked, "Two-factor authentication is required", nil, nil)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", streamResetEvent)
+		change := internal.EnvChange{ID: 7, ProjectID: 1, Key: "TOKEN", Version: 2, Type: "env.updated", OccurredAt: time.Now()}
+		assert.NoError(t, writeChangeEvent(w, change))
+		fmt.Fprint(w, ": heartbeat\n\n")
+		<-r.Context().Done()
+	})
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewGRPCServer(r)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewEnvserverClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "token")
+
+	t.Run("call the handler with the token", func(t *testing.T) {
+		project, err := client.GetProject(ctx, &pb.ProjectRequest{Id: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), project.Id)
+		assert.Equal(t, "backend", project.Name)
+		assert.Equal(t, "production", project.EnvironmentName)
+	})
+
+	t.Run("convert the error status", func(t *testing.T) {
+		_, err := client.GetProject(context.Background(), &pb.ProjectRequest{Id: 1})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = client.GetProject(ctx, &pb.ProjectRequest{Id: 2})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.Equal(t, "Project not found", status.Convert(err).Message())
+
+		_, err = client.ListEnv(ctx, &pb.ListEnvRequest{ProjectId: 1})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("list a page", func(t *testing.T) {
+		response, err := client.ListProjects(ctx, &pb.ListProjectsRequest{
+			NamePrefix: "back",
+			Options:    &pb.ListOptions{Sort: "-name", Limit: 10},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, response.Projects, 1)
+		assert.Equal(t, "backend", response.Projects[0].Name)
+		assert.Equal(t, "next", response.NextCursor)
+	})
+
+	t.Run("empty response", func(t *testing.T) {
+		response, err := client.DeleteProject(ctx, &pb.ProjectRequest{Id: 1})
+		assert.NoError(t, err)
+		assert.NotNil(t, response)
+	})
+
+	t.Run("watch the env changes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := client.WatchEnv(ctx, &pb.WatchEnvRequest{ProjectId: 1, LastEventId: 3})
+		assert.NoError(t, err)
+
+		change, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, streamResetEvent, change.Type)
+
+		change, err = stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(7), change.Id)
+		assert.Equal(t, int64(1), change.ProjectId)
+		assert.Equal(t, "TOKEN", change.Key)
+		assert.Equal(t, int64(2), change.Version)
+		assert.Equal(t, "env.updated", change.Type)
+
+		cancel()
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Canceled, status.Code(err))
+	})
+
+	t.Run("refused watch", func(t *testing.T) {
+		stream, err := client.WatchEnv(ctx, &pb.WatchEnvRequest{ProjectId: 1})
+		assert.NoError(t, err)
+
+		_, err = stream.Recv()
+		assert.False(t, errors.Is(err, io.EOF))
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/rs/zerolog/log"
+)
+
+// readinessTimeout bounds the checks of a readiness probe, so that a hanging database fails the probe instead of blocking it.
+const readinessTimeout = 2 * time.Second
+
+var shuttingDownError = errors.New("the server is shutting down")
+
+// healthReport is the response of the health endpoints, with the status of every checked component.
+type healthReport struct {
+	Status     string                              `json:"status"`
+	Components map[string]internal.ComponentHealth `json:"components,omitempty"`
+}
+
+// livenessHandler reports that the process is serving requests, it checks nothing else to stay cheap.
+func (a *App) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, healthReport{Status: internal.HealthOK})
+}
+
+// readinessHandler reports whether the server can handle requests: the database is reachable, its schema is migrated
+// and the server isn't shutting down. The errors of the failing checks are only logged.
+func (a *App) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	var serverErr error
+	if a.shuttingDown.Load() {
+		serverErr = shuttingDownError
+	}
+
+	checks := map[string]error{
+		"server":     serverErr,
+		"database":   a.DB.Ping(ctx),
+		"migrations": a.checkMigrations(ctx),
+	}
+
+	report := healthReport{Status: internal.HealthOK, Components: map[string]internal.ComponentHealth{}}
+	for component, err := range checks {
+		if err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Str("component", component).Msg("Readiness check failed")
+			report.Status = internal.HealthFailing
+		}
+		report.Components[component] = internal.NewComponentHealth(err)
+	}
+	writeHealthReport(w, report)
+}
+
+// checkMigrations returns an error listing the pending migrations.
+// The schema can't lose columns once migrated, so it's only checked until it's complete.
+func (a *App) checkMigrations(ctx context.Context) error {
+	if a.migrated.Load() {
+		return nil
+	}
+
+	pending, err := a.DB.PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations of %s", strings.Join(pending, ", "))
+	}
+	a.migrated.Store(true)
+	return nil
+}
+
+// writeHealthReport sends the report, with a 503 status if it's failing.
+func writeHealthReport(w http.ResponseWriter, report healthReport) {
+	status := http.StatusOK
+	if report.Status != internal.HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
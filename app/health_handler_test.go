@@ -0,0 +1,51 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	app := &App{}
+	handler := app.Handler()
+
+	probe := func(t *testing.T, path string) (int, healthReport) {
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, path, nil))
+		// The errors of the checks are never sent to the unauthenticated clients.
+		assert.NotContains(t, responseRecorder.Body.String(), "error")
+
+		var report healthReport
+		assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&report))
+		return responseRecorder.Code, report
+	}
+
+	t.Run("Test liveness", func(t *testing.T) {
+		status, report := probe(t, "/healthz")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, internal.HealthOK, report.Status)
+	})
+
+	t.Run("Test readiness without database", func(t *testing.T) {
+		status, report := probe(t, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, internal.HealthFailing, report.Status)
+		assert.Equal(t, internal.HealthOK, report.Components["server"].Status)
+		assert.Equal(t, internal.HealthFailing, report.Components["database"].Status)
+		assert.Equal(t, internal.HealthFailing, report.Components["migrations"].Status)
+	})
+
+	t.Run("Test readiness while shutting down", func(t *testing.T) {
+		app.shuttingDown.Store(true)
+		defer app.shuttingDown.Store(false)
+
+		status, report := probe(t, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, internal.HealthFailing, report.Components["server"].Status)
+	})
+}
@@ -0,0 +1,15 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// jwksHandler serves the public signing keys as a standard JSON Web Key Set.
+// It's not wrapped in the Response envelope so that JWT libraries of other services can consume it directly.
+func (a *App) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(a.Keys.JWKS())
+}
@@ -1,44 +1,158 @@
 package app
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
 
+	internal "github.com/Mahmoud-Emad/envserver/internal"
 	models "github.com/Mahmoud-Emad/envserver/models"
 	"github.com/dgrijalva/jwt-go"
 )
 
-func (a *App) GenerateJwtToken(payload map[string]interface{}, JWTSecretKey string) (string, error) {
-	// Generate a JWT token with user data as the payload
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims(payload))
+// mfaChallengeTTL is the lifetime of the token issued between the password and the TOTP signin steps.
+const mfaChallengeTTL = 5 * time.Minute
 
-	tokenString, err := token.SignedString([]byte(JWTSecretKey))
+// GenerateJwtToken signs the payload with the active key of the app key set.
+func (a *App) GenerateJwtToken(payload map[string]interface{}) (string, error) {
+	// Generate a JWT token with user data as the payload
+	tokenString, err := a.Keys.Sign(jwt.MapClaims(payload))
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-func (a *App) VerifyAndDecodeJwtToken(tokenString, JWTSecretKey string) (models.User, error) {
-	// Parse the token and extract the payload.
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(JWTSecretKey), nil
+// GenerateMFAChallengeToken generates a short-lived token proving that the user passed the password step of the signin.
+// It can only be exchanged for an access token at the mfa signin endpoint.
+func (a *App) GenerateMFAChallengeToken(user models.User) (string, error) {
+	payload := map[string]interface{}{
+		"id":            user.ID,
+		"mfa_challenge": true,
+		"exp":           time.Now().Add(mfaChallengeTTL).Unix(),
+	}
+	return a.GenerateJwtToken(payload)
+}
+
+// VerifyAndDecodeJwtToken validates an access token and returns its user.
+func (a *App) VerifyAndDecodeJwtToken(tokenString string) (models.User, error) {
+	payload, err := a.parseJwtToken(tokenString)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	// MFA challenge and emailed action tokens are not access tokens.
+	if challenge, _ := payload["mfa_challenge"].(bool); challenge {
+		return models.User{}, errors.New("mfa challenge token cannot be used as an access token")
+	}
+	if _, ok := payload["purpose"]; ok {
+		return models.User{}, errors.New("action token cannot be used as an access token")
+	}
+
+	user, err := a.getTokenUser(payload)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if user.Suspended {
+		return models.User{}, internal.UserSuspendedError
+	}
+	return user, nil
+}
+
+// VerifyMFAChallengeToken validates an mfa challenge token and returns its user.
+func (a *App) VerifyMFAChallengeToken(tokenString string) (models.User, error) {
+	payload, err := a.parseJwtToken(tokenString)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if challenge, _ := payload["mfa_challenge"].(bool); !challenge {
+		return models.User{}, errors.New("not an mfa challenge token")
+	}
+
+	return a.getTokenUser(payload)
+}
+
+// GenerateActionToken generates a signed single-use token for the given purpose, e.g. a password reset.
+// The token identifier is stored in the database so it can be consumed only once with VerifyActionToken.
+func (a *App) GenerateActionToken(user models.User, purpose string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	jti := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(ttl)
+
+	err := a.DB.CreateActionToken(&models.ActionToken{
+		JTI:       jti,
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: expiresAt,
 	})
+	if err != nil {
+		return "", err
+	}
+
+	payload := map[string]interface{}{
+		"id":      user.ID,
+		"purpose": purpose,
+		"jti":     jti,
+		"exp":     expiresAt.Unix(),
+	}
+	return a.GenerateJwtToken(payload)
+}
+
+// VerifyActionToken validates the signature and purpose of an action token, consumes it and returns its user.
+func (a *App) VerifyActionToken(tokenString, purpose string) (models.User, error) {
+	payload, err := a.parseJwtToken(tokenString)
+	if err != nil {
+		return models.User{}, internal.InvalidActionTokenError
+	}
+
+	tokenPurpose, _ := payload["purpose"].(string)
+	jti, _ := payload["jti"].(string)
+	if tokenPurpose != purpose || jti == "" {
+		return models.User{}, internal.InvalidActionTokenError
+	}
 
+	user, err := a.getTokenUser(payload)
 	if err != nil {
 		return models.User{}, err
 	}
 
+	token, err := a.DB.UseActionToken(jti, purpose)
+	if err != nil || token.UserID != user.ID {
+		return models.User{}, internal.InvalidActionTokenError
+	}
+	return user, nil
+}
+
+// parseJwtToken parses the token, validates its signature against the app key set and returns its claims.
+func (a *App) parseJwtToken(tokenString string) (jwt.MapClaims, error) {
+	// Parse the token and extract the payload.
+	token, err := jwt.Parse(tokenString, a.Keys.Keyfunc)
+
+	if err != nil {
+		return nil, err
+	}
+
 	if !token.Valid {
-		return models.User{}, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	// Extract the payload data
 	payload, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return models.User{}, errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
 	}
+	return payload, nil
+}
 
+// getTokenUser loads the user referenced by the id claim of the token.
+func (a *App) getTokenUser(payload jwt.MapClaims) (models.User, error) {
 	idFloat, ok := payload["id"].(float64)
 	if !ok {
 		return models.User{}, fmt.Errorf("id %v is an invalid id field in token", payload["id"])
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// requestIDHeader carries the id of a request in both directions, it's generated if the client didn't send a valid one.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern restricts the ids sent by the clients, so that they can't forge log lines.
+var requestIDPattern = regexp.MustCompile(`^[\w.:-]{1,128}$`)
+
+// probeRoutes are polled by the orchestrator, their successful requests are only logged at debug level.
+var probeRoutes = map[string]bool{"/healthz": true, "/readyz": true}
+
+func initZerolog() {
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = internal.NewLogger(internal.LogConfig{}, os.Stderr)
+	// log.Ctx falls back to the global logger outside of the requests.
+	zerolog.DefaultContextLogger = &log.Logger
+}
+
+// configureLogger sets the level and the format of the logs from the config.
+func configureLogger(config internal.LogConfig) {
+	log.Logger = internal.NewLogger(config, os.Stderr)
+	zerolog.SetGlobalLevel(config.LevelOrDefault())
+}
+
+// logRequests assigns an id to every request, sends it back in the X-Request-ID header, and logs one line per request
+// with its id, user, route template, status, latency and response size.
+// The request context carries a logger with the request id, handlers log through it with log.Ctx.
+func (a *App) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		rw.Header().Set(requestIDHeader, requestID)
+
+		logger := log.With().Str("request_id", requestID).Logger()
+		r = r.WithContext(logger.WithContext(r.Context()))
+		r, info := withAuditInfo(r)
+		w := &statusWriter{ResponseWriter: rw}
+		started := time.Now()
+
+		next.ServeHTTP(w, r)
+
+		status := w.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := routeTemplate(r)
+		event := logger.Info()
+		switch {
+		case status >= http.StatusInternalServerError:
+			event = logger.Error()
+		case probeRoutes[route] && status == http.StatusOK:
+			event = logger.Debug()
+		}
+		if info.ActorID != 0 {
+			event = event.Int("user_id", info.ActorID)
+		}
+		event.
+			Str("method", r.Method).
+			Str("route", route).
+			Int("status", status).
+			Dur("latency_ms", time.Since(started)).
+			Int("bytes", w.bytes).
+			Err(w.err).
+			Msg("Request handled")
+	})
+}
+
+// newRequestID returns a random request id.
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogRequests(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.Logger
+	log.Logger = internal.NewLogger(internal.LogConfig{Format: internal.LogFormatJSON}, &out)
+	t.Cleanup(func() { log.Logger = logger })
+
+	app := &App{}
+	router := mux.NewRouter()
+	router.Use(app.logRequests)
+	router.HandleFunc("/api/v1/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		setAuditActor(r, 7)
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid env", nil, errors.New("password=omda"))
+	})
+
+	serve := func(requestID string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		out.Reset()
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/projects/5", nil)
+		if requestID != "" {
+			request.Header.Set(requestIDHeader, requestID)
+		}
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &line))
+		return responseRecorder, line
+	}
+
+	t.Run("Test request log line", func(t *testing.T) {
+		responseRecorder, line := serve("deploy-42")
+		assert.Equal(t, "deploy-42", responseRecorder.Header().Get(requestIDHeader))
+
+		assert.Equal(t, "deploy-42", line["request_id"])
+		assert.Equal(t, float64(7), line["user_id"])
+		assert.Equal(t, "/api/v1/projects/{id}", line["route"])
+		assert.Equal(t, float64(http.StatusBadRequest), line["status"])
+		assert.Equal(t, float64(responseRecorder.Body.Len()), line["bytes"])
+		assert.Contains(t, line, "latency_ms")
+		assert.Equal(t, "password="+internal.RedactedValue, line["error"])
+	})
+
+	t.Run("Test request id in error bodies", func(t *testing.T) {
+		responseRecorder, _ := serve("deploy-42")
+
+		var response Response
+		assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+		assert.Equal(t, "deploy-42", response.RequestID)
+	})
+
+	t.Run("Test generated request id", func(t *testing.T) {
+		responseRecorder, line := serve("forged\nline")
+		requestID := responseRecorder.Header().Get(requestIDHeader)
+		assert.Len(t, requestID, 32)
+		assert.Equal(t, requestID, line["request_id"])
+	})
+}
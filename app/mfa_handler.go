@@ -0,0 +1,282 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// mfaIssuer is the issuer name shown by authenticator apps.
+	mfaIssuer = "envserver"
+	// recoveryCodesCount is the number of recovery codes generated when MFA is enabled.
+	recoveryCodesCount = 10
+	// productionEnvironment is the project environment name whose keys can require MFA.
+	productionEnvironment = "production"
+)
+
+// enrollMFAHandler generates a new TOTP secret for the requested user.
+// The secret is stored encrypted and stays inactive until it's confirmed with verifyMFAHandler.
+func (a *App) enrollMFAHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return
+	}
+
+	if user.MFAEnabled {
+		sendJSONResponse(w, http.StatusBadRequest, "Failed to enroll two-factor authentication", nil, internal.MFAAlreadyEnabledError)
+		return
+	}
+
+	secret, err := internal.GenerateTOTPSecret()
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to generate TOTP secret", nil, err)
+		return
+	}
+
+	encryptedSecret, err := internal.EncryptAES([]byte(secret), a.Config.Server.EncryptionKey)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to encrypt TOTP secret", nil, err)
+		return
+	}
+
+	err = a.requestDB(r).UpdateUserMFA(user.ID, false, encryptedSecret)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to save TOTP secret", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "Two-factor authentication enrollment started", map[string]string{
+		"secret": secret,
+		"uri":    internal.TOTPURI(mfaIssuer, user.Email, secret),
+	}, nil)
+}
+
+// verifyMFAHandler confirms the enrolled TOTP secret with a code from the authenticator app and enables MFA.
+// It returns the recovery codes in plain text, they are only stored hashed and can't be retrieved again.
+func (a *App) verifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return
+	}
+
+	var fields internal.MFACodeInputs
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	if err := fields.Validate(); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Please ensure that all mandatory fields have been filled out.", nil, err)
+		return
+	}
+
+	if user.MFAEnabled {
+		sendJSONResponse(w, http.StatusBadRequest, "Failed to verify two-factor authentication", nil, internal.MFAAlreadyEnabledError)
+		return
+	}
+
+	secret, err := a.getUserTOTPSecret(user)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Failed to verify two-factor authentication", nil, err)
+		return
+	}
+
+	step, ok := internal.ValidateTOTPCode(secret, fields.Code, time.Now(), user.LastTOTPStep)
+	if !ok {
+		sendJSONResponse(w, http.StatusUnauthorized, "Failed to verify two-factor authentication", nil, internal.InvalidMFACodeError)
+		return
+	}
+	if err := a.requestDB(r).UseTOTPStep(user.ID, step); err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "Failed to verify two-factor authentication", nil, err)
+		return
+	}
+
+	codes, err := a.resetRecoveryCodes(user)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to generate recovery codes", nil, err)
+		return
+	}
+
+	err = a.requestDB(r).UpdateUserMFA(user.ID, true, user.MFASecret)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to enable two-factor authentication", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "Two-factor authentication enabled", map[string]interface{}{
+		"recovery_codes": codes,
+	}, nil)
+}
+
+// disableMFAHandler disables two-factor authentication after checking a TOTP or recovery code.
+func (a *App) disableMFAHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return
+	}
+
+	var fields internal.MFACodeInputs
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	if err := fields.Validate(); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Please ensure that all mandatory fields have been filled out.", nil, err)
+		return
+	}
+
+	if !user.MFAEnabled {
+		sendJSONResponse(w, http.StatusBadRequest, "Failed to disable two-factor authentication", nil, internal.MFANotEnrolledError)
+		return
+	}
+
+	if err := a.checkMFACode(user, fields.Code); err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "Failed to disable two-factor authentication", nil, err)
+		return
+	}
+
+	if err := a.requestDB(r).UpdateUserMFA(user.ID, false, nil); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to disable two-factor authentication", nil, err)
+		return
+	}
+
+	if err := a.requestDB(r).ReplaceRecoveryCodes(user.ID, nil); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to delete recovery codes", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "Two-factor authentication disabled", nil, nil)
+}
+
+// signinMFAHandler is the second signin step, it exchanges an mfa challenge token and a TOTP or recovery code for an access token.
+func (a *App) signinMFAHandler(w http.ResponseWriter, r *http.Request) {
+	if lockedUntil, locked := a.SigninThrottler.LockedUntil(clientIP(r)); locked {
+		a.sendLockedResponse(w, http.StatusTooManyRequests, lockedUntil, internal.TooManyFailedSigninsError)
+		return
+	}
+
+	var fields internal.MFASigninInputs
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	if err := fields.Validate(); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Please ensure that all mandatory fields have been filled out.", nil, err)
+		return
+	}
+
+	user, err := a.VerifyMFAChallengeToken(fields.MFAToken)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "Invalid or expired MFA token", nil, err)
+		return
+	}
+
+	setAuditActor(r, user.ID)
+
+	if user.Suspended {
+		sendJSONResponse(w, http.StatusForbidden, "Failed to sign in", nil, internal.UserSuspendedError)
+		return
+	}
+
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		a.sendLockedResponse(w, http.StatusLocked, *user.LockedUntil, internal.AccountLockedError)
+		return
+	}
+
+	if err := a.checkMFACode(user, fields.Code); err != nil {
+		a.recordFailedSignin(r, user.Email, &user)
+		sendJSONResponse(w, http.StatusUnauthorized, "Invalid authentication code", nil, err)
+		return
+	}
+
+	a.completeSignin(w, r, user)
+}
+
+// getUserTOTPSecret decrypts the TOTP secret of the user.
+// The secrets enrolled by older versions were encrypted with the JWT secret, they're encrypted again with the encryption key
+// once they're read, so that they survive a rotation of the JWT secret.
+func (a *App) getUserTOTPSecret(user models.User) (string, error) {
+	if len(user.MFASecret) == 0 {
+		return "", internal.MFANotEnrolledError
+	}
+
+	secret, err := internal.DecryptAES(user.MFASecret, a.Config.Server.EncryptionKey)
+	if err == nil {
+		return string(secret), nil
+	}
+
+	secret, legacyErr := internal.DecryptAES(user.MFASecret, a.Config.Server.JWTSecretKey)
+	if legacyErr != nil {
+		return "", err
+	}
+	if encryptedSecret, err := internal.EncryptAES(secret, a.Config.Server.EncryptionKey); err == nil {
+		if err := a.DB.UpdateUserMFA(user.ID, user.MFAEnabled, encryptedSecret); err != nil {
+			log.Error().Err(err).Int("user_id", user.ID).Msg("Failed to encrypt the TOTP secret with the encryption key")
+		}
+	}
+	return string(secret), nil
+}
+
+// checkMFACode accepts either a valid TOTP code or an unused recovery code, which is consumed.
+func (a *App) checkMFACode(user models.User, code string) error {
+	secret, err := a.getUserTOTPSecret(user)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := internal.ValidateTOTPCode(secret, code, time.Now(), user.LastTOTPStep); ok {
+		return a.DB.UseTOTPStep(user.ID, step)
+	}
+
+	codes, err := a.DB.GetUnusedRecoveryCodes(user.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, recoveryCode := range codes {
+		if internal.CheckPasswordHash(code, recoveryCode.HashedCode) {
+			return a.DB.MarkRecoveryCodeUsed(recoveryCode.ID)
+		}
+	}
+	return internal.InvalidMFACodeError
+}
+
+// resetRecoveryCodes generates new recovery codes for the user, replacing the stored ones.
+func (a *App) resetRecoveryCodes(user models.User) ([]string, error) {
+	codes, err := internal.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedCodes := make([][]byte, 0, len(codes))
+	for _, code := range codes {
+		hashed, err := internal.HashPassword(code)
+		if err != nil {
+			return nil, err
+		}
+		hashedCodes = append(hashedCodes, hashed)
+	}
+
+	if err := a.DB.ReplaceRecoveryCodes(user.ID, hashedCodes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkProjectMFA returns an error if the project requires MFA to read its production keys and the user didn't enable it.
+func checkProjectMFA(user models.User, project models.Project) error {
+	if project.RequireMFA && project.EnvironmentName == productionEnvironment && !user.MFAEnabled {
+		return internal.MFARequiredError
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package app
+
+import (
+	"testing"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the TOTP secrets are encrypted with the encryption key, so that rotating the JWT secret doesn't lock the MFA users out.
+func TestGetUserTOTPSecret(t *testing.T) {
+	app := &App{Config: internal.Config{Server: internal.ServerConfig{JWTSecretKey: "xyz", EncryptionKey: "abc"}}}
+
+	encryptedSecret, err := internal.EncryptAES([]byte("JBSWY3DPEHPK3PXP"), "abc")
+	assert.NoError(t, err)
+	secret, err := app.getUserTOTPSecret(models.User{MFASecret: encryptedSecret})
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+
+	app.Config.Server.JWTSecretKey = "rotated"
+	secret, err = app.getUserTOTPSecret(models.User{MFASecret: encryptedSecret})
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+
+	_, err = app.getUserTOTPSecret(models.User{})
+	assert.ErrorIs(t, err, internal.MFANotEnrolledError)
+}
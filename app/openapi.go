@@ -0,0 +1,407 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const openAPIVersion = "3.0.3"
+
+// apiOperation documents a route, the request body and response data are described by example values of their types.
+type apiOperation struct {
+	Summary     string
+	Tag         string
+	Protected   bool
+	Status      int
+	Request     interface{}
+	Response    interface{}
+	Query       []apiParameter
+	EventStream bool
+	// Raw is set for the routes whose response isn't wrapped in the Response envelope.
+	Raw bool
+}
+
+// apiParameter documents a query parameter.
+type apiParameter struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// Documented response data of the handlers returning ad hoc maps.
+type (
+	signinResponse struct {
+		Token       string `json:"token,omitempty"`
+		MFARequired bool   `json:"mfa_required,omitempty"`
+		MFAToken    string `json:"mfa_token,omitempty"`
+	}
+	tokenResponse struct {
+		Token string `json:"token"`
+	}
+	mfaEnrollmentResponse struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}
+	recoveryCodesResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	createdWebhookResponse struct {
+		Webhook models.Webhook `json:"webhook"`
+		Secret  string         `json:"secret"`
+	}
+)
+
+// apiOperations documents every route registered in registerHandlers, keyed by method and path template.
+var apiOperations = map[string]apiOperation{
+	"GET /.well-known/jwks.json": {Summary: "Public keys verifying the issued tokens", Tag: "auth", Response: internal.JWKS{}, Raw: true},
+	"GET /api/v1/openapi.json":   {Summary: "This OpenAPI document", Tag: "meta", Raw: true},
+	"GET /healthz":               {Summary: "Liveness probe", Tag: "meta", Response: healthReport{}, Raw: true},
+	"GET /readyz":                {Summary: "Readiness probe of the database, migrations and shutdown, 503 if a component is failing", Tag: "meta", Response: healthReport{}, Raw: true},
+
+	"POST /api/v1/auth/signup":          {Summary: "Create a user", Tag: "auth", Status: http.StatusCreated, Request: internal.SignUpInputs{}, Response: models.User{}},
+	"POST /api/v1/auth/signin":          {Summary: "Sign in, returns an access token or a two-factor authentication challenge", Tag: "auth", Request: internal.SigninInputs{}, Response: signinResponse{}},
+	"POST /api/v1/auth/signin/mfa":      {Summary: "Complete a signin with a TOTP or recovery code", Tag: "auth", Request: internal.MFASigninInputs{}, Response: tokenResponse{}},
+	"POST /api/v1/auth/password/forgot": {Summary: "Send a password reset email", Tag: "auth", Request: internal.EmailInputs{}},
+	"POST /api/v1/auth/password/reset":  {Summary: "Reset a password with a password reset token", Tag: "auth", Request: internal.PasswordResetInputs{}},
+	"POST /api/v1/auth/email/verify":    {Summary: "Verify an email address with a verification token", Tag: "auth", Request: internal.TokenInputs{}},
+	"POST /api/v1/auth/email/resend":    {Summary: "Send a new verification email", Tag: "auth", Request: internal.EmailInputs{}},
+	"POST /api/v1/auth/mfa/enroll":      {Summary: "Start the two-factor authentication enrollment", Tag: "auth", Protected: true, Response: mfaEnrollmentResponse{}},
+	"POST /api/v1/auth/mfa/verify":      {Summary: "Enable two-factor authentication, returns the recovery codes", Tag: "auth", Protected: true, Request: internal.MFACodeInputs{}, Response: recoveryCodesResponse{}},
+	"POST /api/v1/auth/mfa/disable":     {Summary: "Disable two-factor authentication", Tag: "auth", Protected: true, Request: internal.MFACodeInputs{}},
+
+	"GET /api/v1/users":         {Summary: "List the users, administrators only", Tag: "users", Protected: true, Response: []models.User{}, Query: userListParameters},
+	"GET /api/v1/users/me":      {Summary: "Get the authenticated user", Tag: "users", Protected: true, Response: models.User{}},
+	"GET /api/v1/users/{id}":    {Summary: "Get a user, the user itself or an administrator only", Tag: "users", Protected: true, Response: models.User{}},
+	"DELETE /api/v1/users/{id}": {Summary: "Delete a user, the user itself or an administrator only", Tag: "users", Protected: true, Status: http.StatusNoContent},
+
+	"GET /api/v1/admin/users":                 {Summary: "List the users", Tag: "admin", Protected: true, Response: []models.User{}, Query: userListParameters},
+	"DELETE /api/v1/admin/users/{id}":         {Summary: "Delete a user", Tag: "admin", Protected: true, Status: http.StatusNoContent},
+	"POST /api/v1/admin/users/{id}/suspend":   {Summary: "Suspend a user", Tag: "admin", Protected: true, Response: models.User{}},
+	"POST /api/v1/admin/users/{id}/unsuspend": {Summary: "Unsuspend a user", Tag: "admin", Protected: true, Response: models.User{}},
+	"POST /api/v1/admin/users/{id}/unlock":    {Summary: "Unlock a user locked after failed signins", Tag: "admin", Protected: true},
+	"GET /api/v1/admin/config":                {Summary: "Get the effective config, with the secrets masked", Tag: "admin", Protected: true, Response: map[string]interface{}{}},
+	"GET /api/v1/admin/audit/sinks":           {Summary: "Get the stats of the audit sinks", Tag: "admin", Protected: true, Response: []internal.AuditSinkStats{}},
+	"GET /api/v1/admin/projects":              {Summary: "List the projects", Tag: "admin", Protected: true, Response: []models.Project{}, Query: projectListParameters},
+	"DELETE /api/v1/admin/projects/{id}":      {Summary: "Delete a project", Tag: "admin", Protected: true, Status: http.StatusNoContent},
+	"GET /api/v1/audit": {Summary: "Query the audit log", Tag: "admin", Protected: true, Response: []models.AuditEvent{}, Query: []apiParameter{
+		{Name: "actor_id", Type: "integer", Description: "Id of the user who performed the action"},
+		{Name: "project_id", Type: "integer", Description: "Id of the targeted project"},
+		{Name: "action", Type: "string", Description: "Action, e.g. \"GET /api/v1/projects/{id}/env\""},
+		{Name: "key_name", Type: "string", Description: "Name of the targeted env key"},
+		{Name: "result", Type: "string", Description: "success or failure"},
+		{Name: "since", Type: "string", Description: "RFC 3339 time of the oldest event"},
+		{Name: "until", Type: "string", Description: "RFC 3339 time of the newest event"},
+		{Name: "limit", Type: "integer", Description: "Maximum number of events"},
+	}},
+
+	"GET /api/v1/projects":         {Summary: "List the projects", Tag: "projects", Protected: true, Response: []models.Project{}, Query: projectListParameters},
+	"POST /api/v1/projects":        {Summary: "Create a project", Tag: "projects", Protected: true, Status: http.StatusCreated, Request: internal.ProjectInputs{}, Response: models.Project{}},
+	"GET /api/v1/projects/{id}":    {Summary: "Get a project", Tag: "projects", Protected: true, Response: models.Project{}},
+	"PUT /api/v1/projects/{id}":    {Summary: "Update a project", Tag: "projects", Protected: true, Request: models.Project{}, Response: models.Project{}},
+	"DELETE /api/v1/projects/{id}": {Summary: "Delete a project", Tag: "projects", Protected: true, Status: http.StatusNoContent},
+
+	"GET /api/v1/projects/{id}/webhooks":                                                {Summary: "List the project webhooks", Tag: "webhooks", Protected: true, Response: []models.Webhook{}},
+	"POST /api/v1/projects/{id}/webhooks":                                               {Summary: "Subscribe a webhook to the project events, returns its signing secret", Tag: "webhooks", Protected: true, Status: http.StatusCreated, Request: internal.WebhookInputs{}, Response: createdWebhookResponse{}},
+	"DELETE /api/v1/projects/{id}/webhooks/{webhookID}":                                 {Summary: "Delete a webhook", Tag: "webhooks", Protected: true, Status: http.StatusNoContent},
+	"GET /api/v1/projects/{id}/webhooks/{webhookID}/deliveries":                         {Summary: "List the latest deliveries of a webhook", Tag: "webhooks", Protected: true, Response: []models.WebhookDelivery{}},
+	"POST /api/v1/projects/{id}/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver": {Summary: "Send a delivery again", Tag: "webhooks", Protected: true, Status: http.StatusAccepted, Response: models.WebhookDelivery{}},
+
+	"GET /api/v1/projects/{id}/env":                   {Summary: "List the project env keys with their values", Tag: "env", Protected: true, Response: []models.EnvironmentKey{}, Query: envListParameters},
+	"POST /api/v1/projects/{id}/env":                  {Summary: "Create an env key", Tag: "env", Protected: true, Status: http.StatusCreated, Request: internal.EnvironmentKeyInputs{}, Response: models.EnvironmentKey{}},
+	"PATCH /api/v1/projects/{id}/env":                 {Summary: "Apply a batch of set and delete operations to the env keys in a single transaction", Tag: "env", Protected: true, Request: internal.EnvBatchInputs{}, Response: []internal.EnvOperationResult{}},
+	"GET /api/v1/projects/{id}/env/stream":            {Summary: "Stream the changes of the project env keys as Server-Sent Events, resumed with the Last-Event-ID header", Tag: "env", Protected: true, EventStream: true},
+	"GET /api/v1/projects/{projectID}/env/{envID}":    {Summary: "Get an env key with its value", Tag: "env", Protected: true, Response: models.EnvironmentKey{}},
+	"PUT /api/v1/projects/{projectID}/env/{envID}":    {Summary: "Update an env key, its version is incremented", Tag: "env", Protected: true, Request: internal.EnvironmentKeyInputs{}, Response: models.EnvironmentKey{}},
+	"DELETE /api/v1/projects/{projectID}/env/{envID}": {Summary: "Delete an env key", Tag: "env", Protected: true, Status: http.StatusNoContent},
+}
+
+// openAPIHandler serves the OpenAPI document of the routes of the router, generated on the first request.
+func openAPIHandler(router *mux.Router) http.HandlerFunc {
+	var once sync.Once
+	var document []byte
+	var err error
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			document, err = generateOpenAPI(router)
+		})
+		if err != nil {
+			sendJSONResponse(w, http.StatusInternalServerError, "Failed to generate the OpenAPI document", nil, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(document)
+	}
+}
+
+// routeOperations returns the "METHOD template" keys of the routes registered in the router, OPTIONS excluded.
+func routeOperations(router *mux.Router) ([]string, error) {
+	var keys []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if method != http.MethodOptions {
+				keys = append(keys, method+" "+template)
+			}
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+// generateOpenAPI builds the OpenAPI document of the routes registered in the router from apiOperations.
+func generateOpenAPI(router *mux.Router) ([]byte, error) {
+	keys, err := routeOperations(router)
+	if err != nil {
+		return nil, err
+	}
+
+	generator := &schemaGenerator{components: map[string]interface{}{}}
+	generator.components["Response"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"message": map[string]interface{}{"type": "string"},
+			"status":  map[string]interface{}{"type": "integer"},
+			"data":    map[string]interface{}{},
+		},
+	}
+
+	paths := map[string]map[string]interface{}{}
+	for _, key := range keys {
+		method, template, _ := strings.Cut(key, " ")
+		operation, ok := apiOperations[key]
+		if !ok {
+			return nil, fmt.Errorf("the route %s is not documented in apiOperations", key)
+		}
+		if paths[template] == nil {
+			paths[template] = map[string]interface{}{}
+		}
+		paths[template][strings.ToLower(method)] = generator.operation(template, operation)
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "envserver",
+			"description": "Store and share the environment variables of your projects.",
+			"version":     "v1",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": generator.components,
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "The access token returned by the signin",
+				},
+			},
+		},
+	}, "", "  ")
+}
+
+var pathParameterPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+func (g *schemaGenerator) operation(template string, operation apiOperation) map[string]interface{} {
+	status := operation.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	var parameters []interface{}
+	for _, match := range pathParameterPattern.FindAllStringSubmatch(template, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name": match[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "integer"},
+		})
+	}
+	for _, parameter := range operation.Query {
+		parameters = append(parameters, map[string]interface{}{
+			"name": parameter.Name, "in": "query", "description": parameter.Description, "schema": map[string]interface{}{"type": parameter.Type},
+		})
+	}
+
+	var content map[string]interface{}
+	switch {
+	case operation.EventStream:
+		content = map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+	case operation.Raw:
+		content = map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(operation.Response)}}
+	default:
+		content = map[string]interface{}{"application/json": map[string]interface{}{"schema": g.envelope(operation.Response)}}
+	}
+
+	responses := map[string]interface{}{
+		fmt.Sprint(status): map[string]interface{}{"description": http.StatusText(status), "content": content},
+	}
+	if !operation.Raw {
+		responses["default"] = map[string]interface{}{
+			"description": "Error",
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaRef("Response")}},
+		}
+	}
+
+	result := map[string]interface{}{
+		"summary":   operation.Summary,
+		"tags":      []string{operation.Tag},
+		"responses": responses,
+	}
+	if len(parameters) > 0 {
+		result["parameters"] = parameters
+	}
+	if operation.Request != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(operation.Request)}},
+		}
+	}
+	if operation.Protected {
+		result["security"] = []interface{}{map[string]interface{}{"token": []string{}}}
+	}
+	return result
+}
+
+// schemaGenerator generates the JSON schemas of Go types, named structs are added to the components.
+type schemaGenerator struct {
+	components map[string]interface{}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// envelope returns the schema of the Response envelope whose data has the type of the value.
+func (g *schemaGenerator) envelope(data interface{}) map[string]interface{} {
+	if data == nil {
+		return schemaRef("Response")
+	}
+	return map[string]interface{}{
+		"allOf": []interface{}{
+			schemaRef("Response"),
+			map[string]interface{}{"type": "object", "properties": map[string]interface{}{"data": g.schema(data)}},
+		},
+	}
+}
+
+func (g *schemaGenerator) schema(value interface{}) map[string]interface{} {
+	if value == nil {
+		return map[string]interface{}{"type": "object"}
+	}
+	return g.typeSchema(reflect.TypeOf(value))
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case deletedAtType:
+		return map[string]interface{}{"type": "string", "format": "date-time", "nullable": true}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.typeSchema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.components[t.Name()]; !ok {
+			// Reserve the name first, so that recursive types end.
+			g.components[t.Name()] = nil
+			g.components[t.Name()] = g.structSchema(t)
+		}
+		return schemaRef(t.Name())
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the schema of the JSON encoding of a struct, the fields of embedded structs are promoted.
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	g.addFields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]interface{}) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.typeSchema(field.Type)
+	}
+
+	// Fields of the struct shadow the promoted fields with the same name.
+	for _, embeddedType := range embedded {
+		promoted := map[string]interface{}{}
+		g.addFields(embeddedType, promoted)
+		for name, schema := range promoted {
+			if _, ok := properties[name]; !ok {
+				properties[name] = schema
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPI(t *testing.T) {
+	app := &App{}
+	router := app.registerHandlers().(*mux.Router)
+
+	t.Run("every route is documented", func(t *testing.T) {
+		routes, err := routeOperations(router)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, routes)
+
+		registered := map[string]bool{}
+		for _, route := range routes {
+			registered[route] = true
+			_, documented := apiOperations[route]
+			assert.True(t, documented, "the route %s is registered without an entry in apiOperations", route)
+		}
+
+		for route := range apiOperations {
+			assert.True(t, registered[route], "the documented route %s is not registered", route)
+		}
+	})
+
+	t.Run("serve the document", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+
+		var document struct {
+			OpenAPI    string                            `json:"openapi"`
+			Paths      map[string]map[string]interface{} `json:"paths"`
+			Components struct {
+				Schemas map[string]struct {
+					Properties map[string]interface{} `json:"properties"`
+				} `json:"schemas"`
+			} `json:"components"`
+		}
+		assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&document))
+		assert.Equal(t, openAPIVersion, document.OpenAPI)
+		assert.Contains(t, document.Paths["/api/v1/projects/{projectID}/env/{envID}"], "put")
+
+		for _, name := range []string{"Response", "SignUpInputs", "ProjectInputs", "EnvironmentKeyInputs", "User", "Project", "EnvironmentKey"} {
+			assert.Contains(t, document.Components.Schemas, name)
+		}
+		assert.Contains(t, document.Components.Schemas["SignUpInputs"].Properties, "first_name")
+		assert.Contains(t, document.Components.Schemas["Response"].Properties, "data")
+		// Fields hidden from the JSON encoding are not documented.
+		assert.NotContains(t, document.Components.Schemas["User"].Properties, "HashedPassword")
+		assert.Contains(t, document.Components.Schemas["User"].Properties, "CreatedAt")
+	})
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+)
+
+// listParameters documents the query parameters shared by the list endpoints.
+var listParameters = []apiParameter{
+	{Name: "limit", Type: "integer", Description: fmt.Sprintf("Maximum number of records, %d by default and at most %d", internal.DefaultListLimit, internal.MaxListLimit)},
+	{Name: "cursor", Type: "string", Description: "next_cursor returned with the previous page"},
+	{Name: "sort", Type: "string", Description: "Field to sort by, prefixed with - for descending order, id by default"},
+	{Name: "updated_since", Type: "string", Description: "RFC 3339 time of the oldest update"},
+}
+
+var (
+	userListParameters = append([]apiParameter{
+		{Name: "name_prefix", Type: "string", Description: "Prefix of the first name, last name or email"},
+	}, listParameters...)
+	projectListParameters = append([]apiParameter{
+		{Name: "name_prefix", Type: "string", Description: "Prefix of the project name"},
+		{Name: "owner", Type: "integer", Description: "Id of the project owner"},
+		{Name: "environment_name", Type: "string", Description: "Environment name, e.g. production"},
+	}, listParameters...)
+	envListParameters = append([]apiParameter{
+		{Name: "name_prefix", Type: "string", Description: "Prefix of the key name"},
+	}, listParameters...)
+)
+
+// parseListFilter reads the list filter from the name_prefix, owner, environment_name, updated_since, sort, cursor and limit
+// query parameters, updated_since is an RFC 3339 timestamp.
+func parseListFilter(query url.Values) (internal.ListFilter, error) {
+	filter := internal.ListFilter{
+		NamePrefix:      query.Get("name_prefix"),
+		EnvironmentName: query.Get("environment_name"),
+		Sort:            query.Get("sort"),
+		Cursor:          query.Get("cursor"),
+	}
+
+	ints := map[string]*int{
+		"owner": &filter.Owner,
+		"limit": &filter.Limit,
+	}
+	for name, value := range ints {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 {
+				return filter, fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*value = parsed
+		}
+	}
+
+	if raw := query.Get("updated_since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, err
+		}
+		filter.UpdatedSince = parsed
+	}
+
+	return filter, nil
+}
+
+// listErrorStatus returns the status of an error returned by a list query.
+func listErrorStatus(err error) int {
+	if errors.Is(err, internal.InvalidSortError) || errors.Is(err, internal.InvalidCursorError) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListFilter(t *testing.T) {
+	t.Run("valid filter", func(t *testing.T) {
+		query, err := url.ParseQuery("name_prefix=back&owner=3&environment_name=production&updated_since=2023-08-01T00:00:00Z&sort=-name&cursor=abc&limit=10")
+		assert.NoError(t, err)
+
+		filter, err := parseListFilter(query)
+		assert.NoError(t, err)
+		assert.Equal(t, "back", filter.NamePrefix)
+		assert.Equal(t, 3, filter.Owner)
+		assert.Equal(t, "production", filter.EnvironmentName)
+		assert.Equal(t, time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC), filter.UpdatedSince)
+		assert.Equal(t, "-name", filter.Sort)
+		assert.Equal(t, "abc", filter.Cursor)
+		assert.Equal(t, 10, filter.Limit)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		_, err := parseListFilter(url.Values{"limit": {"-1"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid owner", func(t *testing.T) {
+		_, err := parseListFilter(url.Values{"owner": {"omda"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid updated since", func(t *testing.T) {
+		_, err := parseListFilter(url.Values{"updated_since": {"yesterday"}})
+		assert.Error(t, err)
+	})
+}
@@ -13,14 +13,21 @@ import (
 
 var projectFields internal.ProjectInputs
 
-// getProjectsHandler retrieves a list of projects from the database and sends the response as JSON.
+// getProjectsHandler retrieves a page of projects from the database and sends the response as JSON.
+// The projects can be filtered with the name_prefix, owner, environment_name and updated_since query parameters, see parseListFilter.
 func (a *App) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
-	projects, err := a.DB.GetProjects()
+	filter, err := parseListFilter(r.URL.Query())
 	if err != nil {
-		sendJSONResponse(w, http.StatusBadRequest, "Failed to retrieve projects", nil, err)
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid projects filter", nil, err)
 		return
 	}
-	sendJSONResponse(w, http.StatusOK, "Projects found successfully", projects, nil)
+
+	projects, nextCursor, err := a.requestDB(r).GetProjects(filter)
+	if err != nil {
+		sendJSONResponse(w, listErrorStatus(err), "Failed to retrieve projects", nil, err)
+		return
+	}
+	sendPageResponse(w, "Projects found successfully", projects, nextCursor)
 }
 
 // deleteProjectByIDHandler deletes a project by its ID. The ID is retrieved from the URL path parameters.
@@ -40,18 +47,19 @@ func (a *App) deleteProjectByIDHandler(w http.ResponseWriter, r *http.Request) {
 
 	pId := int(convertedProjectId)
 
-	project, err := a.DB.GetProjectByID(pId)
+	project, err := a.requestDB(r).GetProjectByID(pId)
 	if err != nil {
 		sendJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Failed to retrieve project with id %s.", projectIDStr), nil, err)
 		return
 	}
 
-	err = a.DB.DeleteProjectByID(project.ID)
+	err = a.requestDB(r).DeleteProjectByID(project.ID)
 
 	if err != nil {
 		sendJSONResponse(w, http.StatusNotFound, "Error while deleting project", nil, err)
 		return
 	}
+	a.notifyChange(r, project.ID, models.ProjectDeletedEvent, "", 0)
 	sendJSONResponse(w, http.StatusNoContent, "Project deleted successfully", nil, nil)
 }
 
@@ -72,12 +80,11 @@ func (a *App) getProjectByIDHandler(w http.ResponseWriter, r *http.Request) {
 
 	pId := int(convertedProjectId)
 
-	project, err := a.DB.GetProjectByID(pId)
+	project, err := a.requestDB(r).GetProjectByID(pId)
 	if err != nil {
 		sendJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Failed to retrieve project with id %s.", projectIDStr), nil, err)
 		return
 	}
-	fmt.Println(project.Keys)
 	sendJSONResponse(w, http.StatusOK, "Project found successfully", project, nil)
 }
 
@@ -117,7 +124,7 @@ func (a *App) createProjectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// save the project into the database
-	err = a.DB.CreateProject(&project)
+	err = a.requestDB(r).CreateProject(&project)
 	if err != nil {
 		sendJSONResponse(
 			w, http.StatusBadRequest,
@@ -152,7 +159,7 @@ func (a *App) updateProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	existingProject, err := a.DB.GetProjectByID(projectID)
+	existingProject, err := a.requestDB(r).GetProjectByID(projectID)
 	if err != nil {
 		sendJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Failed to retrieve project with id %s.", projectIDStr), nil, err)
 		return
@@ -161,10 +168,11 @@ func (a *App) updateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	existingProject = updatedProject
 	existingProject.ID = projectID
 
-	err = a.DB.UpdateProject(&existingProject)
+	err = a.requestDB(r).UpdateProject(&existingProject)
 	if err != nil {
 		sendJSONResponse(w, http.StatusInternalServerError, "Failed to update project", nil, err)
 		return
 	}
+	a.notifyChange(r, projectID, models.ProjectUpdatedEvent, "", 0)
 	sendJSONResponse(w, http.StatusOK, "Project updated successfully", existingProject, nil)
 }
@@ -81,7 +81,7 @@ func TestProjectHandlers(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -101,7 +101,7 @@ func TestProjectHandlers(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -126,7 +126,7 @@ func TestProjectHandlers(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -148,7 +148,7 @@ func TestProjectHandlers(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -214,7 +214,7 @@ func TestProjectHandlers(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -239,7 +239,7 @@ func TestProjectHandlers(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
@@ -262,7 +262,7 @@ func TestProjectHandlers(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
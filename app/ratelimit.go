@@ -0,0 +1,88 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/rs/zerolog/log"
+)
+
+// limitRequests rejects the requests of the clients exceeding their budget with a 429 status.
+// Clients are identified by their user for access tokens, by their service for client certificates, and by their IP otherwise. Every response carries the RateLimit-* headers of its budget:
+// the burst size, the remaining requests and the seconds until the budget is full again.
+// The preflight requests don't reach it, they're answered by handleCORS.
+func (a *App) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		if a.RateLimiter == nil || a.currentConfig().RateLimit.Disabled || probeRoutes[route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		budget := rateLimitBudget(r.Method, route)
+		limit, result, err := a.RateLimiter.Allow(budget, a.rateLimitKey(r), time.Now())
+		if err != nil {
+			// Don't fail the requests if a shared store is unavailable.
+			log.Ctx(r.Context()).Error().Err(err).Str("budget", budget).Msg("Failed to check the rate limit")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			sendJSONResponse(w, http.StatusTooManyRequests, "Too many requests", nil, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitBudget returns the budget of a request: the auth endpoints and the secret reads have their own.
+func rateLimitBudget(method, route string) string {
+	switch {
+	case strings.HasPrefix(route, "/api/v1/auth/"):
+		return internal.AuthRateLimitBudget
+	case isSecretRead(method, route):
+		return internal.SecretsRateLimitBudget
+	default:
+		return internal.DefaultRateLimitBudget
+	}
+}
+
+// rateLimitKey identifies the client of a request from its access token, the token is only checked against the signing keys
+// so that no database query is made before the request is allowed. The auth endpoints and the other tokens (e.g. mfa challenges)
+// are keyed by IP, since every signin would otherwise come with a fresh budget.
+func (a *App) rateLimitKey(r *http.Request) string {
+	if strings.HasPrefix(routeTemplate(r), "/api/v1/auth/") {
+		return "ip:" + clientIP(r)
+	}
+
+	tokenString := r.Header.Get("Authorization")
+	if tokenString != "" && a.Keys != nil {
+		if payload, err := a.parseJwtToken(tokenString); err == nil {
+			challenge, _ := payload["mfa_challenge"].(bool)
+			_, action := payload["purpose"]
+			if id, ok := payload["id"].(float64); ok && !challenge && !action {
+				return fmt.Sprintf("user:%d", int(id))
+			}
+			return "ip:" + clientIP(r)
+		}
+	}
+	if identity, ok := a.clientIdentity(r); ok {
+		return "service:" + identity.Name
+	}
+	return "ip:" + clientIP(r)
+}
+
+// ceilSeconds rounds a delay up to whole seconds.
+func ceilSeconds(delay time.Duration) int {
+	return int(math.Ceil(delay.Seconds()))
+}
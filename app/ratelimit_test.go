@@ -0,0 +1,153 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitRequests(t *testing.T) {
+	app := &App{
+		Keys: internal.NewHMACKeySet("secret"),
+		RateLimiter: internal.NewRateLimiter(internal.RateLimitConfig{
+			Default: internal.RateLimitBudgetConfig{RequestsPerMinute: 1, Burst: 2},
+			Auth:    internal.RateLimitBudgetConfig{RequestsPerMinute: 1, Burst: 1},
+		}, internal.NewMemoryRateLimitStore()),
+	}
+
+	router := mux.NewRouter()
+	router.Use(app.limitRequests)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/api/v1/projects/{id}", ok)
+	router.HandleFunc("/api/v1/auth/signin", ok)
+	router.HandleFunc("/healthz", ok)
+
+	serve := func(path, remoteAddr, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.RemoteAddr = remoteAddr
+		if token != "" {
+			request.Header.Set("Authorization", token)
+		}
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+
+	t.Run("Test rate limit headers", func(t *testing.T) {
+		responseRecorder := serve("/api/v1/projects/1", "10.0.0.1:4000", "")
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.Equal(t, "2", responseRecorder.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", responseRecorder.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "60", responseRecorder.Header().Get("RateLimit-Reset"))
+
+		serve("/api/v1/projects/1", "10.0.0.1:4000", "")
+		responseRecorder = serve("/api/v1/projects/1", "10.0.0.1:4000", "")
+		assert.Equal(t, http.StatusTooManyRequests, responseRecorder.Code)
+		assert.Equal(t, "60", responseRecorder.Header().Get("Retry-After"))
+	})
+
+	t.Run("Test separate auth budget", func(t *testing.T) {
+		responseRecorder := serve("/api/v1/auth/signin", "10.0.0.1:4000", "")
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.Equal(t, "1", responseRecorder.Header().Get("RateLimit-Limit"))
+
+		responseRecorder = serve("/api/v1/auth/signin", "10.0.0.1:4000", "")
+		assert.Equal(t, http.StatusTooManyRequests, responseRecorder.Code)
+	})
+
+	t.Run("Test keyed by user", func(t *testing.T) {
+		token, err := app.GenerateJwtToken(map[string]interface{}{"id": 3})
+		assert.NoError(t, err)
+
+		// The same user is limited from every address.
+		serve("/api/v1/projects/1", "10.0.0.2:4000", token)
+		serve("/api/v1/projects/1", "10.0.0.3:4000", token)
+		responseRecorder := serve("/api/v1/projects/1", "10.0.0.4:4000", token)
+		assert.Equal(t, http.StatusTooManyRequests, responseRecorder.Code)
+
+		// Other clients of these addresses aren't.
+		responseRecorder = serve("/api/v1/projects/1", "10.0.0.2:4000", "")
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+	})
+
+	t.Run("Test challenge tokens and auth endpoints keyed by IP", func(t *testing.T) {
+		// Every signin returns a new challenge token, they share the budget of the address.
+		for i := 0; i < 2; i++ {
+			token, err := app.GenerateMFAChallengeToken(models.User{ID: 4})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, serve("/api/v1/projects/1", "10.0.0.5:4000", token).Code)
+		}
+		token, err := app.GenerateMFAChallengeToken(models.User{ID: 4})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, serve("/api/v1/projects/1", "10.0.0.5:4000", token).Code)
+
+		userToken, err := app.GenerateJwtToken(map[string]interface{}{"id": 5})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, serve("/api/v1/auth/signin", "10.0.0.6:4000", userToken).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve("/api/v1/auth/signin", "10.0.0.6:4000", "").Code)
+	})
+
+	t.Run("Test probes are not limited", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			responseRecorder := serve("/healthz", "10.0.0.1:4000", "")
+			assert.Equal(t, http.StatusOK, responseRecorder.Code)
+			assert.Empty(t, responseRecorder.Header().Get("RateLimit-Limit"))
+		}
+	})
+}
+
+// Test only the preflights answered by handleCORS skip the rate limit, the other OPTIONS requests are limited.
+func TestLimitOptionsRequests(t *testing.T) {
+	app := &App{
+		Config: internal.Config{CORS: internal.CORSConfig{AllowedOrigins: []string{"https://dashboard.example.com"}}},
+		RateLimiter: internal.NewRateLimiter(internal.RateLimitConfig{
+			Default: internal.RateLimitBudgetConfig{RequestsPerMinute: 1, Burst: 1},
+		}, internal.NewMemoryRateLimitStore()),
+	}
+	router := app.registerHandlers()
+
+	serve := func(remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodOptions, "/api/v1/openapi.json", nil)
+		request.RemoteAddr = remoteAddr
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+
+	t.Run("Test preflights are not limited", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			responseRecorder := serve("10.0.0.1:4000", map[string]string{
+				"Origin":                        "https://dashboard.example.com",
+				"Access-Control-Request-Method": http.MethodGet,
+			})
+			assert.Equal(t, http.StatusNoContent, responseRecorder.Code)
+			assert.Empty(t, responseRecorder.Header().Get("RateLimit-Limit"))
+		}
+	})
+
+	t.Run("Test other OPTIONS requests are limited", func(t *testing.T) {
+		responseRecorder := serve("10.0.0.2:4000", nil)
+		assert.Equal(t, http.StatusNoContent, responseRecorder.Code)
+		assert.Equal(t, "1", responseRecorder.Header().Get("RateLimit-Limit"))
+
+		// A request without Access-Control-Request-Method isn't a preflight, even from an allowed origin.
+		responseRecorder = serve("10.0.0.2:4000", map[string]string{"Origin": "https://dashboard.example.com"})
+		assert.Equal(t, http.StatusTooManyRequests, responseRecorder.Code)
+	})
+}
+
+func TestRateLimitBudget(t *testing.T) {
+	assert.Equal(t, internal.AuthRateLimitBudget, rateLimitBudget(http.MethodPost, "/api/v1/auth/signin"))
+	assert.Equal(t, internal.SecretsRateLimitBudget, rateLimitBudget(http.MethodGet, "/api/v1/projects/{id}/env"))
+	assert.Equal(t, internal.SecretsRateLimitBudget, rateLimitBudget(http.MethodGet, "/api/v1/projects/{projectID}/env/{envID}"))
+	assert.Equal(t, internal.DefaultRateLimitBudget, rateLimitBudget(http.MethodPost, "/api/v1/projects/{id}/env"))
+	assert.Equal(t, internal.DefaultRateLimitBudget, rateLimitBudget(http.MethodGet, "/api/v1/projects"))
+}
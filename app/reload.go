@@ -0,0 +1,95 @@
+package app
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// configCheckInterval is the interval between two checks of the config file.
+const configCheckInterval = 5 * time.Second
+
+// currentConfig returns the effective config: the config the app started with, and the reloaded keys.
+func (a *App) currentConfig() internal.Config {
+	if config := a.config.Load(); config != nil {
+		return *config
+	}
+	return a.Config
+}
+
+// ReloadConfig reads the config from its sources again and applies the reloadable keys: the log level, the rate limits,
+// the CORS policy and the webhook settings. An invalid config is rejected, and the changes of the other keys are logged and ignored until a restart.
+func (a *App) ReloadConfig() error {
+	loaded, err := internal.LoadConfig(a.configSources)
+	if err != nil {
+		log.Error().Err(err).Msg("Config reload failed, keeping the current config")
+		return err
+	}
+
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	effective, applied, ignored := internal.ReloadConfig(a.currentConfig(), loaded)
+	if len(ignored) > 0 {
+		log.Warn().Strs("keys", ignored).Msg("Config changes ignored, they need a restart")
+	}
+
+	zerolog.SetGlobalLevel(effective.Log.LevelOrDefault())
+	if a.RateLimiter != nil {
+		a.RateLimiter.Configure(effective.RateLimit)
+	}
+	if a.WebhookDispatcher != nil {
+		a.WebhookDispatcher.Configure(effective.Webhooks)
+	}
+	a.config.Store(&effective)
+
+	log.Info().Strs("keys", applied).Msg("Config reloaded")
+	return nil
+}
+
+// watchConfig reloads the config on SIGHUP, and when the modification time of the config file changes, until stop is closed.
+func (a *App) watchConfig(stop <-chan struct{}) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+	modTime := configModTime(a.configSources.File)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hangup:
+			log.Info().Msg("Received SIGHUP, reloading the config")
+			_ = a.ReloadConfig()
+		case <-ticker.C:
+			if a.configSources.File == "" {
+				continue
+			}
+			if current := configModTime(a.configSources.File); !current.Equal(modTime) {
+				modTime = current
+				log.Info().Str("file", a.configSources.File).Msg("Config file changed, reloading the config")
+				_ = a.ReloadConfig()
+			}
+		}
+	}
+}
+
+// configModTime returns the modification time of the config file, zero if it can't be read.
+func configModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
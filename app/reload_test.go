@@ -0,0 +1,103 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+const reloadConfigContent = `
+[server]
+host = "localhost"
+port = 8080
+jwt_secret_key = "xyz"
+encryption_key = "abc"
+
+[database]
+host = "localhost"
+port = 5432
+name = "postgres"
+user = "postgres"
+password = "postgres"
+`
+
+func TestReloadConfig(t *testing.T) {
+	level := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	assert.NoError(t, os.WriteFile(path, []byte(reloadConfigContent), 0600))
+	sources := internal.ConfigSources{File: path}
+	config, err := internal.LoadConfig(sources)
+	assert.NoError(t, err)
+
+	app := &App{
+		Config:            config,
+		configSources:     sources,
+		RateLimiter:       internal.NewRateLimiter(config.RateLimit, internal.NewMemoryRateLimitStore()),
+		WebhookDispatcher: internal.NewWebhookDispatcher(internal.Database{}, config.Webhooks, config.Server.EncryptionKey),
+	}
+
+	t.Run("Test reload the reloadable keys", func(t *testing.T) {
+		changed := reloadConfigContent + `
+[log]
+level = "warn"
+
+[cors]
+allowed_origins = ["https://dashboard.example.com"]
+
+[rate_limit.auth]
+burst = 3
+`
+		assert.NoError(t, os.WriteFile(path, []byte(changed), 0600))
+		assert.NoError(t, app.ReloadConfig())
+
+		current := app.currentConfig()
+		assert.Equal(t, []string{"https://dashboard.example.com"}, current.CORS.AllowedOrigins)
+		assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+		limit, _, _ := app.RateLimiter.Allow(internal.AuthRateLimitBudget, "ip:10.0.0.1", time.Now())
+		assert.Equal(t, 3, limit.Burst)
+		// The config the app started with doesn't change.
+		assert.Empty(t, app.Config.CORS.AllowedOrigins)
+	})
+
+	t.Run("Test keys needing a restart are ignored", func(t *testing.T) {
+		changed := strings.Replace(reloadConfigContent, "port = 8080", "port = 9000", 1) + "[cors]\nallowed_origins = [\"https://other.example.com\"]\n"
+		assert.NoError(t, os.WriteFile(path, []byte(changed), 0600))
+		assert.NoError(t, app.ReloadConfig())
+
+		current := app.currentConfig()
+		assert.Equal(t, 8080, current.Server.Port)
+		assert.Equal(t, []string{"https://other.example.com"}, current.CORS.AllowedOrigins)
+	})
+
+	t.Run("Test invalid config is rejected", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(path, []byte(reloadConfigContent+"[cors]\nallowed_origins = [\"dashboard\"]\n"), 0600))
+		assert.Error(t, app.ReloadConfig())
+		assert.Equal(t, []string{"https://other.example.com"}, app.currentConfig().CORS.AllowedOrigins)
+	})
+
+	t.Run("Test effective config with masked secrets", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		app.getConfigHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil))
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+
+		var response struct {
+			Data map[string]map[string]interface{} `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+		assert.Equal(t, internal.RedactedValue, response.Data["database"]["password"])
+		assert.Equal(t, internal.RedactedValue, response.Data["server"]["jwt_secret_key"])
+		assert.Equal(t, "localhost", response.Data["database"]["host"])
+		assert.Equal(t, []interface{}{"https://other.example.com"}, response.Data["cors"]["allowed_origins"])
+	})
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// streamHeartbeatInterval is the interval between two comments keeping idle streams open through proxies.
+	streamHeartbeatInterval = 15 * time.Second
+	// streamResetEvent tells the client that its last event is too old to be resumed and that it must reload the project env.
+	streamResetEvent = "reset"
+)
+
+// streamProjectEnvHandler streams the changes of the project env keys as Server-Sent Events.
+// Every event carries the key name, version and change type, never the value.
+// Clients resume a stream by sending the id of the last received event in the Last-Event-ID header.
+func (a *App) streamProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := mux.Vars(r)["id"]
+	projectID, err := strconv.Atoi(projectIDStr)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Cannot convert project id to number", nil, err)
+		return
+	}
+
+	project, err := a.requestDB(r).GetProjectByID(projectID)
+	if err != nil {
+		sendJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Failed to retrieve project with id %s", projectIDStr), nil, err)
+		return
+	}
+
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return
+	}
+
+	if err := checkProjectMFA(user, project); err != nil {
+		sendJSONResponse(w, http.StatusForbidden, "Failed to stream project environment", nil, err)
+		return
+	}
+
+	var lastEventID uint64
+	if header := strings.TrimSpace(r.Header.Get("Last-Event-ID")); header != "" {
+		lastEventID, err = strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			sendJSONResponse(w, http.StatusBadRequest, "Invalid Last-Event-ID header", nil, err)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendJSONResponse(w, http.StatusInternalServerError, "Streaming is not supported", nil, nil)
+		return
+	}
+
+	// Streams outlive the server timeouts, they end when the client disconnects or the server shuts down.
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(time.Time{})
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	subscription, missed, resumed := a.Changes.Subscribe(project.ID, lastEventID)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !resumed {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", streamResetEvent)
+	}
+	for _, change := range missed {
+		if err := writeChangeEvent(w, change); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case change, ok := <-subscription.Changes():
+			if !ok {
+				// The client is too slow or the server is shutting down, it reconnects and resumes from its last event.
+				return
+			}
+			if err := writeChangeEvent(w, change); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeChangeEvent writes the change as a Server-Sent Event named after the change type.
+func writeChangeEvent(w http.ResponseWriter, change internal.EnvChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Type, data)
+	return err
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+)
+
+// clientIdentity returns the service identity of the verified client certificate of the request, if any.
+func (a *App) clientIdentity(r *http.Request) (internal.ClientIdentityConfig, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return internal.ClientIdentityConfig{}, false
+	}
+	return a.Config.Server.TLS.ClientIdentity(r.TLS.VerifiedChains[0][0])
+}
+
+// verifyClientIdentity returns the user account a service authenticated by its client certificate acts as.
+func (a *App) verifyClientIdentity(r *http.Request, identity internal.ClientIdentityConfig) (models.User, error) {
+	user, err := a.requestDB(r).GetUserByEmail(identity.UserEmail)
+	if err != nil {
+		return models.User{}, fmt.Errorf("cannot find the user of service %s", identity.Name)
+	}
+	if user.Suspended {
+		return models.User{}, internal.UserSuspendedError
+	}
+	return user, nil
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCertificates(t *testing.T) {
+	app := &App{Keys: internal.NewHMACKeySet("secret")}
+	app.Config.Server.TLS.ClientIdentities = []internal.ClientIdentityConfig{
+		{Name: "ci", Subject: "ci.example.com", UserEmail: "ci@example.com"},
+	}
+
+	withCertificate := func(r *http.Request, commonName string) *http.Request {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return r
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("Test mapped client certificate", func(t *testing.T) {
+		request := withCertificate(httptest.NewRequest(http.MethodGet, "/api/v1/projects", nil), "ci.example.com")
+		identity, ok := app.clientIdentity(request)
+		assert.True(t, ok)
+		assert.Equal(t, "ci", identity.Name)
+		assert.Equal(t, "service:ci", app.rateLimitKey(request))
+
+		responseRecorder := httptest.NewRecorder()
+		app.authenticateMiddleware(next).ServeHTTP(responseRecorder, request)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+	})
+
+	t.Run("Test unknown client certificate", func(t *testing.T) {
+		request := withCertificate(httptest.NewRequest(http.MethodGet, "/api/v1/projects", nil), "unknown.example.com")
+		_, ok := app.clientIdentity(request)
+		assert.False(t, ok)
+
+		responseRecorder := httptest.NewRecorder()
+		app.authenticateMiddleware(next).ServeHTTP(responseRecorder, request)
+		assert.Equal(t, http.StatusUnauthorized, responseRecorder.Code)
+	})
+
+	t.Run("Test unverified client certificate", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/projects", nil)
+		request.TLS = &tls.ConnectionState{}
+		_, ok := app.clientIdentity(request)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"net/http"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startRequestSpan starts the server span of a request, continuing the trace of its W3C traceparent header if any.
+// The returned request carries the span, so that the database queries of the handler are its children.
+func startRequestSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	route := routeTemplate(r)
+	ctx, span := internal.Tracer().Start(ctx, r.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethod(r.Method),
+			semconv.HTTPRoute(route),
+		),
+	)
+	if projectID := requestProjectID(r); projectID != 0 {
+		span.SetAttributes(internal.ProjectIDAttribute.Int(projectID))
+	}
+	return r.WithContext(ctx), span
+}
+
+// endRequestSpan records the response status of a request and ends its span, server errors mark the span as failed.
+func endRequestSpan(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
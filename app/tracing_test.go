@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRequestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(internal.NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), internal.TracingConfig{}))
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+	_, err := internal.SetupTracing(context.Background(), internal.TracingConfig{})
+	assert.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		r, span := startRequestSpan(r)
+		status := http.StatusOK
+		if r.Header.Get("X-Fail") != "" {
+			status = http.StatusInternalServerError
+		}
+		w.WriteHeader(status)
+		endRequestSpan(span, status)
+	})
+
+	t.Run("continue the caller trace", func(t *testing.T) {
+		exporter.Reset()
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/projects/5", nil)
+		request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 1)
+		span := spans[0]
+		assert.Equal(t, "GET /api/v1/projects/{id}", span.Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+
+		attributes := attribute.NewSet(span.Attributes...)
+		projectID, _ := attributes.Value(internal.ProjectIDAttribute)
+		assert.Equal(t, int64(5), projectID.AsInt64())
+		route, _ := attributes.Value("http.route")
+		assert.Equal(t, "/api/v1/projects/{id}", route.AsString())
+		assert.Equal(t, codes.Unset, span.Status.Code)
+	})
+
+	t.Run("server errors", func(t *testing.T) {
+		exporter.Reset()
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/projects/5", nil)
+		request.Header.Set("X-Fail", "true")
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 1)
+		assert.False(t, spans[0].Parent.IsValid())
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+	})
+}
@@ -18,25 +18,32 @@ func (a *App) deleteUserByIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	if len(vars) == 0 {
 		sendJSONResponse(w, http.StatusBadRequest, "Cannot get the user id.", nil, internal.UserIdNotProvidedError)
+		return
 	}
 	userIDStr := vars["id"]
 	// Convert the user ID to uint
 	convertedUserId, err := strconv.ParseInt(userIDStr, 10, 64)
 	if err != nil {
 		sendJSONResponse(w, http.StatusBadRequest, "Cannot convert user id to number.", nil, err)
+		return
 	}
 
 	uId := int(convertedUserId)
 
+	// Regular users can only delete their own account.
+	if !a.canAccessUser(w, r, uId) {
+		return
+	}
+
 	// Check if the user exists
-	_, err = a.DB.GetUserByID(uId)
+	_, err = a.requestDB(r).GetUserByID(uId)
 	if err != nil {
 		sendJSONResponse(w, http.StatusNotFound, "User not found", nil, err)
 		return
 	}
 
 	// Delete the user from the database
-	err = a.DB.DeleteUserByID(uId)
+	err = a.requestDB(r).DeleteUserByID(uId)
 	if err != nil {
 		sendJSONResponse(w, http.StatusInternalServerError, "Failed to delete user", nil, err)
 		return
@@ -46,15 +53,72 @@ func (a *App) deleteUserByIDHandler(w http.ResponseWriter, r *http.Request) {
 	sendJSONResponse(w, http.StatusNoContent, "User deleted successfully", nil, nil)
 }
 
-// getUsersHandler handles the HTTP request for retrieving all users from the database.
-// It returns a JSON response with status 200 (OK) containing an array of users.
+// getUsersHandler handles the HTTP request for retrieving a page of users from the database.
+// It returns a JSON response with status 200 (OK) containing an array of users and the cursor of the next page.
+// The users can be filtered with the name_prefix and updated_since query parameters, see parseListFilter.
 // If the retrieval encounters an error, it returns an appropriate error response.
 func (a *App) getUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := a.DB.GetUsers()
+	filter, err := parseListFilter(r.URL.Query())
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid users filter", nil, err)
+		return
+	}
+
+	users, nextCursor, err := a.requestDB(r).GetUsers(filter)
+	if err != nil {
+		sendJSONResponse(w, listErrorStatus(err), "Failed to retrieve users", nil, err)
+		return
+	}
+
+	sendPageResponse(w, "Users found", users, nextCursor)
+}
+
+// getCurrentUserHandler handles the HTTP request for retrieving the profile of the requested user.
+func (a *App) getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "User found", user, nil)
+}
+
+// getUserByIDHandler handles the HTTP request for retrieving a user by ID.
+// Regular users can only retrieve their own profile, administrators can retrieve any user.
+func (a *App) getUserByIDHandler(w http.ResponseWriter, r *http.Request) {
+	userIDStr := mux.Vars(r)["id"]
+	convertedUserId, err := strconv.ParseInt(userIDStr, 10, 64)
 	if err != nil {
-		sendJSONResponse(w, http.StatusInternalServerError, "Failed to retrieve users", nil, err)
+		sendJSONResponse(w, http.StatusBadRequest, "Cannot convert user id to number.", nil, err)
+		return
+	}
+
+	uId := int(convertedUserId)
+	if !a.canAccessUser(w, r, uId) {
 		return
 	}
 
-	sendJSONResponse(w, http.StatusOK, "Users found", users, nil)
+	user, err := a.requestDB(r).GetUserByID(uId)
+	if err != nil {
+		sendJSONResponse(w, http.StatusNotFound, "User not found", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "User found", user, nil)
+}
+
+// canAccessUser sends a forbidden response and returns false if the requested user is neither the given user nor an administrator.
+func (a *App) canAccessUser(w http.ResponseWriter, r *http.Request, userID int) bool {
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return false
+	}
+
+	if user.ID != userID && !user.IsAdmin {
+		sendJSONResponse(w, http.StatusForbidden, "Forbidden", nil, internal.ForbiddenUserError)
+		return false
+	}
+	return true
 }
@@ -19,6 +19,7 @@ var configContent = `
 host = "localhost"
 port = 8080
 jwt_secret_key = "xyz"
+encryption_key = "abc"
 shutdown_timeout = 10
 
 [database]
@@ -118,7 +119,7 @@ func TestDeleteUserByIDHandler(t *testing.T) {
 		app, err := NewApp(tempFile.Name())
 		assert.NoError(t, err)
 
-		user, err := app.VerifyAndDecodeJwtToken(userToken, app.Config.Server.JWTSecretKey)
+		user, err := app.VerifyAndDecodeJwtToken(userToken)
 		assert.NoError(t, err)
 		assert.NotEqual(t, user.ID, 0)
 
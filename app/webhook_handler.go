@@ -0,0 +1,262 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// webhookSecretSize is the size of generated webhook secrets in bytes.
+	webhookSecretSize = 32
+	// webhookDeliveriesLimit is the number of deliveries returned by the delivery log.
+	webhookDeliveriesLimit = 100
+)
+
+// WebhookPayload is the JSON body sent to webhooks, it never contains env values.
+type WebhookPayload struct {
+	Event      string    `json:"event"`
+	ProjectID  int       `json:"project_id"`
+	Key        string    `json:"key,omitempty"`
+	Version    int       `json:"version,omitempty"`
+	ActorID    int       `json:"actor_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// createWebhookHandler subscribes a new webhook to the project events.
+// The secret used to sign the payloads is returned once, it can't be retrieved again.
+func (a *App) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := a.getManagedProject(w, r)
+	if !ok {
+		return
+	}
+
+	var fields internal.WebhookInputs
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+		return
+	}
+
+	if err := fields.Validate(); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Invalid webhook", nil, err)
+		return
+	}
+
+	secret := fields.Secret
+	if secret == "" {
+		buf := make([]byte, webhookSecretSize)
+		if _, err := rand.Read(buf); err != nil {
+			sendJSONResponse(w, http.StatusInternalServerError, "Failed to generate webhook secret", nil, err)
+			return
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	encryptedSecret, err := internal.EncryptAES([]byte(secret), a.Config.Server.EncryptionKey)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to encrypt webhook secret", nil, err)
+		return
+	}
+
+	webhook := models.Webhook{
+		ProjectID: project.ID,
+		URL:       fields.URL,
+		Events:    strings.Join(fields.Events, ","),
+		Secret:    encryptedSecret,
+	}
+
+	if err := a.requestDB(r).CreateWebhook(&webhook); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to create webhook", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, "Webhook created successfully", map[string]interface{}{
+		"webhook": webhook,
+		"secret":  secret,
+	}, nil)
+}
+
+// getWebhooksHandler lists the webhooks of a project.
+func (a *App) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := a.getManagedProject(w, r)
+	if !ok {
+		return
+	}
+
+	webhooks, err := a.requestDB(r).GetProjectWebhooks(project.ID)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to retrieve webhooks", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "Webhooks found successfully", webhooks, nil)
+}
+
+// deleteWebhookHandler deletes a project webhook and its delivery log.
+func (a *App) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := a.getManagedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.requestDB(r).DeleteWebhookByID(webhook.ID); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to delete webhook", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusNoContent, "Webhook deleted successfully", nil, nil)
+}
+
+// getWebhookDeliveriesHandler returns the latest deliveries of a webhook, newest first.
+func (a *App) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := a.getManagedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := a.requestDB(r).GetWebhookDeliveries(webhook.ID, webhookDeliveriesLimit)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to retrieve webhook deliveries", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, "Webhook deliveries found successfully", deliveries, nil)
+}
+
+// redeliverWebhookHandler queues a new delivery of the payload of a previous delivery.
+func (a *App) redeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := a.getManagedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := strconv.Atoi(mux.Vars(r)["deliveryID"])
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Cannot convert delivery id to number.", nil, err)
+		return
+	}
+
+	previous, err := a.requestDB(r).GetWebhookDeliveryByID(deliveryID)
+	if err != nil || previous.WebhookID != webhook.ID {
+		sendJSONResponse(w, http.StatusNotFound, "Webhook delivery not found", nil, err)
+		return
+	}
+
+	delivery := models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         previous.Event,
+		Payload:       previous.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+
+	if err := a.requestDB(r).CreateWebhookDelivery(&delivery); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "Failed to queue webhook delivery", nil, err)
+		return
+	}
+
+	sendJSONResponse(w, http.StatusAccepted, "Webhook delivery queued", delivery, nil)
+}
+
+// notifyChange publishes env changes to the live streams and queues a delivery for every project webhook subscribed to the event.
+// Failures are logged, they never fail the request that made the change.
+func (a *App) notifyChange(r *http.Request, projectID int, event, keyName string, version int) {
+	if strings.HasPrefix(event, "env.") {
+		a.Changes.Publish(internal.EnvChange{ProjectID: projectID, Key: keyName, Version: version, Type: event})
+	}
+
+	webhooks, err := a.requestDB(r).GetProjectWebhooks(projectID)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Int("project_id", projectID).Msg("Failed to retrieve the webhooks")
+		return
+	}
+
+	actor, _ := a.GetRequestedUser(r)
+	payload, err := json.Marshal(WebhookPayload{
+		Event:      event,
+		ProjectID:  projectID,
+		Key:        keyName,
+		Version:    version,
+		ActorID:    actor.ID,
+		OccurredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to encode webhook payload")
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(event) {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := a.requestDB(r).CreateWebhookDelivery(&delivery); err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Int("webhook_id", webhook.ID).Msg("Failed to queue webhook delivery")
+		}
+	}
+}
+
+// getManagedProject loads the project of the id path parameter and checks that the requested user can manage it.
+func (a *App) getManagedProject(w http.ResponseWriter, r *http.Request) (models.Project, bool) {
+	projectIDStr := mux.Vars(r)["id"]
+	projectID, err := strconv.Atoi(projectIDStr)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Cannot convert project id to number.", nil, err)
+		return models.Project{}, false
+	}
+
+	project, err := a.requestDB(r).GetProjectByID(projectID)
+	if err != nil {
+		sendJSONResponse(w, http.StatusNotFound, "Failed to retrieve project with id "+projectIDStr, nil, err)
+		return models.Project{}, false
+	}
+
+	user, err := a.GetRequestedUser(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Requested user not found.", nil, err)
+		return models.Project{}, false
+	}
+
+	if project.Owner != user.ID && !user.IsAdmin {
+		sendJSONResponse(w, http.StatusForbidden, "Forbidden", nil, internal.ProjectOwnerRequiredError)
+		return models.Project{}, false
+	}
+	return project, true
+}
+
+// getManagedWebhook loads the webhook of the webhookID path parameter within a project the requested user can manage.
+func (a *App) getManagedWebhook(w http.ResponseWriter, r *http.Request) (models.Webhook, bool) {
+	project, ok := a.getManagedProject(w, r)
+	if !ok {
+		return models.Webhook{}, false
+	}
+
+	webhookID, err := strconv.Atoi(mux.Vars(r)["webhookID"])
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "Cannot convert webhook id to number.", nil, err)
+		return models.Webhook{}, false
+	}
+
+	webhook, err := a.requestDB(r).GetWebhookByID(webhookID)
+	if err != nil || webhook.ProjectID != project.ID {
+		sendJSONResponse(w, http.StatusNotFound, "Webhook not found", nil, err)
+		return models.Webhook{}, false
+	}
+	return webhook, true
+}
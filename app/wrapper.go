@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
+	internal "github.com/Mahmoud-Emad/envserver/internal"
 	models "github.com/Mahmoud-Emad/envserver/models"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/rs/zerolog/log"
@@ -16,11 +19,20 @@ type Response struct {
 	Message string      `json:"message"`
 	Status  int         `json:"status"`
 	Data    interface{} `json:"data"`
+	// Id of the request, sent with the errors to find them in the server logs.
+	RequestID string `json:"request_id,omitempty"`
+	// Cursor of the next page of the list endpoints, empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type Handler func(w http.ResponseWriter, r *http.Request)
 
+// sendJSONResponse sends the response envelope, errors caused by a request body over the size limit are sent with a 413 status.
 func sendJSONResponse(w http.ResponseWriter, status int, message string, data interface{}, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		status = http.StatusRequestEntityTooLarge
+	}
 
 	response := Response{
 		Status:  status,
@@ -30,62 +42,127 @@ func sendJSONResponse(w http.ResponseWriter, status int, message string, data in
 
 	if err != nil {
 		response.Message = message + ": " + err.Error()
+		// Log the error with the request.
+		if sw, ok := w.(*statusWriter); ok {
+			sw.err = err
+		}
 	}
 
+	writeJSONResponse(w, response)
+}
+
+// sendPageResponse sends a page of a list with the cursor of the next page.
+func sendPageResponse(w http.ResponseWriter, message string, data interface{}, nextCursor string) {
+	writeJSONResponse(w, Response{
+		Status:     http.StatusOK,
+		Message:    message,
+		Data:       data,
+		NextCursor: nextCursor,
+	})
+}
+
+func writeJSONResponse(w http.ResponseWriter, response Response) {
+	if response.Status >= http.StatusBadRequest {
+		response.RequestID = w.Header().Get(requestIDHeader)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
+	w.WriteHeader(response.Status)
 	json.NewEncoder(w).Encode(response)
 }
 
-// wrapRequest wraps an HTTP handler function with additional debugging information and optional authentication check.
-// If protected is true, the incoming request is expected to include a JWT token in the "Authorization" header.
+// wrapRequest wraps an HTTP handler function with tracing, metrics and an optional authentication check.
+// If protected is true, the incoming request is expected to include a JWT token in the "Authorization" header,
+// or a client certificate mapped to a service identity.
+// Every wrapped request is recorded in the audit log.
 func (a *App) wrapRequest(h http.HandlerFunc, protected bool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// The writer and the audit info are shared with logRequests.
+		w, ok := rw.(*statusWriter)
+		if !ok {
+			w = &statusWriter{ResponseWriter: rw}
+		}
+		r, info := withAuditInfo(r)
+		r, span := startRequestSpan(r)
+		started := time.Now()
+		defer func() {
+			a.recordAudit(r, info, w.status)
+			status := w.status
+			if status == 0 {
+				// Nothing was written, net/http sends an empty 200 response.
+				status = http.StatusOK
+			}
+			a.Metrics.ObserveRequest(routeTemplate(r), r.Method, status, time.Since(started))
+			endRequestSpan(span, status)
+		}()
+
 		if protected {
-			// Check if the request includes a JWT token in the "Authorization" header.
+			// Check if the request includes a JWT token in the "Authorization" header, or a client certificate.
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			identity, hasIdentity := a.clientIdentity(r)
+			if authHeader == "" && !hasIdentity {
+				a.Metrics.AuthFailure(internal.AuthFailureMissingToken)
 				sendJSONResponse(w, http.StatusUnauthorized, "Unauthorized: JWT token missing", nil, nil)
 				return
 			}
 
-			// Validate and decode the JWT token.
-			user, err := a.VerifyAndDecodeJwtToken(authHeader, a.Config.Server.JWTSecretKey)
-			if err != nil {
-				sendJSONResponse(w, http.StatusUnauthorized, "Unauthorized: Invalid JWT token", nil, err)
-				return
+			var user models.User
+			var err error
+			if authHeader != "" {
+				// Validate and decode the JWT token.
+				user, err = a.VerifyAndDecodeJwtToken(authHeader)
+				if err != nil {
+					a.Metrics.AuthFailure(internal.AuthFailureInvalidToken)
+					sendJSONResponse(w, http.StatusUnauthorized, "Unauthorized: Invalid JWT token", nil, err)
+					return
+				}
+			} else {
+				// Act as the user of the service identified by the client certificate.
+				user, err = a.verifyClientIdentity(r, identity)
+				if err != nil {
+					a.Metrics.AuthFailure(internal.AuthFailureInvalidClientCert)
+					sendJSONResponse(w, http.StatusUnauthorized, "Unauthorized: Invalid client certificate", nil, err)
+					return
+				}
 			}
 
 			// Add the user object inside the request.
 			ctx := context.WithValue(r.Context(), UserContextKey, user)
 			r = r.WithContext(ctx)
-
-			// Print out the incoming request URL and its method for debugging.
-			log.Debug().Msgf("User: %d | Request: %s %s", user.ID, r.Method, r.URL.Path)
-		} else {
-			// Print out the incoming request URL and its method for debugging.
-			log.Debug().Msgf("Request: %s %s", r.Method, r.URL.Path)
+			info.ActorID = user.ID
+			span.SetAttributes(internal.UserIDAttribute.Int(user.ID))
 		}
 		// Call the original handler.
 		h(w, r)
 	}
 }
 
+// wrapAdminRequest wraps a protected HTTP handler function that is restricted to site administrators.
+func (a *App) wrapAdminRequest(h http.HandlerFunc) http.HandlerFunc {
+	return a.wrapRequest(func(w http.ResponseWriter, r *http.Request) {
+		if !a.requireAdmin(w, r) {
+			return
+		}
+		h(w, r)
+	}, true)
+}
+
 func (a *App) authenticateMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract the JWT token from the Authorization header
 		tokenString := r.Header.Get("Authorization")
 		if strings.TrimSpace(tokenString) == "" {
+			// Services can authenticate with a client certificate instead, it's checked by wrapRequest.
+			if _, ok := a.clientIdentity(r); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
 			log.Warn().Msgf("Request|unauthorized: %s %s", r.Method, r.URL.Path)
 			sendJSONResponse(w, http.StatusUnauthorized, "Authorization token required", nil, nil)
 			return
 		}
 
 		// Parse the JWT token and validate its signature
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Replace "your-secret-key" with the same secret key used for signing the token
-			return []byte(a.Config.Server.JWTSecretKey), nil
-		})
+		token, err := jwt.Parse(tokenString, a.Keys.Keyfunc)
 
 		if err != nil || !token.Valid {
 			log.Warn().Msgf("Request|unauthorized: %s %s", r.Method, r.URL.Path)
@@ -107,7 +184,7 @@ func (a *App) GetRequestedUser(r *http.Request) (models.User, error) {
 	user, ok := r.Context().Value(UserContextKey).(models.User)
 	if !ok {
 		authHeader := r.Header.Get("Authorization")
-		user, err := a.VerifyAndDecodeJwtToken(authHeader, a.Config.Server.JWTSecretKey)
+		user, err := a.VerifyAndDecodeJwtToken(authHeader)
 		if err != nil {
 			return user, errors.New("cannot decode jwt")
 		}
@@ -117,3 +194,45 @@ func (a *App) GetRequestedUser(r *http.Request) (models.User, error) {
 	}
 	return user, nil
 }
+
+// limitBodySize fails the reads of the request bodies over the configured size, the handlers then respond with a 413 status.
+func (a *App) limitBodySize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, a.Config.Server.MaxBodySizeOrDefault())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestDB returns the database running its queries in the context of the request, so that they're traced as part of it.
+func (a *App) requestDB(r *http.Request) *internal.Database {
+	return a.DB.WithContext(r.Context())
+}
+
+const clientIPContextKey contextKey = "client_ip"
+
+// resolveClientIP finds the address of the client, taken from the X-Forwarded-For header of the requests sent by a trusted proxy.
+func (a *App) resolveClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := a.TrustedProxies.ClientIP(remoteIP(r), r.Header.Values("X-Forwarded-For"))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPContextKey, ip)))
+	})
+}
+
+// clientIP returns the IP address of the client that sent the request.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP returns the IP address of the connection, e.g. of a proxy.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
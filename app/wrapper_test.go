@@ -0,0 +1,79 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitBodySize(t *testing.T) {
+	app := &App{Config: internal.Config{Server: internal.ServerConfig{MaxBodySize: 16}}}
+
+	router := mux.NewRouter()
+	router.Use(app.limitBodySize)
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var fields map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+			sendJSONResponse(w, http.StatusBadRequest, "Invalid request payload", nil, err)
+			return
+		}
+		sendJSONResponse(w, http.StatusOK, "ok", nil, nil)
+	})
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+
+	t.Run("Test body under the limit", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(`{"a": "b"}`).Code)
+	})
+
+	t.Run("Test body over the limit", func(t *testing.T) {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, serve(`{"key": "a value over the limit"}`).Code)
+	})
+
+	t.Run("Test invalid body under the limit", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(`{`).Code)
+	})
+}
+
+// Test the X-Forwarded-For header is only trusted from the configured proxies.
+func TestResolveClientIP(t *testing.T) {
+	proxies, err := internal.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	app := &App{TrustedProxies: proxies}
+
+	router := mux.NewRouter()
+	router.Use(app.resolveClientIP)
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(clientIP(r)))
+	})
+
+	serve := func(remoteAddr, forwardedFor string) string {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			request.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		return responseRecorder.Body.String()
+	}
+
+	t.Run("Test request from a trusted proxy", func(t *testing.T) {
+		assert.Equal(t, "198.51.100.1", serve("10.0.0.1:4000", "1.2.3.4, 198.51.100.1"))
+	})
+
+	t.Run("Test header of a direct client is ignored", func(t *testing.T) {
+		assert.Equal(t, "203.0.113.7", serve("203.0.113.7:4000", "198.51.100.1"))
+	})
+}
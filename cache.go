@@ -0,0 +1,75 @@
+package envserver
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	internal "github.com/Mahmoud-Emad/envserver/internal"
+)
+
+// cache stores the last fetched keys of the project environments, encrypted, in a local directory.
+type cache struct {
+	dir string
+	key string
+}
+
+// newCache creates a cache in the directory, the files are encrypted with the key.
+func newCache(dir, key string) *cache {
+	return &cache{dir: dir, key: key}
+}
+
+// path returns the cache file of a project environment, named after its hash so that names can't escape the directory.
+func (c *cache) path(project, env string) string {
+	return filepath.Join(c.dir, internal.HashMD5(project+"/"+env)+".cache")
+}
+
+// Save replaces the cached keys of the project environment.
+func (c *cache) Save(project, env string, s snapshot) error {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := internal.EncryptAES(content, c.key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash never leaves a truncated cache.
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(encrypted); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(project, env))
+}
+
+// Load returns the cached keys of the project environment.
+func (c *cache) Load(project, env string) (snapshot, error) {
+	encrypted, err := os.ReadFile(c.path(project, env))
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	content, err := internal.DecryptAES(encrypted, c.key)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	var s snapshot
+	err = json.Unmarshal(content, &s)
+	return s, err
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+)
+
+// SignupRequest holds the details of a new user.
+type SignupRequest struct {
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	ProjectOwner bool   `json:"is_owner"`
+}
+
+// SigninResult is the result of a signin, either an access token or a two-factor authentication challenge.
+type SigninResult struct {
+	Token string `json:"token"`
+	// MFARequired is set when the user has two-factor authentication enabled,
+	// the signin must be completed with SigninMFA using MFAToken.
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// Signup creates a new user.
+func (c *Client) Signup(ctx context.Context, request SignupRequest) (models.User, error) {
+	var user models.User
+	err := c.do(ctx, http.MethodPost, "/auth/signup", request, &user)
+	return user, err
+}
+
+// Signin authenticates the user, the returned access token is sent with the next requests.
+func (c *Client) Signin(ctx context.Context, email, password string) (SigninResult, error) {
+	var result SigninResult
+	err := c.do(ctx, http.MethodPost, "/auth/signin", map[string]string{
+		"email":    email,
+		"password": password,
+	}, &result)
+	if err == nil && result.Token != "" {
+		c.SetToken(result.Token)
+	}
+	return result, err
+}
+
+// SigninMFA completes a signin with a TOTP or a recovery code, the returned access token is sent with the next requests.
+func (c *Client) SigninMFA(ctx context.Context, mfaToken, code string) (string, error) {
+	var result SigninResult
+	err := c.do(ctx, http.MethodPost, "/auth/signin/mfa", map[string]string{
+		"mfa_token": mfaToken,
+		"code":      code,
+	}, &result)
+	if err != nil {
+		return "", err
+	}
+	c.SetToken(result.Token)
+	return result.Token, nil
+}
+
+// CurrentUser returns the authenticated user.
+func (c *Client) CurrentUser(ctx context.Context) (models.User, error) {
+	var user models.User
+	err := c.do(ctx, http.MethodGet, "/users/me", nil, &user)
+	return user, err
+}
@@ -0,0 +1,229 @@
+// Package client is a Go client for the envserver REST API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the number of times an idempotent request is retried after a transient failure.
+	DefaultMaxRetries = 3
+	// DefaultRetryWait is the delay before the first retry, it doubles with every retry.
+	DefaultRetryWait = 200 * time.Millisecond
+	// DefaultTimeout is the timeout of the default http client.
+	DefaultTimeout = 30 * time.Second
+
+	apiPrefix = "/api/v1"
+)
+
+// Client calls the envserver REST API, it's safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	retryWait  time.Duration
+
+	mu    sync.RWMutex
+	token string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the http client used to send the requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken sets the access token sent with the requests.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries sets the number of retries of idempotent requests and the delay before the first retry.
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+// New creates a client for the envserver listening at baseURL, e.g. "https://envserver.example.com".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		maxRetries: DefaultMaxRetries,
+		retryWait:  DefaultRetryWait,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token returns the access token sent with the requests.
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// SetToken sets the access token sent with the requests, Signin sets it automatically.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// response is the envelope of every envserver response.
+type response struct {
+	Message    string          `json:"message"`
+	Status     int             `json:"status"`
+	Data       json.RawMessage `json:"data"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+// page receives the data and the next cursor of a list response.
+type page struct {
+	data       interface{}
+	nextCursor string
+}
+
+// do sends the request and decodes the data of the response into out, if not nil.
+// GET, PUT and DELETE requests are retried after network errors, 429, 502, 503 and 504 responses.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	retries := 0
+	if isIdempotent(method) {
+		retries = c.maxRetries
+	}
+
+	wait := c.retryWait
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, payload)
+		retryable := err != nil || isRetryableStatus(resp.StatusCode)
+		if !retryable || attempt >= retries {
+			if err != nil {
+				return err
+			}
+			return decodeResponse(resp, out)
+		}
+
+		delay := wait
+		if err == nil {
+			if retryAfter := parseRetryAfter(resp.Header); retryAfter > delay {
+				delay = retryAfter
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+apiPrefix+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	return c.httpClient.Do(req)
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	var envelope response
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(content)) > 0 {
+		if err := json.Unmarshal(content, &envelope); err != nil && resp.StatusCode < 400 {
+			return fmt.Errorf("cannot decode envserver response: %w", err)
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		return newError(resp, envelope.Message)
+	}
+
+	if p, ok := out.(*page); ok {
+		p.nextCursor = envelope.NextCursor
+		out = p.data
+	}
+
+	if out == nil || len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter returns the delay of the Retry-After header, 0 if it's missing or invalid.
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
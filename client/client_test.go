@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mahmoud-Emad/envserver/app"
+	"github.com/stretchr/testify/assert"
+)
+
+var configContent = `
+[server]
+host = "localhost"
+port = 8080
+jwt_secret_key = "xyz"
+encryption_key = "abc"
+shutdown_timeout = 10
+
+[database]
+host = "localhost"
+port = 5432
+name = "postgres"
+user = "postgres"
+password = "postgres"
+`
+
+// newTestServer runs the envserver API behind an httptest server.
+func newTestServer(t *testing.T) (*app.App, *httptest.Server) {
+	tempFile, err := os.CreateTemp("", "config.toml")
+	assert.NoError(t, err)
+	defer os.Remove(tempFile.Name())
+
+	err = os.WriteFile(tempFile.Name(), []byte(configContent), 0644)
+	assert.NoError(t, err)
+
+	envserver, err := app.NewApp(tempFile.Name())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(envserver.Handler())
+	t.Cleanup(server.Close)
+	return envserver, server
+}
+
+// writeResponse writes a response envelope like the envserver handlers.
+func writeResponse(w http.ResponseWriter, status int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": message, "status": status, "data": data})
+}
+
+func TestClient(t *testing.T) {
+	envserver, server := newTestServer(t)
+	ctx := context.Background()
+	c := New(server.URL)
+
+	email := "client-sdk@gmail.com"
+	password := "password123"
+	defer envserver.DB.DeleteUserByEmail(email)
+
+	user, err := c.Signup(ctx, SignupRequest{FirstName: "omda", LastName: "man", Email: email, Password: password, ProjectOwner: true})
+	assert.NoError(t, err)
+	assert.Equal(t, email, user.Email)
+
+	t.Run("requests without token are unauthorized", func(t *testing.T) {
+		_, err := c.Projects(ctx)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		_, err := New(server.URL).Signin(ctx, email, "wrong-password")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	result, err := c.Signin(ctx, email, password)
+	assert.NoError(t, err)
+	assert.False(t, result.MFARequired)
+	assert.Equal(t, result.Token, c.Token())
+
+	t.Run("projects and env keys", func(t *testing.T) {
+		me, err := c.CurrentUser(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, me.ID)
+
+		project, err := c.CreateProject(ctx, "client-sdk")
+		assert.NoError(t, err)
+		assert.Equal(t, "client-sdk", project.Name)
+		defer c.DeleteProject(ctx, project.ID)
+
+		env, err := c.CreateEnvKey(ctx, project.ID, "DATABASE_URL", "postgres://localhost")
+		assert.NoError(t, err)
+		assert.Equal(t, "DATABASE_URL", env.Key)
+		assert.Equal(t, 1, env.Version)
+
+		updated, err := c.UpdateEnvKey(ctx, project.ID, env.ID, "DATABASE_URL", "postgres://db")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, updated.Version)
+
+		keys, err := c.Env(ctx, project.ID)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+
+		assert.NoError(t, c.DeleteEnvKey(ctx, project.ID, env.ID))
+		_, err = c.EnvKey(ctx, project.ID, env.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		_, err := c.Project(ctx, 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		writeResponse(w, http.StatusLocked, "Signin temporarily locked", nil)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).Signin(context.Background(), "omda@gmail.com", "password123")
+
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusLocked, apiErr.StatusCode)
+	assert.Equal(t, "Signin temporarily locked", apiErr.Message)
+	assert.Equal(t, 2*time.Minute, apiErr.RetryAfter)
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.NotErrorIs(t, err, ErrUnauthorized)
+
+	for status, expected := range map[int]error{
+		http.StatusBadRequest:          ErrBadRequest,
+		http.StatusForbidden:           ErrForbidden,
+		http.StatusConflict:            ErrConflict,
+		http.StatusInternalServerError: ErrServer,
+	} {
+		assert.ErrorIs(t, &Error{StatusCode: status}, expected)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			writeResponse(w, http.StatusServiceUnavailable, "Service unavailable", nil)
+			return
+		}
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		writeResponse(w, http.StatusOK, "Projects found successfully", []map[string]interface{}{{"ID": 1, "name": "backend"}})
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithToken("token"), WithRetries(3, time.Millisecond))
+
+	t.Run("idempotent requests are retried", func(t *testing.T) {
+		projects, err := c.Projects(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Len(t, projects, 1)
+		assert.Equal(t, "backend", projects[0].Name)
+	})
+
+	t.Run("non idempotent requests are not retried", func(t *testing.T) {
+		calls.Store(0)
+		_, err := c.CreateProject(context.Background(), "backend")
+		assert.ErrorIs(t, err, ErrServer)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("retries stop when the context is done", func(t *testing.T) {
+		calls.Store(-100)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := New(server.URL, WithRetries(100, 5*time.Millisecond)).Projects(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestClientPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/projects/1/env", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		response := map[string]interface{}{"message": "Project environment found successfully", "status": http.StatusOK}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			response["data"] = []map[string]interface{}{{"ID": 1, "Key": "API_URL"}}
+			response["next_cursor"] = "page-2"
+		case "page-2":
+			response["data"] = []map[string]interface{}{{"ID": 2, "Key": "API_TOKEN"}}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	ctx := context.Background()
+
+	t.Run("list a page", func(t *testing.T) {
+		env, next, err := c.ListEnv(ctx, 1, ListOptions{NamePrefix: "API_", Limit: 1})
+		assert.NoError(t, err)
+		assert.Len(t, env, 1)
+		assert.Equal(t, "page-2", next)
+
+		env, next, err = c.ListEnv(ctx, 1, ListOptions{NamePrefix: "API_", Cursor: next})
+		assert.NoError(t, err)
+		assert.Equal(t, "API_TOKEN", env[0].Key)
+		assert.Empty(t, next)
+	})
+
+	t.Run("fetch all the pages", func(t *testing.T) {
+		env, err := c.Env(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, env, 2)
+		assert.Equal(t, "API_URL", env[0].Key)
+		assert.Equal(t, "API_TOKEN", env[1].Key)
+	})
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+)
+
+// envKeyRequest holds the key and value of an env key to create or update.
+type envKeyRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Env returns all the env keys of a project, fetched page by page.
+func (c *Client) Env(ctx context.Context, projectID int) ([]models.EnvironmentKey, error) {
+	var env []models.EnvironmentKey
+	opts := ListOptions{}
+	for {
+		page, next, err := c.ListEnv(ctx, projectID, opts)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, page...)
+		if next == "" {
+			return env, nil
+		}
+		opts.Cursor = next
+	}
+}
+
+// ListEnv returns a page of the env keys of a project matching the options, and the cursor of the next page, empty on the last page.
+func (c *Client) ListEnv(ctx context.Context, projectID int, opts ListOptions) ([]models.EnvironmentKey, string, error) {
+	var env []models.EnvironmentKey
+	next, err := c.list(ctx, fmt.Sprintf("/projects/%d/env", projectID), opts, &env)
+	return env, next, err
+}
+
+// EnvKey returns an env key of a project by its id.
+func (c *Client) EnvKey(ctx context.Context, projectID, envID int) (models.EnvironmentKey, error) {
+	var env models.EnvironmentKey
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/projects/%d/env/%d", projectID, envID), nil, &env)
+	return env, err
+}
+
+// CreateEnvKey adds a key to the project env.
+func (c *Client) CreateEnvKey(ctx context.Context, projectID int, key, value string) (models.EnvironmentKey, error) {
+	var env models.EnvironmentKey
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/projects/%d/env", projectID), envKeyRequest{Key: key, Value: value}, &env)
+	return env, err
+}
+
+// UpdateEnvKey sets the key and value of an env key, its version is incremented.
+func (c *Client) UpdateEnvKey(ctx context.Context, projectID, envID int, key, value string) (models.EnvironmentKey, error) {
+	var env models.EnvironmentKey
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/projects/%d/env/%d", projectID, envID), envKeyRequest{Key: key, Value: value}, &env)
+	return env, err
+}
+
+// DeleteEnvKey deletes an env key of a project.
+func (c *Client) DeleteEnvKey(ctx context.Context, projectID, envID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/projects/%d/env/%d", projectID, envID), nil, nil)
+}
+
+// Env operations of ApplyEnv.
+const (
+	SetOperation    = "set"
+	DeleteOperation = "delete"
+)
+
+// EnvOperation sets or deletes an env key, see ApplyEnv.
+type EnvOperation struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// EnvOperationResult is the outcome of an applied env operation.
+type EnvOperationResult struct {
+	Op  string `json:"op"`
+	Key string `json:"key"`
+	// Event is the change made by the operation: env.created, env.updated or env.deleted.
+	Event   string `json:"event"`
+	Version int    `json:"version"`
+}
+
+// ApplyEnv applies the operations to the env keys of a project in a single transaction, either all of them are applied or none.
+func (c *Client) ApplyEnv(ctx context.Context, projectID int, operations []EnvOperation) ([]EnvOperationResult, error) {
+	var results []EnvOperationResult
+	err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/projects/%d/env", projectID), map[string]interface{}{"operations": operations}, &results)
+	return results, err
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrBadRequest is returned when the request is invalid, e.g. a mandatory field is missing.
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnauthorized is returned when the access token is missing, invalid or expired.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the user isn't allowed to perform the request.
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is returned when the requested resource doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when the resource conflicts with an existing one.
+	ErrConflict = errors.New("conflict")
+	// ErrLocked is returned when the signin is temporarily locked after too many failures.
+	ErrLocked = errors.New("locked")
+	// ErrTooManyRequests is returned when the client is rate limited.
+	ErrTooManyRequests = errors.New("too many requests")
+	// ErrServer is returned when the server failed to process the request.
+	ErrServer = errors.New("server error")
+)
+
+// Error is returned for every response with an error status, use errors.Is with the Err variables to check its kind.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay sent in the Retry-After header of locked and rate limited responses.
+	RetryAfter time.Duration
+	// RequestID is the id of the request in the server logs.
+	RequestID string
+}
+
+func newError(resp *http.Response, message string) *Error {
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("envserver: %d %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("envserver: %d %s", e.StatusCode, e.Message)
+}
+
+// Unwrap maps the status code to one of the Err variables.
+func (e *Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusLocked:
+		return ErrLocked
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrTooManyRequests
+	case e.StatusCode >= 500:
+		return ErrServer
+	}
+	return nil
+}
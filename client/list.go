@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ListOptions filters, sorts and paginates a list, zero values are ignored.
+// Filters that don't apply to a list are ignored by the server.
+type ListOptions struct {
+	// NamePrefix matches the project names or the env key names.
+	NamePrefix      string
+	Owner           int
+	EnvironmentName string
+	UpdatedSince    time.Time
+	// Sort is the field to sort by, prefixed with "-" for descending order, e.g. "-updated_at".
+	Sort string
+	// Cursor is the next cursor returned with the previous page.
+	Cursor string
+	Limit  int
+}
+
+func (o ListOptions) query() string {
+	query := url.Values{}
+	set := map[string]string{
+		"name_prefix":      o.NamePrefix,
+		"environment_name": o.EnvironmentName,
+		"sort":             o.Sort,
+		"cursor":           o.Cursor,
+	}
+	for name, value := range set {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if o.Owner != 0 {
+		query.Set("owner", strconv.Itoa(o.Owner))
+	}
+	if o.Limit != 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if !o.UpdatedSince.IsZero() {
+		query.Set("updated_since", o.UpdatedSince.Format(time.RFC3339))
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// list fetches a page of the list at path into out, and returns the cursor of the next page.
+func (c *Client) list(ctx context.Context, path string, opts ListOptions, out interface{}) (string, error) {
+	p := page{data: out}
+	err := c.do(ctx, http.MethodGet, path+opts.query(), nil, &p)
+	return p.nextCursor, err
+}
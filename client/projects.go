@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+)
+
+// Projects returns all the projects, fetched page by page.
+func (c *Client) Projects(ctx context.Context) ([]models.Project, error) {
+	var projects []models.Project
+	opts := ListOptions{}
+	for {
+		page, next, err := c.ListProjects(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, page...)
+		if next == "" {
+			return projects, nil
+		}
+		opts.Cursor = next
+	}
+}
+
+// ListProjects returns a page of the projects matching the options, and the cursor of the next page, empty on the last page.
+func (c *Client) ListProjects(ctx context.Context, opts ListOptions) ([]models.Project, string, error) {
+	var projects []models.Project
+	next, err := c.list(ctx, "/projects", opts, &projects)
+	return projects, next, err
+}
+
+// Project returns a project by its id.
+func (c *Client) Project(ctx context.Context, id int) (models.Project, error) {
+	var project models.Project
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/projects/%d", id), nil, &project)
+	return project, err
+}
+
+// CreateProject creates a project owned by the authenticated user.
+func (c *Client) CreateProject(ctx context.Context, name string) (models.Project, error) {
+	var project models.Project
+	err := c.do(ctx, http.MethodPost, "/projects", map[string]string{"name": name}, &project)
+	return project, err
+}
+
+// UpdateProject replaces the project of the same id.
+func (c *Client) UpdateProject(ctx context.Context, project models.Project) (models.Project, error) {
+	var updated models.Project
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/projects/%d", project.ID), project, &updated)
+	return updated, err
+}
+
+// DeleteProject deletes a project and its env keys.
+func (c *Client) DeleteProject(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/projects/%d", id), nil, nil)
+}
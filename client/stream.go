@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ResetEvent is streamed when the last event id is too old to be resumed, the project env must be reloaded.
+const ResetEvent = "reset"
+
+// EnvChange describes a change of a project env key, it never contains the key value.
+type EnvChange struct {
+	ID         uint64    `json:"id"`
+	ProjectID  int       `json:"project_id"`
+	Key        string    `json:"key"`
+	Version    int       `json:"version"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// EnvEvent is an event of the env stream of a project.
+type EnvEvent struct {
+	// ID is the id to send as lastEventID to resume the stream after this event.
+	ID string
+	// Type is the change type, e.g. env.updated, or ResetEvent.
+	Type   string
+	Change EnvChange
+}
+
+// StreamEnv streams the changes of the project env keys and calls handle for every event until the context is done,
+// the stream is closed by the server or handle returns an error. If lastEventID is set, the stream resumes after it.
+func (c *Client) StreamEnv(ctx context.Context, projectID int, lastEventID string, handle func(EnvEvent) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s%s/projects/%d/env/stream", c.baseURL, apiPrefix, projectID), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	// The stream is long lived, so the timeout of the http client doesn't apply.
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return decodeResponse(resp, nil)
+	}
+	defer resp.Body.Close()
+
+	var event EnvEvent
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if event.Type != "" {
+				if event.Type != ResetEvent {
+					if err := json.Unmarshal([]byte(data.String()), &event.Change); err != nil {
+						return fmt.Errorf("cannot decode env event: %w", err)
+					}
+				}
+				if err := handle(event); err != nil {
+					return err
+				}
+			}
+			event = EnvEvent{}
+			data.Reset()
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Type = value
+		case "data":
+			data.WriteString(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -5,25 +5,66 @@ import (
 	"os"
 
 	envserver "github.com/Mahmoud-Emad/envserver/app"
+	internal "github.com/Mahmoud-Emad/envserver/internal"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 	var configFilePath string
-	flag.StringVar(&configFilePath, "config", "", "Path to the Config file")
+	var jwtKeyPath string
+	var jwtKeyType string
+	var adminEmail string
+	var verifyAudit bool
+	configFlags := internal.ConfigFlags{}
+	flag.StringVar(&configFilePath, "config", "", "Path to the Config file, TOML, YAML or JSON")
+	flag.Var(configFlags, "set", "Override a config key, e.g. -set server.port=8080, it can be repeated")
+	flag.StringVar(&jwtKeyPath, "generate-jwt-key", "", "Generate a new JWT signing key at this path and exit")
+	flag.StringVar(&jwtKeyType, "jwt-key-type", "ed25519", "Type of the generated JWT signing key, rsa or ed25519")
+	flag.StringVar(&adminEmail, "promote-admin", "", "Grant the administrator role to the user with this email and exit")
+	flag.BoolVar(&verifyAudit, "verify-audit", false, "Verify the audit log hash chain and exit")
 	flag.Parse()
 
-	if configFilePath == "" {
-		log.Error().Msgf("Error: You must provide the path to the Config file using the -config flag.")
-		flag.Usage()
-		os.Exit(1)
+	if jwtKeyPath != "" {
+		if err := internal.GenerateJWTKeyFile(jwtKeyPath, jwtKeyType); err != nil {
+			log.Error().Msgf("Error generating the JWT key: %s\n", err)
+			os.Exit(1)
+		}
+		log.Info().Msgf("JWT key generated at %s", jwtKeyPath)
+		return
 	}
 
-	app, err := envserver.NewApp(configFilePath)
+	// The config file is optional, the config can also be set by the ENVSERVER_ environment variables and the -set flags.
+	app, err := envserver.NewAppFromSources(internal.ConfigSources{
+		File:    configFilePath,
+		Environ: os.Environ(),
+		Flags:   configFlags,
+	})
 	if err != nil {
 		log.Error().Msgf("Error creating the app: %s\n", err)
 		os.Exit(1)
 	}
 
-	app.Start()
+	if adminEmail != "" {
+		if err := app.DB.PromoteAdmin(adminEmail, false); err != nil {
+			log.Error().Msgf("Error granting the administrator role to %s: %s\n", adminEmail, err)
+			os.Exit(1)
+		}
+		log.Info().Msgf("%s is now an administrator", adminEmail)
+		return
+	}
+
+	if verifyAudit {
+		verified, err := app.DB.VerifyAuditLog(internal.AuditChainKey(app.Config.Server.EncryptionKey))
+		if err != nil {
+			log.Error().Msgf("Audit log verification failed after %d events: %s\n", verified, err)
+			os.Exit(1)
+		}
+		log.Info().Msgf("Audit log verified, %d events", verified)
+		return
+	}
+
+	if err := app.Start(); err != nil {
+		log.Error().Msgf("Error running the server: %s\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// auditLockID is the postgres advisory lock serializing the appends to the audit chain.
+	auditLockID = 7331
+	// DefaultAuditLimit is the number of events returned by an audit query without limit.
+	DefaultAuditLimit = 100
+	// MaxAuditLimit is the maximum number of events returned by an audit query.
+	MaxAuditLimit = 1000
+	// auditVerifyBatchSize is the number of events loaded at once while verifying the chain.
+	auditVerifyBatchSize = 500
+	// auditHeadID is the ID of the single audit head row.
+	auditHeadID = 1
+)
+
+// AuditFilter filters the audit events, zero values are ignored.
+type AuditFilter struct {
+	ActorID   int
+	ProjectID int
+	Action    string
+	KeyName   string
+	Result    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// AuditChainKey derives the HMAC key of the audit chain from the encryption key, so that the chain can't be rewritten
+// by someone who can only write to the database.
+func AuditChainKey(encryptionKey string) []byte {
+	return auditHMAC([]byte(encryptionKey), "envserver audit chain")
+}
+
+// ComputeAuditHash returns the HMAC of the event chained to the previous event hash.
+func ComputeAuditHash(event models.AuditEvent, prevHash string, key []byte) string {
+	content := fmt.Sprintf(
+		"%s|%d|%d|%s|%d|%s|%s|%s|%s|%d",
+		prevHash, event.CreatedAt.UnixMicro(), event.ActorID, event.Action, event.ProjectID,
+		event.KeyName, event.SourceIP, event.UserAgent, event.Result, event.Status,
+	)
+	return hex.EncodeToString(auditHMAC(key, content))
+}
+
+// ComputeAuditHeadSignature returns the HMAC of the audit head pointing to the event.
+func ComputeAuditHeadSignature(eventID int, hash string, key []byte) string {
+	return hex.EncodeToString(auditHMAC(key, "head|"+strconv.Itoa(eventID)+"|"+hash))
+}
+
+func auditHMAC(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
+
+// VerifyAuditChain checks that the events are correctly chained starting from prevHash, and returns the hash of the last event.
+func VerifyAuditChain(events []models.AuditEvent, prevHash string, key []byte) (string, error) {
+	for _, event := range events {
+		if event.PrevHash != prevHash {
+			return "", fmt.Errorf("audit event %d is not chained to the previous event", event.ID)
+		}
+		if !hmac.Equal([]byte(ComputeAuditHash(event, prevHash, key)), []byte(event.Hash)) {
+			return "", fmt.Errorf("audit event %d was modified", event.ID)
+		}
+		prevHash = event.Hash
+	}
+	return prevHash, nil
+}
+
+// VerifyAuditHead checks that the head points to the last event of the chain, nil if the chain has no events.
+func VerifyAuditHead(head *models.AuditHead, lastID int, lastHash string, key []byte) error {
+	if head == nil {
+		if lastID != 0 {
+			return fmt.Errorf("the audit head is missing")
+		}
+		return nil
+	}
+	if !hmac.Equal([]byte(ComputeAuditHeadSignature(head.EventID, head.Hash, key)), []byte(head.Signature)) {
+		return fmt.Errorf("the audit head was modified")
+	}
+	if head.EventID != lastID || head.Hash != lastHash {
+		return fmt.Errorf("the audit chain ends at event %d but its head is event %d, events were deleted or added", lastID, head.EventID)
+	}
+	return nil
+}
+
+// AppendAuditEvent chains the event to the last stored one, stores it and moves the head of the chain to it.
+func (d *Database) AppendAuditEvent(event *models.AuditEvent, key []byte) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		// Serialize the appends so that two events can't be chained to the same previous event.
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLockID).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditEvent
+		result := tx.Order("id desc").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// Postgres stores microseconds, truncate so the hash can be verified after reading the event back.
+		event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		event.PrevHash = last.Hash
+		event.Hash = ComputeAuditHash(*event, event.PrevHash, key)
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+
+		head := models.AuditHead{
+			ID:        auditHeadID,
+			EventID:   event.ID,
+			Hash:      event.Hash,
+			Signature: ComputeAuditHeadSignature(event.ID, event.Hash, key),
+		}
+		return tx.Save(&head).Error
+	})
+}
+
+// GetAuditEvents returns the latest audit events matching the filter.
+func (d *Database) GetAuditEvents(filter AuditFilter) ([]models.AuditEvent, error) {
+	query := d.db.Model(&models.AuditEvent{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.ProjectID != 0 {
+		query = query.Where("project_id = ?", filter.ProjectID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.KeyName != "" {
+		query = query.Where("key_name = ?", filter.KeyName)
+	}
+	if filter.Result != "" {
+		query = query.Where("result = ?", filter.Result)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	}
+	if limit > MaxAuditLimit {
+		limit = MaxAuditLimit
+	}
+
+	var events []models.AuditEvent
+	result := query.Order("id desc").Limit(limit).Find(&events)
+	return events, result.Error
+}
+
+// VerifyAuditLog verifies the whole audit chain and its head, and returns the number of verified events.
+func (d *Database) VerifyAuditLog(key []byte) (int, error) {
+	prevHash := ""
+	lastID := 0
+	verified := 0
+
+	for {
+		var events []models.AuditEvent
+		result := d.db.Where("id > ?", lastID).Order("id asc").Limit(auditVerifyBatchSize).Find(&events)
+		if result.Error != nil {
+			return verified, result.Error
+		}
+		if len(events) == 0 {
+			return verified, d.verifyAuditHead(lastID, prevHash, key)
+		}
+
+		hash, err := VerifyAuditChain(events, prevHash, key)
+		if err != nil {
+			return verified, err
+		}
+
+		prevHash = hash
+		lastID = events[len(events)-1].ID
+		verified += len(events)
+	}
+}
+
+// verifyAuditHead checks the stored head points to the last verified event.
+func (d *Database) verifyAuditHead(lastID int, lastHash string, key []byte) error {
+	var heads []models.AuditHead
+	if err := d.db.Where("id = ?", auditHeadID).Limit(1).Find(&heads).Error; err != nil {
+		return err
+	}
+	if len(heads) == 0 {
+		return VerifyAuditHead(nil, lastID, lastHash, key)
+	}
+	return VerifyAuditHead(&heads[0], lastID, lastHash, key)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var auditKey = AuditChainKey("abc")
+
+// Build a chain of audit events as AppendAuditEvent does.
+func buildAuditChain(n int) []models.AuditEvent {
+	events := []models.AuditEvent{}
+	prevHash := ""
+	for i := 1; i <= n; i++ {
+		event := models.AuditEvent{
+			ID:        i,
+			CreatedAt: time.Unix(int64(1690000000+i), 0),
+			ActorID:   1,
+			Action:    "GET /api/v1/projects/{projectID}/env/{envID}",
+			ProjectID: 2,
+			KeyName:   "DATABASE_URL",
+			SourceIP:  "10.0.0.1",
+			Result:    models.AuditSuccess,
+			Status:    200,
+			PrevHash:  prevHash,
+		}
+		event.Hash = ComputeAuditHash(event, prevHash, auditKey)
+		prevHash = event.Hash
+		events = append(events, event)
+	}
+	return events
+}
+
+// Test verifying the audit hash chain.
+func TestVerifyAuditChain(t *testing.T) {
+	t.Run("valid chain", func(t *testing.T) {
+		events := buildAuditChain(5)
+		last, err := VerifyAuditChain(events, "", auditKey)
+		assert.NoError(t, err)
+		assert.Equal(t, events[4].Hash, last)
+	})
+
+	t.Run("valid chain verified in batches", func(t *testing.T) {
+		events := buildAuditChain(5)
+		prev, err := VerifyAuditChain(events[:2], "", auditKey)
+		assert.NoError(t, err)
+		_, err = VerifyAuditChain(events[2:], prev, auditKey)
+		assert.NoError(t, err)
+	})
+
+	t.Run("modified event", func(t *testing.T) {
+		events := buildAuditChain(5)
+		events[2].ActorID = 3
+		_, err := VerifyAuditChain(events, "", auditKey)
+		assert.EqualError(t, err, "audit event 3 was modified")
+	})
+
+	t.Run("deleted event", func(t *testing.T) {
+		events := buildAuditChain(5)
+		events = append(events[:1], events[2:]...)
+		_, err := VerifyAuditChain(events, "", auditKey)
+		assert.EqualError(t, err, "audit event 3 is not chained to the previous event")
+	})
+	t.Run("chain rewritten without the key", func(t *testing.T) {
+		events := buildAuditChain(5)
+		_, err := VerifyAuditChain(events, "", AuditChainKey("other"))
+		assert.EqualError(t, err, "audit event 1 was modified")
+	})
+}
+
+// Test verifying the head of the audit chain.
+func TestVerifyAuditHead(t *testing.T) {
+	events := buildAuditChain(5)
+	last := events[4]
+	head := models.AuditHead{EventID: last.ID, Hash: last.Hash, Signature: ComputeAuditHeadSignature(last.ID, last.Hash, auditKey)}
+
+	t.Run("valid head", func(t *testing.T) {
+		assert.NoError(t, VerifyAuditHead(&head, last.ID, last.Hash, auditKey))
+		assert.NoError(t, VerifyAuditHead(nil, 0, "", auditKey))
+	})
+
+	t.Run("deleted newest events", func(t *testing.T) {
+		err := VerifyAuditHead(&head, events[2].ID, events[2].Hash, auditKey)
+		assert.EqualError(t, err, "the audit chain ends at event 3 but its head is event 5, events were deleted or added")
+	})
+
+	t.Run("head moved without the key", func(t *testing.T) {
+		moved := models.AuditHead{EventID: events[2].ID, Hash: events[2].Hash, Signature: head.Signature}
+		assert.EqualError(t, VerifyAuditHead(&moved, events[2].ID, events[2].Hash, auditKey), "the audit head was modified")
+	})
+
+	t.Run("missing head", func(t *testing.T) {
+		assert.EqualError(t, VerifyAuditHead(nil, last.ID, last.Hash, auditKey), "the audit head is missing")
+	})
+}
@@ -0,0 +1,372 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// SyslogAuditSink forwards audit events to a syslog server in the RFC 5424 format.
+	SyslogAuditSink = "syslog"
+	// FileAuditSink appends audit events as JSON lines to a rotated file.
+	FileAuditSink = "file"
+	// StdoutAuditSink writes audit events as JSON lines to stdout.
+	StdoutAuditSink = "stdout"
+
+	// DefaultAuditBufferSize is the number of events buffered per sink before they are dropped.
+	DefaultAuditBufferSize = 1024
+	// DefaultAuditFileMaxSize is the size in megabytes after which the audit file is rotated.
+	DefaultAuditFileMaxSize = 100
+	// DefaultAuditFileMaxBackups is the number of rotated audit files kept.
+	DefaultAuditFileMaxBackups = 5
+
+	// syslogFacilityAudit is the "log audit" syslog facility.
+	syslogFacilityAudit = 13
+	syslogSeverityInfo  = 6
+	syslogSeverityWarn  = 4
+	syslogAppName       = "envserver"
+	syslogDialTimeout   = 5 * time.Second
+	syslogWriteTimeout  = 5 * time.Second
+)
+
+// AuditSink receives the audit events, it's called by a single goroutine.
+type AuditSink interface {
+	Write(event models.AuditEvent) error
+	Close() error
+}
+
+// AuditSinkStats reports the back-pressure of a sink.
+type AuditSinkStats struct {
+	Sink    string `json:"sink"`
+	Queued  int    `json:"queued"`
+	Written uint64 `json:"written"`
+	Failed  uint64 `json:"failed"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// AuditStreamer forwards the audit events to the configured sinks in the background.
+// Every sink has its own bounded queue, events are dropped when it's full so a slow sink never blocks request handling.
+type AuditStreamer struct {
+	workers []*sinkWorker
+	wg      sync.WaitGroup
+	// mu is held for reading while the events are queued and for writing while the queues are closed,
+	// so that Publish never sends on a closed queue.
+	mu     sync.RWMutex
+	closed bool
+}
+
+type sinkWorker struct {
+	name    string
+	sink    AuditSink
+	queue   chan models.AuditEvent
+	written atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// NewAuditStreamer creates the configured sinks and starts forwarding events to them.
+func NewAuditStreamer(config AuditConfig) (*AuditStreamer, error) {
+	bufferSize := config.BufferSize
+	if bufferSize == 0 {
+		bufferSize = DefaultAuditBufferSize
+	}
+
+	streamer := &AuditStreamer{}
+	for _, sinkConfig := range config.Sinks {
+		sink, err := NewAuditSink(sinkConfig)
+		if err != nil {
+			streamer.Close()
+			return nil, err
+		}
+		streamer.AddSink(sinkConfig.Name(), sink, bufferSize)
+	}
+	return streamer, nil
+}
+
+// NewAuditSink creates the sink described by the config.
+func NewAuditSink(config AuditSinkConfig) (AuditSink, error) {
+	switch config.Type {
+	case SyslogAuditSink:
+		return NewSyslogSink(config.Network, config.Address), nil
+	case FileAuditSink:
+		maxSize := config.MaxSize
+		if maxSize == 0 {
+			maxSize = DefaultAuditFileMaxSize
+		}
+		maxBackups := config.MaxBackups
+		if maxBackups == 0 {
+			maxBackups = DefaultAuditFileMaxBackups
+		}
+		return NewFileSink(config.Path, int64(maxSize)*1024*1024, maxBackups)
+	case StdoutAuditSink:
+		return NewWriterSink(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink type %q", config.Type)
+	}
+}
+
+// AddSink starts forwarding events to the sink through a queue of the given size.
+func (s *AuditStreamer) AddSink(name string, sink AuditSink, bufferSize int) {
+	worker := &sinkWorker{
+		name:  name,
+		sink:  sink,
+		queue: make(chan models.AuditEvent, bufferSize),
+	}
+	s.workers = append(s.workers, worker)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		worker.run()
+	}()
+}
+
+func (w *sinkWorker) run() {
+	for event := range w.queue {
+		if err := w.sink.Write(event); err != nil {
+			w.failed.Add(1)
+			log.Error().Msgf("Failed to write audit event %d to sink %s: %v", event.ID, w.name, err)
+			continue
+		}
+		w.written.Add(1)
+	}
+
+	if err := w.sink.Close(); err != nil {
+		log.Error().Msgf("Failed to close audit sink %s: %v", w.name, err)
+	}
+}
+
+// Publish queues the event for every sink without blocking, the event is dropped for the sinks whose queue is full.
+func (s *AuditStreamer) Publish(event models.AuditEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+
+	for _, worker := range s.workers {
+		select {
+		case worker.queue <- event:
+		default:
+			worker.dropped.Add(1)
+		}
+	}
+}
+
+// Stats returns the back-pressure stats of every sink.
+func (s *AuditStreamer) Stats() []AuditSinkStats {
+	stats := make([]AuditSinkStats, 0, len(s.workers))
+	for _, worker := range s.workers {
+		stats = append(stats, AuditSinkStats{
+			Sink:    worker.name,
+			Queued:  len(worker.queue),
+			Written: worker.written.Load(),
+			Failed:  worker.failed.Load(),
+			Dropped: worker.dropped.Load(),
+		})
+	}
+	return stats
+}
+
+// Close stops accepting events, flushes the queued ones and closes the sinks.
+func (s *AuditStreamer) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	for _, worker := range s.workers {
+		close(worker.queue)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// WriterSink writes audit events as JSON lines to a writer, e.g. stdout.
+type WriterSink struct {
+	w io.Writer
+}
+
+// NewWriterSink creates a new WriterSink.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(event models.AuditEvent) error {
+	return json.NewEncoder(s.w).Encode(event)
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink appends audit events as JSON lines to a file, rotated when it exceeds its max size.
+// Rotated files are renamed path.1, path.2... up to maxBackups, the oldest one is removed.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewFileSink opens the audit file for appending.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	sink := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) Write(event models.AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		src := fmt.Sprintf("%s.%d", s.path, i)
+		if _, err := os.Stat(src); err == nil {
+			if err := os.Rename(src, fmt.Sprintf("%s.%d", s.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.maxBackups > 0 {
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// SyslogSink forwards audit events to a syslog server over UDP or TCP in the RFC 5424 format.
+// TCP messages are framed with octet counting (RFC 6587), the connection is reopened after a failure.
+type SyslogSink struct {
+	network  string
+	address  string
+	hostname string
+	conn     net.Conn
+	// writeTimeout bounds every write, so that a stalled server doesn't block the sink forever.
+	writeTimeout time.Duration
+}
+
+// NewSyslogSink creates a new SyslogSink, the connection is opened on the first event.
+func NewSyslogSink(network, address string) *SyslogSink {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogSink{network: network, address: address, hostname: hostname, writeTimeout: syslogWriteTimeout}
+}
+
+func (s *SyslogSink) Write(event models.AuditEvent) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, syslogDialTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	message, err := FormatSyslogMessage(event, s.hostname)
+	if err != nil {
+		return err
+	}
+
+	if s.network == "tcp" {
+		message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+
+	// The connection is closed on a failed or timed out write, the next event opens a new one.
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	if _, err := s.conn.Write(message); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// FormatSyslogMessage formats the audit event as an RFC 5424 message whose body is the JSON encoded event.
+func FormatSyslogMessage(event models.AuditEvent, hostname string) ([]byte, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	severity := syslogSeverityInfo
+	if event.Result == models.AuditFailure {
+		severity = syslogSeverityWarn
+	}
+
+	header := fmt.Sprintf(
+		"<%d>1 %s %s %s %d audit - ",
+		syslogFacilityAudit*8+severity, event.CreatedAt.UTC().Format(time.RFC3339Nano), hostname, syslogAppName, os.Getpid(),
+	)
+	return append([]byte(header), body...), nil
+}
@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingSink blocks every write until it's released, used to test back-pressure.
+type blockingSink struct {
+	release chan struct{}
+	events  []models.AuditEvent
+}
+
+func (s *blockingSink) Write(event models.AuditEvent) error {
+	<-s.release
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+func auditEvent(id int) models.AuditEvent {
+	return models.AuditEvent{
+		ID:        id,
+		CreatedAt: time.Unix(1690000000, 0),
+		ActorID:   1,
+		Action:    "GET /api/v1/projects/{id}/env",
+		ProjectID: 2,
+		Result:    models.AuditSuccess,
+		Status:    200,
+	}
+}
+
+// Test a slow sink never blocks publishing and reports the dropped events.
+func TestAuditStreamerBackPressure(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	streamer := &AuditStreamer{}
+	streamer.AddSink("blocking", sink, 2)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 10; i++ {
+			streamer.Publish(auditEvent(i))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishing blocked on a slow sink")
+	}
+
+	stats := streamer.Stats()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "blocking", stats[0].Sink)
+	// One event is held by the blocked worker and two are queued.
+	assert.GreaterOrEqual(t, stats[0].Dropped, uint64(7))
+
+	close(sink.release)
+	streamer.Close()
+
+	stats = streamer.Stats()
+	assert.Equal(t, uint64(10), stats[0].Written+stats[0].Dropped)
+	assert.Equal(t, int(stats[0].Written), len(sink.events))
+}
+
+// Test publishing while the streamer is closed never sends on a closed queue.
+func TestAuditStreamerPublishWhileClosing(t *testing.T) {
+	var buffer bytes.Buffer
+	streamer := &AuditStreamer{}
+	streamer.AddSink("writer", NewWriterSink(&buffer), 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 1000; i++ {
+			streamer.Publish(auditEvent(i))
+		}
+	}()
+	streamer.Close()
+	<-done
+
+	stats := streamer.Stats()
+	assert.LessOrEqual(t, stats[0].Written+stats[0].Dropped, uint64(1000))
+}
+
+// Test the writer sink writes JSON lines.
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	assert.NoError(t, sink.Write(auditEvent(1)))
+	assert.NoError(t, sink.Write(auditEvent(2)))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var event models.AuditEvent
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, 2, event.ID)
+}
+
+// Test the file sink rotates the file when it exceeds its max size.
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	line, err := json.Marshal(auditEvent(1))
+	assert.NoError(t, err)
+
+	// Two events fit in a file.
+	sink, err := NewFileSink(path, int64(2*(len(line)+1)), 2)
+	assert.NoError(t, err)
+
+	for i := 1; i <= 7; i++ {
+		assert.NoError(t, sink.Write(auditEvent(i)))
+	}
+	assert.NoError(t, sink.Close())
+
+	countLines := func(path string) int {
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		return len(strings.Split(strings.TrimSpace(string(content)), "\n"))
+	}
+
+	assert.Equal(t, 1, countLines(path))
+	assert.Equal(t, 2, countLines(path+".1"))
+	assert.Equal(t, 2, countLines(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+// Test the syslog sink sends RFC 5424 messages over UDP and TCP.
+func TestSyslogSink(t *testing.T) {
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		sink := NewSyslogSink("udp", conn.LocalAddr().String())
+		defer sink.Close()
+		assert.NoError(t, sink.Write(auditEvent(1)))
+
+		buf := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		assert.NoError(t, err)
+
+		message := string(buf[:n])
+		assert.True(t, strings.HasPrefix(message, "<110>1 2023-07-22T04:26:40Z "))
+		assert.Contains(t, message, " envserver ")
+		assert.Contains(t, message, ` audit - {"id":1,`)
+	})
+
+	t.Run("tcp with octet counting", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		received := make(chan string, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			line, _ := bufio.NewReader(conn).ReadString('}')
+			received <- line
+		}()
+
+		sink := NewSyslogSink("tcp", listener.Addr().String())
+		event := auditEvent(2)
+		event.Result = models.AuditFailure
+		assert.NoError(t, sink.Write(event))
+
+		select {
+		case message := <-received:
+			assert.Regexp(t, `^\d+ <108>1 `, message)
+		case <-time.After(time.Second):
+			t.Fatal("syslog message not received")
+		}
+		assert.NoError(t, sink.Close())
+	})
+	t.Run("reconnects after a write timeout", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		received := make(chan string, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			line, _ := bufio.NewReader(conn).ReadString('}')
+			received <- line
+		}()
+
+		sink := NewSyslogSink("tcp", listener.Addr().String())
+		sink.writeTimeout = 50 * time.Millisecond
+		// A server that never reads, the writes of a pipe block until they're read.
+		stalled, server := net.Pipe()
+		defer server.Close()
+		sink.conn = stalled
+
+		err = sink.Write(auditEvent(3))
+		var netErr net.Error
+		assert.ErrorAs(t, err, &netErr)
+		assert.True(t, netErr.Timeout())
+
+		assert.NoError(t, sink.Write(auditEvent(4)))
+		select {
+		case message := <-received:
+			assert.Contains(t, message, `{"id":4,`)
+		case <-time.After(time.Second):
+			t.Fatal("syslog message not received after reconnecting")
+		}
+		assert.NoError(t, sink.Close())
+	})
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultChangeHistorySize is the number of recent changes kept to resume the streams of reconnecting clients.
+	DefaultChangeHistorySize = 1024
+	// changeSubscriptionBuffer is the number of changes buffered per subscriber before it's disconnected.
+	changeSubscriptionBuffer = 64
+)
+
+// EnvChange describes a change of a project env key, it never contains the key value.
+type EnvChange struct {
+	ID         uint64    `json:"id"`
+	ProjectID  int       `json:"project_id"`
+	Key        string    `json:"key"`
+	Version    int       `json:"version"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// ChangeBroker fans out the env changes to the subscribers of their project.
+// It keeps the latest changes so that clients can resume a stream from the last change they received.
+// Change ids are seeded from the startup time, so the ids received before a restart are older than the history.
+type ChangeBroker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []EnvChange
+	historySize int
+	subscribers map[int]map[*ChangeSubscription]struct{}
+	closed      bool
+}
+
+// ChangeSubscription receives the changes of a project until it's closed.
+// The channel is closed when the subscriber is too slow to keep up or the broker is closed, it should resume from its last change.
+type ChangeSubscription struct {
+	broker    *ChangeBroker
+	projectID int
+	changes   chan EnvChange
+	closed    bool
+}
+
+// NewChangeBroker creates a broker keeping the given number of recent changes.
+func NewChangeBroker(historySize int) *ChangeBroker {
+	if historySize <= 0 {
+		historySize = DefaultChangeHistorySize
+	}
+	return &ChangeBroker{
+		lastID:      uint64(time.Now().UnixMicro()),
+		historySize: historySize,
+		subscribers: map[int]map[*ChangeSubscription]struct{}{},
+	}
+}
+
+// Publish assigns the next id to the change and sends it to the subscribers of its project.
+func (b *ChangeBroker) Publish(change EnvChange) EnvChange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	change.ID = b.lastID
+	if change.OccurredAt.IsZero() {
+		change.OccurredAt = time.Now().UTC()
+	}
+
+	b.history = append(b.history, change)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for subscription := range b.subscribers[change.ProjectID] {
+		select {
+		case subscription.changes <- change:
+		default:
+			subscription.close()
+		}
+	}
+	return change
+}
+
+// Subscribe starts receiving the changes of a project.
+// If lastID is set, the changes of the project published after it are returned to be replayed first,
+// resumed is false if they are no longer in the history and the client must reload the project env.
+func (b *ChangeBroker) Subscribe(projectID int, lastID uint64) (subscription *ChangeSubscription, missed []EnvChange, resumed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	resumed = true
+	if lastID > 0 {
+		resumed = lastID <= b.lastID && len(b.history) > 0 && lastID >= b.history[0].ID-1
+		if resumed {
+			for _, change := range b.history {
+				if change.ID > lastID && change.ProjectID == projectID {
+					missed = append(missed, change)
+				}
+			}
+		}
+	}
+
+	subscription = &ChangeSubscription{
+		broker:    b,
+		projectID: projectID,
+		changes:   make(chan EnvChange, changeSubscriptionBuffer),
+	}
+	if b.subscribers[projectID] == nil {
+		b.subscribers[projectID] = map[*ChangeSubscription]struct{}{}
+	}
+	b.subscribers[projectID][subscription] = struct{}{}
+	if b.closed {
+		subscription.close()
+	}
+	return subscription, missed, resumed
+}
+
+// Close closes every subscription, and the ones started later, e.g. to end the streams on shutdown.
+func (b *ChangeBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, subscriptions := range b.subscribers {
+		for subscription := range subscriptions {
+			subscription.close()
+		}
+	}
+}
+
+// Changes returns the channel receiving the changes.
+func (s *ChangeSubscription) Changes() <-chan EnvChange {
+	return s.changes
+}
+
+// Close stops receiving changes.
+func (s *ChangeSubscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.close()
+}
+
+func (s *ChangeSubscription) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.changes)
+
+	subscribers := s.broker.subscribers[s.projectID]
+	delete(subscribers, s)
+	if len(subscribers) == 0 {
+		delete(s.broker.subscribers, s.projectID)
+	}
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"testing"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test publishing the env changes to the project subscribers.
+func TestChangeBroker(t *testing.T) {
+	t.Run("subscribers receive the changes of their project", func(t *testing.T) {
+		broker := NewChangeBroker(10)
+		subscription, missed, resumed := broker.Subscribe(1, 0)
+		defer subscription.Close()
+		assert.True(t, resumed)
+		assert.Empty(t, missed)
+
+		broker.Publish(EnvChange{ProjectID: 2, Key: "OTHER", Version: 1, Type: models.EnvCreatedEvent})
+		published := broker.Publish(EnvChange{ProjectID: 1, Key: "DATABASE_URL", Version: 2, Type: models.EnvUpdatedEvent})
+
+		change := <-subscription.Changes()
+		assert.Equal(t, published, change)
+		assert.Equal(t, "DATABASE_URL", change.Key)
+		assert.Equal(t, 2, change.Version)
+		assert.NotZero(t, change.ID)
+		assert.False(t, change.OccurredAt.IsZero())
+		assert.Empty(t, subscription.Changes())
+	})
+
+	t.Run("resume from the last event id", func(t *testing.T) {
+		broker := NewChangeBroker(10)
+		first := broker.Publish(EnvChange{ProjectID: 1, Key: "A", Type: models.EnvCreatedEvent})
+		broker.Publish(EnvChange{ProjectID: 2, Key: "B", Type: models.EnvCreatedEvent})
+		third := broker.Publish(EnvChange{ProjectID: 1, Key: "C", Type: models.EnvDeletedEvent})
+
+		subscription, missed, resumed := broker.Subscribe(1, first.ID)
+		defer subscription.Close()
+		assert.True(t, resumed)
+		assert.Equal(t, []EnvChange{third}, missed)
+
+		subscription, missed, resumed = broker.Subscribe(1, third.ID)
+		defer subscription.Close()
+		assert.True(t, resumed)
+		assert.Empty(t, missed)
+	})
+
+	t.Run("reset when the last event id is no longer in the history", func(t *testing.T) {
+		broker := NewChangeBroker(2)
+		first := broker.Publish(EnvChange{ProjectID: 1, Key: "A", Type: models.EnvCreatedEvent})
+		broker.Publish(EnvChange{ProjectID: 1, Key: "B", Type: models.EnvCreatedEvent})
+		broker.Publish(EnvChange{ProjectID: 1, Key: "C", Type: models.EnvCreatedEvent})
+		broker.Publish(EnvChange{ProjectID: 1, Key: "D", Type: models.EnvCreatedEvent})
+
+		subscription, missed, resumed := broker.Subscribe(1, first.ID)
+		defer subscription.Close()
+		assert.False(t, resumed)
+		assert.Empty(t, missed)
+
+		// An id from before a restart is unknown to a new broker.
+		restarted := NewChangeBroker(2)
+		subscription, _, resumed = restarted.Subscribe(1, first.ID)
+		defer subscription.Close()
+		assert.False(t, resumed)
+	})
+
+	t.Run("slow subscribers are disconnected", func(t *testing.T) {
+		broker := NewChangeBroker(10)
+		subscription, _, _ := broker.Subscribe(1, 0)
+
+		for i := 0; i <= changeSubscriptionBuffer; i++ {
+			broker.Publish(EnvChange{ProjectID: 1, Key: "A", Type: models.EnvUpdatedEvent})
+		}
+
+		received := 0
+		for range subscription.Changes() {
+			received++
+		}
+		assert.Equal(t, changeSubscriptionBuffer, received)
+		assert.Empty(t, broker.subscribers)
+
+		// Closing an already closed subscription is a no-op.
+		subscription.Close()
+	})
+
+	t.Run("closing the broker ends the subscriptions", func(t *testing.T) {
+		broker := NewChangeBroker(10)
+		subscription, _, _ := broker.Subscribe(1, 0)
+
+		broker.Close()
+		_, ok := <-subscription.Changes()
+		assert.False(t, ok)
+		assert.Empty(t, broker.subscribers)
+
+		late, _, _ := broker.Subscribe(1, 0)
+		_, ok = <-late.Changes()
+		assert.False(t, ok)
+	})
+}
@@ -1,22 +1,111 @@
 package internal
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/rs/zerolog"
+)
+
+// Defaults of the HTTP server.
+const (
+	DefaultShutdownTimeout   = 30 * time.Second
+	DefaultShutdownDelay     = 5 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	// DefaultMaxBodySize is the maximum size of a request body in bytes.
+	DefaultMaxBodySize = 1 << 20
 )
 
 type Config struct {
-	Database DatabaseConfig `toml:"database"`
-	Server   ServerConfig   `toml:"server"`
+	Database  DatabaseConfig  `toml:"database"`
+	Server    ServerConfig    `toml:"server"`
+	Mail      MailConfig      `toml:"mail"`
+	Audit     AuditConfig     `toml:"audit"`
+	Webhooks  WebhookConfig   `toml:"webhooks"`
+	Metrics   MetricsConfig   `toml:"metrics"`
+	Tracing   TracingConfig   `toml:"tracing"`
+	Log       LogConfig       `toml:"log"`
+	RateLimit RateLimitConfig `toml:"rate_limit"`
+	CORS      CORSConfig      `toml:"cors"`
 }
 
 type ServerConfig struct {
-	Host            string `toml:"host"`
-	Port            int    `toml:"port"`
-	JWTSecretKey    string `toml:"jwt_secret_key"`
+	Host         string `toml:"host"`
+	Port         int    `toml:"port"`
+	JWTSecretKey string `toml:"jwt_secret_key"`
+	// Key encrypting the env values at rest, the stored values can't be read with another key.
+	EncryptionKey   string `toml:"encryption_key"`
 	ShutdownTimeout int    `toml:"shutdown_timeout"`
+	// Seconds the readiness probe fails before the server stops accepting requests.
+	ShutdownDelay int `toml:"shutdown_delay"`
+	// HTTP server timeouts in seconds, they don't apply to the live env streams.
+	ReadHeaderTimeout int `toml:"read_header_timeout"`
+	ReadTimeout       int `toml:"read_timeout"`
+	WriteTimeout      int `toml:"write_timeout"`
+	IdleTimeout       int `toml:"idle_timeout"`
+	// Maximum size of a request body in bytes.
+	MaxBodySize int64 `toml:"max_body_size"`
+	// Port of the gRPC API, it's disabled if it's not set.
+	GRPCPort int `toml:"grpc_port"`
+	// Block the signin of users who didn't verify their email address.
+	RequireEmailVerification bool `toml:"require_email_verification"`
+	// Brute-force protection, durations are in seconds and unset values use the defaults.
+	MaxFailedSignins      int `toml:"max_failed_signins"`
+	MaxFailedSigninsPerIP int `toml:"max_failed_signins_per_ip"`
+	LockoutDuration       int `toml:"lockout_duration"`
+	MaxLockoutDuration    int `toml:"max_lockout_duration"`
+	// Lifetime of the access tokens in seconds, 24 hours by default.
+	AccessTokenTTL int `toml:"access_token_ttl"`
+	// Maximum number of operations of a bulk env request.
+	MaxEnvBatchSize int `toml:"max_env_batch_size"`
+	// Asymmetric signing keys, tokens are signed with HS256 and jwt_secret_key if it's empty.
+	JWTKeys []JWTKeyConfig `toml:"jwt_keys"`
+	// Emails of the users granted the site administrator role.
+	Admins []string `toml:"admins"`
+	// Addresses and CIDR ranges of the reverse proxies whose X-Forwarded-For header holds the client address.
+	TrustedProxies []string `toml:"trusted_proxies"`
+	// TLS termination of the API, it's served in plaintext if no certificate is set.
+	TLS TLSConfig `toml:"tls"`
+}
+
+// TLSConfig configures the certificate of the API and the optional client certificate authentication (mTLS).
+type TLSConfig struct {
+	// PEM certificate chain and private key, they're reloaded when the files change.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	// Minimum TLS version, "1.2" by default.
+	MinVersion string `toml:"min_version"`
+	// PEM certificates of the authorities issuing the client certificates, client certificates are ignored if it's empty.
+	ClientCAFile string `toml:"client_ca_file"`
+	// Reject the connections without a valid client certificate, they're optional by default.
+	RequireClientCert bool `toml:"require_client_cert"`
+	// Services allowed to authenticate with a client certificate instead of a JWT.
+	ClientIdentities []ClientIdentityConfig `toml:"client_identities"`
+}
+
+// ClientIdentityConfig maps the client certificates of a service to the user it acts as.
+type ClientIdentityConfig struct {
+	// Name of the service, used in logs and rate limits.
+	Name string `toml:"name"`
+	// Common name, DNS name or URI of the client certificates, e.g. "spiffe://example.com/deployer".
+	Subject string `toml:"subject"`
+	// Email of the user account of the service.
+	UserEmail string `toml:"user_email"`
+}
+
+// JWTKeyConfig is an RSA or Ed25519 key used to sign the JWT tokens.
+// The first key without retired_at signs new tokens, retired keys keep verifying tokens until they expire.
+type JWTKeyConfig struct {
+	ID             string    `toml:"kid"`
+	PrivateKeyFile string    `toml:"private_key_file"`
+	RetiredAt      time.Time `toml:"retired_at"`
 }
 
 type DatabaseConfig struct {
@@ -27,18 +116,215 @@ type DatabaseConfig struct {
 	Name     string `toml:"name"`
 }
 
-// Read the config file.
-func ReadConfigFromFile(path string) (Config, error) {
-	config := Config{}
-	_, err := toml.DecodeFile(path, &config)
-	if err != nil {
-		return Config{}, cantLoadConfigFileError
+type MailConfig struct {
+	Backend  string `toml:"backend"` // "smtp" or "log", defaults to "log".
+	Host     string `toml:"host"`
+	Port     int    `toml:"port"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	From     string `toml:"from"`
+	LogFile  string `toml:"log_file"` // File used by the log backend, emails are logged if it's empty.
+	BaseURL  string `toml:"base_url"` // URL of the dashboard, used to build the links sent in emails.
+}
+
+type AuditConfig struct {
+	BufferSize int               `toml:"buffer_size"` // Events buffered per sink before they are dropped, 1024 by default.
+	Sinks      []AuditSinkConfig `toml:"sinks"`
+}
+
+// AuditSinkConfig describes where audit events are streamed.
+type AuditSinkConfig struct {
+	Type       string `toml:"type"`        // "syslog", "file" or "stdout".
+	Network    string `toml:"network"`     // "udp" or "tcp", for syslog sinks.
+	Address    string `toml:"address"`     // host:port, for syslog sinks.
+	Path       string `toml:"path"`        // For file sinks.
+	MaxSize    int    `toml:"max_size"`    // Size in megabytes after which the file is rotated, 100 by default.
+	MaxBackups int    `toml:"max_backups"` // Rotated files kept, 5 by default.
+}
+
+type WebhookConfig struct {
+	MaxAttempts  int `toml:"max_attempts"`  // Attempts before a delivery is marked as failed, 8 by default.
+	Timeout      int `toml:"timeout"`       // Timeout of a webhook call in seconds, 10 by default.
+	PollInterval int `toml:"poll_interval"` // Interval between two checks of the delivery queue in seconds, 5 by default.
+}
+
+type MetricsConfig struct {
+	// Address serving the Prometheus metrics at /metrics, e.g. "127.0.0.1:9100", the metrics aren't served if it's empty.
+	Address string `toml:"address"`
+}
+
+// TracingConfig configures the export of the traces to an OTLP/HTTP collector, nothing is exported if the endpoint is empty.
+type TracingConfig struct {
+	Endpoint    string            `toml:"endpoint"` // host:port of the collector, e.g. "localhost:4318".
+	URLPath     string            `toml:"url_path"` // "/v1/traces" by default.
+	Insecure    bool              `toml:"insecure"` // Export over plain HTTP instead of HTTPS.
+	Headers     map[string]string `toml:"headers"`
+	ServiceName string            `toml:"service_name"`
+	// Ratio of the traces started by envserver that are sampled, all of them by default.
+	// Traces started by the callers follow their sampling decision.
+	SampleRatio float64 `toml:"sample_ratio"`
+}
+
+// RateLimitConfig configures the token buckets limiting the requests of every user, token or client IP.
+// The auth endpoints and the secret reads have their own budgets, unset values use the defaults.
+type RateLimitConfig struct {
+	Disabled bool                  `toml:"disabled"`
+	Default  RateLimitBudgetConfig `toml:"default"`
+	Auth     RateLimitBudgetConfig `toml:"auth"`
+	Secrets  RateLimitBudgetConfig `toml:"secrets"`
+}
+
+// RateLimitBudgetConfig is the sustained rate and the burst size of a rate limit budget.
+type RateLimitBudgetConfig struct {
+	RequestsPerMinute int `toml:"requests_per_minute"`
+	Burst             int `toml:"burst"`
+}
+
+// CORSConfig configures the cross-origin requests of the browser dashboard, they're denied unless their origin is allowed.
+type CORSConfig struct {
+	// Origins allowed to call the API, e.g. "https://dashboard.example.com", "*" allows every origin.
+	AllowedOrigins []string `toml:"allowed_origins"`
+	// Methods and request headers allowed in the preflight requests, unset values use the defaults.
+	AllowedMethods []string `toml:"allowed_methods"`
+	AllowedHeaders []string `toml:"allowed_headers"`
+	// Allow the browsers to send cookies and client certificates, it can't be used with the "*" origin.
+	AllowCredentials bool `toml:"allow_credentials"`
+	// Seconds the browsers cache the preflight responses, they aren't cached if it's not set.
+	MaxAge int `toml:"max_age"`
+}
+
+// LogConfig configures the server logs.
+type LogConfig struct {
+	Level  string `toml:"level"`  // zerolog level, "info" by default.
+	Format string `toml:"format"` // "console" or "json", "console" by default.
+}
+
+// LevelOrDefault returns the minimum level of the logged events.
+func (l LogConfig) LevelOrDefault() zerolog.Level {
+	level, err := zerolog.ParseLevel(l.Level)
+	if err != nil || l.Level == "" {
+		return zerolog.InfoLevel
 	}
-	err = config.validateConfig()
-	if err != nil {
-		return Config{}, err
+	return level
+}
+
+// FormatOrDefault returns the format of the logs.
+func (l LogConfig) FormatOrDefault() string {
+	if l.Format == "" {
+		return LogFormatConsole
+	}
+	return l.Format
+}
+
+// Name returns a readable name of the sink, used in logs and stats.
+func (s AuditSinkConfig) Name() string {
+	switch s.Type {
+	case SyslogAuditSink:
+		return fmt.Sprintf("syslog+%s://%s", s.Network, s.Address)
+	case FileAuditSink:
+		return "file://" + s.Path
+	default:
+		return s.Type
 	}
-	return config, nil
+}
+
+// ShutdownTimeoutOrDefault returns the time given to the in-flight requests to finish on shutdown.
+func (s ServerConfig) ShutdownTimeoutOrDefault() time.Duration {
+	return secondsOrDefault(s.ShutdownTimeout, DefaultShutdownTimeout)
+}
+
+// ShutdownDelayOrDefault returns the time the readiness probe fails on shutdown before the server stops accepting requests,
+// so that the load balancers stop routing requests to it first.
+func (s ServerConfig) ShutdownDelayOrDefault() time.Duration {
+	return secondsOrDefault(s.ShutdownDelay, DefaultShutdownDelay)
+}
+
+// ReadHeaderTimeoutOrDefault returns the time allowed to read the headers of a request.
+func (s ServerConfig) ReadHeaderTimeoutOrDefault() time.Duration {
+	return secondsOrDefault(s.ReadHeaderTimeout, DefaultReadHeaderTimeout)
+}
+
+// ReadTimeoutOrDefault returns the time allowed to read a whole request.
+func (s ServerConfig) ReadTimeoutOrDefault() time.Duration {
+	return secondsOrDefault(s.ReadTimeout, DefaultReadTimeout)
+}
+
+// WriteTimeoutOrDefault returns the time allowed to handle a request and write its response.
+func (s ServerConfig) WriteTimeoutOrDefault() time.Duration {
+	return secondsOrDefault(s.WriteTimeout, DefaultWriteTimeout)
+}
+
+// IdleTimeoutOrDefault returns the time a keep-alive connection waits for the next request.
+func (s ServerConfig) IdleTimeoutOrDefault() time.Duration {
+	return secondsOrDefault(s.IdleTimeout, DefaultIdleTimeout)
+}
+
+// MaxBodySizeOrDefault returns the maximum size of a request body in bytes.
+func (s ServerConfig) MaxBodySizeOrDefault() int64 {
+	if s.MaxBodySize == 0 {
+		return DefaultMaxBodySize
+	}
+	return s.MaxBodySize
+}
+
+func secondsOrDefault(seconds int, defaultDuration time.Duration) time.Duration {
+	if seconds == 0 {
+		return defaultDuration
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// MaxFailedSigninsOrDefault returns the number of failed signins before an account is locked.
+func (s ServerConfig) MaxFailedSigninsOrDefault() int {
+	if s.MaxFailedSignins == 0 {
+		return DefaultMaxFailedSignins
+	}
+	return s.MaxFailedSignins
+}
+
+// MaxFailedSigninsPerIPOrDefault returns the number of failed signins before an IP is locked.
+func (s ServerConfig) MaxFailedSigninsPerIPOrDefault() int {
+	if s.MaxFailedSigninsPerIP == 0 {
+		return DefaultMaxFailedSigninsPerIP
+	}
+	return s.MaxFailedSigninsPerIP
+}
+
+// LockoutDurationOrDefault returns the duration of the first lockout.
+func (s ServerConfig) LockoutDurationOrDefault() time.Duration {
+	if s.LockoutDuration == 0 {
+		return DefaultLockoutDuration
+	}
+	return time.Duration(s.LockoutDuration) * time.Second
+}
+
+// MaxLockoutDurationOrDefault returns the maximum lockout duration.
+func (s ServerConfig) MaxLockoutDurationOrDefault() time.Duration {
+	if s.MaxLockoutDuration == 0 {
+		return DefaultMaxLockoutDuration
+	}
+	return time.Duration(s.MaxLockoutDuration) * time.Second
+}
+
+// AccessTokenTTLOrDefault returns the lifetime of the access tokens.
+func (s ServerConfig) AccessTokenTTLOrDefault() time.Duration {
+	if s.AccessTokenTTL == 0 {
+		return DefaultAccessTokenTTL
+	}
+	return time.Duration(s.AccessTokenTTL) * time.Second
+}
+
+// MaxEnvBatchSizeOrDefault returns the maximum number of operations of a bulk env request.
+func (s ServerConfig) MaxEnvBatchSizeOrDefault() int {
+	if s.MaxEnvBatchSize == 0 {
+		return DefaultEnvBatchSize
+	}
+	return s.MaxEnvBatchSize
+}
+
+// Read the config file, TOML, YAML or JSON detected by its extension, on top of the defaults.
+func ReadConfigFromFile(path string) (Config, error) {
+	return LoadConfig(ConfigSources{File: path})
 }
 
 // Read the config from a string.
@@ -60,13 +346,16 @@ func ReadConfigFromReader(r io.Reader) (Config, error) {
 	return config, nil
 }
 
+// validateConfig checks the config, it reports all the missing and invalid keys at once.
 func (c *Config) validateConfig() error {
+	var errs []error
 	requiredFields := []struct {
 		value     string
 		fieldName string
 	}{
 		{c.Server.Host, "server host"},
 		{c.Server.JWTSecretKey, "server jwt secret"},
+		{c.Server.EncryptionKey, "server encryption_key"},
 		{c.Database.Host, "database host"},
 		{c.Database.Name, "database name"},
 		{c.Database.User, "database user"},
@@ -75,17 +364,139 @@ func (c *Config) validateConfig() error {
 
 	for _, field := range requiredFields {
 		if strings.TrimSpace(field.value) == "" {
-			return missingKeyError(field.fieldName)
+			errs = append(errs, missingKeyError(field.fieldName))
 		}
 	}
 
 	if c.Server.Port == 0 {
-		return missingKeyError("server port")
+		errs = append(errs, missingKeyError("server port"))
+	}
+
+	if c.Server.GRPCPort < 0 || (c.Server.GRPCPort != 0 && c.Server.GRPCPort == c.Server.Port) {
+		errs = append(errs, invalidKeyError("server grpc_port", c.Server.GRPCPort))
 	}
 
 	if c.Database.Port == 0 {
-		return missingKeyError("database port")
+		errs = append(errs, missingKeyError("database port"))
+	}
+
+	durationFields := []struct {
+		value     int
+		fieldName string
+	}{
+		{c.Server.ShutdownTimeout, "server shutdown_timeout"},
+		{c.Server.ShutdownDelay, "server shutdown_delay"},
+		{c.Server.ReadHeaderTimeout, "server read_header_timeout"},
+		{c.Server.ReadTimeout, "server read_timeout"},
+		{c.Server.WriteTimeout, "server write_timeout"},
+		{c.Server.IdleTimeout, "server idle_timeout"},
+		{c.Server.MaxFailedSignins, "server max_failed_signins"},
+		{c.Server.MaxFailedSigninsPerIP, "server max_failed_signins_per_ip"},
+		{c.Server.LockoutDuration, "server lockout_duration"},
+		{c.Server.MaxLockoutDuration, "server max_lockout_duration"},
+		{c.Server.AccessTokenTTL, "server access_token_ttl"},
+		{c.Server.MaxEnvBatchSize, "server max_env_batch_size"},
+		{c.Webhooks.MaxAttempts, "webhooks max_attempts"},
+		{c.Webhooks.Timeout, "webhooks timeout"},
+		{c.Webhooks.PollInterval, "webhooks poll_interval"},
+		{c.RateLimit.Default.RequestsPerMinute, "rate_limit default requests_per_minute"},
+		{c.RateLimit.Default.Burst, "rate_limit default burst"},
+		{c.RateLimit.Auth.RequestsPerMinute, "rate_limit auth requests_per_minute"},
+		{c.RateLimit.Auth.Burst, "rate_limit auth burst"},
+		{c.RateLimit.Secrets.RequestsPerMinute, "rate_limit secrets requests_per_minute"},
+		{c.RateLimit.Secrets.Burst, "rate_limit secrets burst"},
+		{c.CORS.MaxAge, "cors max_age"},
+	}
+
+	for _, field := range durationFields {
+		if field.value < 0 {
+			errs = append(errs, invalidKeyError(field.fieldName, field.value))
+		}
+	}
+
+	if c.Server.MaxBodySize < 0 {
+		errs = append(errs, invalidKeyError("server max_body_size", c.Server.MaxBodySize))
+	}
+
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, invalidKeyError("log level", c.Log.Level))
+	}
+	if format := c.Log.FormatOrDefault(); format != LogFormatConsole && format != LogFormatJSON {
+		errs = append(errs, invalidKeyError("log format", c.Log.Format))
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, invalidKeyError("tracing sample_ratio", c.Tracing.SampleRatio))
+	}
+
+	errs = append(errs, c.Server.TLS.validate(), c.CORS.validate())
+
+	if _, err := ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		errs = append(errs, err)
+	}
+
+	kids := map[string]bool{}
+	activeKeys := 0
+	for _, key := range c.Server.JWTKeys {
+		if strings.TrimSpace(key.ID) == "" {
+			errs = append(errs, missingKeyError("server jwt_keys kid"))
+		} else if kids[key.ID] {
+			errs = append(errs, invalidKeyError("server jwt_keys kid", key.ID+" (duplicated)"))
+		}
+		if strings.TrimSpace(key.PrivateKeyFile) == "" {
+			errs = append(errs, missingKeyError(fmt.Sprintf("server jwt_keys %s private_key_file", key.ID)))
+		}
+		kids[key.ID] = true
+		if key.RetiredAt.IsZero() {
+			activeKeys++
+		}
+	}
+
+	if len(c.Server.JWTKeys) > 0 && activeKeys == 0 {
+		errs = append(errs, missingKeyError("server jwt_keys active key"))
+	}
+
+	if c.Audit.BufferSize < 0 {
+		errs = append(errs, invalidKeyError("audit buffer_size", c.Audit.BufferSize))
+	}
+
+	for _, sink := range c.Audit.Sinks {
+		switch sink.Type {
+		case SyslogAuditSink:
+			if sink.Network != "udp" && sink.Network != "tcp" {
+				errs = append(errs, invalidKeyError("audit sinks network", sink.Network))
+			}
+			if strings.TrimSpace(sink.Address) == "" {
+				errs = append(errs, missingKeyError("audit sinks address"))
+			}
+		case FileAuditSink:
+			if strings.TrimSpace(sink.Path) == "" {
+				errs = append(errs, missingKeyError("audit sinks path"))
+			}
+			if sink.MaxSize < 0 || sink.MaxBackups < 0 {
+				errs = append(errs, invalidKeyError("audit sinks rotation", fmt.Sprintf("max_size=%d max_backups=%d", sink.MaxSize, sink.MaxBackups)))
+			}
+		case StdoutAuditSink:
+		default:
+			errs = append(errs, invalidKeyError("audit sinks type", sink.Type))
+		}
+	}
+
+	switch c.Mail.Backend {
+	case SMTPMailBackend:
+		if strings.TrimSpace(c.Mail.Host) == "" {
+			errs = append(errs, missingKeyError("mail host"))
+		}
+		if c.Mail.Port == 0 {
+			errs = append(errs, missingKeyError("mail port"))
+		}
+		if strings.TrimSpace(c.Mail.From) == "" {
+			errs = append(errs, missingKeyError("mail from"))
+		}
+	case LogMailBackend, "":
+	default:
+		errs = append(errs, invalidKeyError("mail backend", c.Mail.Backend))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -3,6 +3,7 @@ package internal
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -19,6 +20,7 @@ name = "postgres"
 port = 8080
 host = "localhost"
 jwt_secret_key = "xyz"
+encryption_key = "abc"
 shutdown_timeout = 10
 `
 )
@@ -49,6 +51,7 @@ name = "postgres"
 port = 8080
 host = "localhost"
 jwt_secret_key = "xyz"
+encryption_key = "abc"
 shutdown_timeout = 10
 		`
 		_, err := ReadConfigFromString(fileContent)
@@ -67,6 +70,7 @@ name = "postgres"
 port = 8080
 host = "localhost"
 jwt_secret_key = "xyz"
+encryption_key = "abc"
 shutdown_timeout = 10
 		`
 		_, err := ReadConfigFromString(fileContent)
@@ -74,6 +78,96 @@ shutdown_timeout = 10
 	})
 }
 
+// Test the mail section validation.
+func TestMailConfigValidation(t *testing.T) {
+	t.Run("smtp backend without host", func(t *testing.T) {
+		_, err := ReadConfigFromString(fileContent + `
+[mail]
+backend = "smtp"
+port = 25
+from = "envserver@localhost"
+`)
+		assert.EqualError(t, err, missingKeyError("mail host").Error())
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		_, err := ReadConfigFromString(fileContent + `
+[mail]
+backend = "pigeon"
+`)
+		assert.EqualError(t, err, invalidKeyError("mail backend", "pigeon").Error())
+	})
+}
+
+// Test the server timeouts and body size.
+func TestServerTimeoutsConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		server := ServerConfig{}
+		assert.Equal(t, DefaultShutdownTimeout, server.ShutdownTimeoutOrDefault())
+		assert.Equal(t, DefaultShutdownDelay, server.ShutdownDelayOrDefault())
+		assert.Equal(t, DefaultReadHeaderTimeout, server.ReadHeaderTimeoutOrDefault())
+		assert.Equal(t, DefaultWriteTimeout, server.WriteTimeoutOrDefault())
+		assert.Equal(t, int64(DefaultMaxBodySize), server.MaxBodySizeOrDefault())
+	})
+
+	t.Run("configured values", func(t *testing.T) {
+		config, err := ReadConfigFromString(strings.Replace(fileContent, "shutdown_timeout = 10", "shutdown_timeout = 10\nshutdown_delay = 2\nidle_timeout = 5\nmax_body_size = 1024", 1))
+		assert.NoError(t, err)
+		assert.Equal(t, 10*time.Second, config.Server.ShutdownTimeoutOrDefault())
+		assert.Equal(t, 2*time.Second, config.Server.ShutdownDelayOrDefault())
+		assert.Equal(t, 5*time.Second, config.Server.IdleTimeoutOrDefault())
+		assert.Equal(t, int64(1024), config.Server.MaxBodySizeOrDefault())
+	})
+
+	t.Run("negative values", func(t *testing.T) {
+		_, err := ReadConfigFromString(strings.Replace(fileContent, "shutdown_timeout = 10", "shutdown_timeout = 10\nread_timeout = -1", 1))
+		assert.Error(t, err)
+
+		_, err = ReadConfigFromString(strings.Replace(fileContent, "shutdown_timeout = 10", "shutdown_timeout = 10\nmax_body_size = -1", 1))
+		assert.EqualError(t, err, invalidKeyError("server max_body_size", -1).Error())
+	})
+	t.Run("trusted proxies", func(t *testing.T) {
+		config, err := ReadConfigFromString(strings.Replace(fileContent, "shutdown_timeout = 10", "shutdown_timeout = 10\ntrusted_proxies = [\"10.0.0.0/8\", \"192.168.1.1\"]", 1))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, config.Server.TrustedProxies)
+
+		_, err = ReadConfigFromString(strings.Replace(fileContent, "shutdown_timeout = 10", "shutdown_timeout = 10\ntrusted_proxies = [\"proxy\"]", 1))
+		assert.EqualError(t, err, invalidKeyError("server trusted_proxies", "proxy").Error())
+	})
+}
+
+// Test the cors section validation.
+func TestCORSConfigValidation(t *testing.T) {
+	t.Run("allowed origins", func(t *testing.T) {
+		config, err := ReadConfigFromString(fileContent + `
+[cors]
+allowed_origins = ["https://dashboard.example.com", "http://localhost:3000"]
+allow_credentials = true
+`)
+		assert.NoError(t, err)
+		assert.True(t, config.CORS.AllowsOrigin("https://Dashboard.example.com"))
+		assert.False(t, config.CORS.AllowsOrigin("https://evil.example.com"))
+		assert.True(t, config.CORS.AllowsMethod("PATCH"))
+		assert.True(t, config.CORS.AllowsHeader("authorization"))
+	})
+
+	t.Run("invalid origins", func(t *testing.T) {
+		for _, origin := range []string{"dashboard.example.com", "https://dashboard.example.com/", "ftp://example.com"} {
+			_, err := ReadConfigFromString(fileContent + "[cors]\nallowed_origins = [\"" + origin + "\"]\n")
+			assert.EqualError(t, err, invalidKeyError("cors allowed_origins", origin).Error())
+		}
+	})
+
+	t.Run("any origin with credentials", func(t *testing.T) {
+		_, err := ReadConfigFromString(fileContent + `
+[cors]
+allowed_origins = ["*"]
+allow_credentials = true
+`)
+		assert.Error(t, err)
+	})
+}
+
 // Test read config from reader.
 func TestReadConfigFromReader(t *testing.T) {
 	t.Run("read config from reader", func(t *testing.T) {
@@ -107,6 +201,7 @@ func serverExpectation() ServerConfig {
 		Port:            8080,
 		Host:            "localhost",
 		JWTSecretKey:    "xyz",
+		EncryptionKey:   "abc",
 		ShutdownTimeout: 10,
 	}
 }
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// reloadableConfigKeys are the keys applied when the config is reloaded, the changes of the other keys need a restart.
+// The log format isn't reloadable since the loggers of the in-flight requests share the output.
+var reloadableConfigKeys = []string{"log.level", "rate_limit", "cors", "webhooks"}
+
+// secretConfigKeys are the keys masked in the effective config.
+var secretConfigKeys = map[string]bool{
+	"database.password":     true,
+	"server.jwt_secret_key": true,
+	"server.encryption_key": true,
+	"mail.password":         true,
+	"tracing.headers":       true,
+}
+
+// IsReloadableConfigKey returns true if a change of the dotted key is applied without a restart.
+func IsReloadableConfigKey(key string) bool {
+	for _, reloadable := range reloadableConfigKeys {
+		if key == reloadable || strings.HasPrefix(key, reloadable+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadConfig returns the config to apply when the config is reloaded: the current config with the reloadable keys of the loaded one.
+// It also returns the changed keys that are applied, and the ones that are ignored until a restart.
+func ReloadConfig(current, loaded Config) (effective Config, applied []string, ignored []string) {
+	for _, key := range ChangedConfigKeys(current, loaded) {
+		if IsReloadableConfigKey(key) {
+			applied = append(applied, key)
+		} else {
+			ignored = append(ignored, key)
+		}
+	}
+
+	effective = current
+	effective.Log.Level = loaded.Log.Level
+	effective.RateLimit = loaded.RateLimit
+	effective.CORS = loaded.CORS
+	effective.Webhooks = loaded.Webhooks
+	return effective, applied, ignored
+}
+
+// ChangedConfigKeys returns the dotted keys whose values differ between two configs, e.g. "database.host".
+// A list of tables is compared as a whole.
+func ChangedConfigKeys(old, new Config) []string {
+	var keys []string
+	changedConfigKeys(reflect.ValueOf(old), reflect.ValueOf(new), "", &keys)
+	return keys
+}
+
+func changedConfigKeys(old, new reflect.Value, prefix string, keys *[]string) {
+	for i := 0; i < old.NumField(); i++ {
+		key := old.Type().Field(i).Tag.Get("toml")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		oldField, newField := old.Field(i), new.Field(i)
+		if isConfigTable(oldField.Type()) {
+			changedConfigKeys(oldField, newField, key, keys)
+			continue
+		}
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			*keys = append(*keys, key)
+		}
+	}
+}
+
+// MaskedConfig returns the config as a tree of keys, with the values of the secrets replaced by RedactedValue.
+// Unset secrets are kept empty, so that the tree shows which ones are set.
+func MaskedConfig(config Config) map[string]interface{} {
+	return maskedConfigTree(reflect.ValueOf(config), "")
+}
+
+func maskedConfigTree(v reflect.Value, prefix string) map[string]interface{} {
+	tree := map[string]interface{}{}
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("toml")
+		if name == "" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		tree[name] = maskedConfigValue(v.Field(i), key)
+	}
+	return tree
+}
+
+func maskedConfigValue(field reflect.Value, key string) interface{} {
+	switch {
+	case secretConfigKeys[key] && field.Kind() == reflect.Map:
+		masked := map[string]string{}
+		for _, name := range field.MapKeys() {
+			masked[name.String()] = RedactedValue
+		}
+		return masked
+	case secretConfigKeys[key]:
+		if field.IsZero() {
+			return ""
+		}
+		return RedactedValue
+	case isConfigTable(field.Type()):
+		return maskedConfigTree(field, key)
+	case field.Kind() == reflect.Slice && isConfigTable(field.Type().Elem()):
+		tables := make([]interface{}, field.Len())
+		for i := range tables {
+			tables[i] = maskedConfigTree(field.Index(i), key)
+		}
+		return tables
+	}
+	return field.Interface()
+}
+
+// isConfigTable returns true if the type is a section of the config.
+func isConfigTable(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
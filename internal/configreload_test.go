@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadConfig(t *testing.T) {
+	current, err := ReadConfigFromString(fileContent)
+	assert.NoError(t, err)
+
+	t.Run("changed keys", func(t *testing.T) {
+		loaded := current
+		loaded.Database.Host = "db.internal"
+		loaded.Server.Admins = []string{"admin@example.com"}
+		loaded.CORS.AllowedOrigins = []string{"https://dashboard.example.com"}
+		assert.Equal(t, []string{"database.host", "server.admins", "cors.allowed_origins"}, ChangedConfigKeys(current, loaded))
+		assert.Empty(t, ChangedConfigKeys(current, current))
+	})
+
+	t.Run("apply the reloadable keys only", func(t *testing.T) {
+		loaded := current
+		loaded.Server.Port = 9000
+		loaded.Database.Password = "changed"
+		loaded.Log = LogConfig{Level: "debug", Format: LogFormatJSON}
+		loaded.RateLimit.Auth.Burst = 3
+		loaded.CORS.AllowedOrigins = []string{"https://dashboard.example.com"}
+		loaded.Webhooks.MaxAttempts = 3
+
+		effective, applied, ignored := ReloadConfig(current, loaded)
+		assert.Equal(t, []string{"webhooks.max_attempts", "log.level", "rate_limit.auth.burst", "cors.allowed_origins"}, applied)
+		assert.Equal(t, []string{"database.password", "server.port", "log.format"}, ignored)
+
+		assert.Equal(t, 8080, effective.Server.Port)
+		assert.Equal(t, "postgres", effective.Database.Password)
+		assert.Equal(t, "", effective.Log.Format)
+		assert.Equal(t, "debug", effective.Log.Level)
+		assert.Equal(t, 3, effective.RateLimit.Auth.Burst)
+		assert.Equal(t, loaded.CORS, effective.CORS)
+		assert.Equal(t, 3, effective.Webhooks.MaxAttempts)
+	})
+
+	t.Run("masked secrets", func(t *testing.T) {
+		config := current
+		config.Tracing.Headers = map[string]string{"x-api-key": "secret"}
+		config.Server.JWTKeys = []JWTKeyConfig{{ID: "2024", PrivateKeyFile: "/keys/2024.pem"}}
+
+		masked := MaskedConfig(config)
+		database := masked["database"].(map[string]interface{})
+		assert.Equal(t, RedactedValue, database["password"])
+		assert.Equal(t, "localhost", database["host"])
+
+		server := masked["server"].(map[string]interface{})
+		assert.Equal(t, RedactedValue, server["jwt_secret_key"])
+		assert.Equal(t, "2024", server["jwt_keys"].([]interface{})[0].(map[string]interface{})["kid"])
+
+		assert.Equal(t, "", masked["mail"].(map[string]interface{})["password"])
+		assert.Equal(t, map[string]string{"x-api-key": RedactedValue}, masked["tracing"].(map[string]interface{})["headers"])
+	})
+}
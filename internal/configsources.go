@@ -0,0 +1,367 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigEnvPrefix prefixes the environment variables overriding the config keys, e.g. ENVSERVER_DATABASE_PASSWORD.
+const ConfigEnvPrefix = "ENVSERVER_"
+
+// secretFileSuffix suffixes the keys reading the value of a string key from a file, e.g. password_file for a mounted secret.
+const secretFileSuffix = "_file"
+
+// ConfigSources are the layers of the config, every layer overrides the keys set by the previous ones:
+// the defaults, the file, the environment variables and the flags.
+type ConfigSources struct {
+	// Path of a TOML, YAML or JSON file, detected by its extension; other extensions are read as TOML.
+	File string
+	// Environment in the "KEY=value" form of os.Environ, only the ENVSERVER_ variables are read.
+	Environ []string
+	// Values by dotted key, e.g. "server.port" or "database.password_file".
+	Flags map[string]string
+}
+
+// ConfigFlags collects the repeated "key=value" flags overriding the config keys, it implements flag.Value.
+type ConfigFlags map[string]string
+
+func (f ConfigFlags) String() string {
+	pairs := make([]string, 0, len(f))
+	for key, value := range f {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set adds a "key=value" flag.
+func (f ConfigFlags) Set(pair string) error {
+	key, value, ok := strings.Cut(pair, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("%q isn't a key=value pair", pair)
+	}
+	f[strings.TrimSpace(key)] = value
+	return nil
+}
+
+// DefaultConfig returns the first layer of the config, the other unset keys fall back on the defaults of the XOrDefault methods.
+func DefaultConfig() Config {
+	return Config{
+		Server:   ServerConfig{Host: "localhost", Port: 8080},
+		Database: DatabaseConfig{Port: 5432},
+	}
+}
+
+// LoadConfig loads the config from its sources and validates it.
+// It reports all the invalid keys of every source and all the validation errors at once.
+func LoadConfig(sources ConfigSources) (Config, error) {
+	config := DefaultConfig()
+	root := reflect.ValueOf(&config).Elem()
+
+	var errs []error
+	if sources.File != "" {
+		tree, err := readConfigFile(sources.File)
+		if err != nil {
+			return Config{}, err
+		}
+		errs = append(errs, decodeConfigTree(root, tree, "")...)
+	}
+	errs = append(errs, decodeConfigTree(root, environConfigTree(sources.Environ), "")...)
+
+	flags := map[string]interface{}{}
+	for key, value := range sources.Flags {
+		setConfigTreeValue(flags, strings.Split(key, "."), value)
+	}
+	errs = append(errs, decodeConfigTree(root, flags, "")...)
+
+	errs = append(errs, config.validateConfig())
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// readConfigFile decodes a config file into a tree of keys.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", cantLoadConfigFileError, err)
+	}
+
+	tree := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &tree)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		err = decoder.Decode(&tree)
+	default:
+		_, err = toml.Decode(string(content), &tree)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %s", cantDecodeConfigError, path, err)
+	}
+	return tree, nil
+}
+
+// environConfigTree returns the tree of the keys set by the ENVSERVER_ variables.
+// The variables are matched against the keys of the config, so that the unknown ones (e.g. ENVSERVER_URL of the Go client) are ignored.
+func environConfigTree(environ []string) map[string]interface{} {
+	paths := map[string][]string{}
+	configKeyPaths(reflect.TypeOf(Config{}), nil, paths)
+
+	tree := map[string]interface{}{}
+	for _, variable := range environ {
+		name, value, ok := strings.Cut(variable, "=")
+		if !ok || !strings.HasPrefix(name, ConfigEnvPrefix) {
+			continue
+		}
+		if path, ok := paths[strings.TrimPrefix(name, ConfigEnvPrefix)]; ok {
+			setConfigTreeValue(tree, path, value)
+		}
+	}
+	return tree
+}
+
+// configKeyPaths maps the environment variable names of the scalar and list keys to their path, e.g. DATABASE_PASSWORD to database.password.
+// The lists of tables and the maps can only be set in a file.
+func configKeyPaths(t reflect.Type, prefix []string, paths map[string][]string) {
+	var secrets [][]string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("toml")
+		if key == "" {
+			continue
+		}
+		path := append(append([]string{}, prefix...), key)
+		switch {
+		case isConfigTable(field.Type):
+			configKeyPaths(field.Type, path, paths)
+			continue
+		case field.Type.Kind() == reflect.Map, field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			continue
+		case field.Type.Kind() == reflect.String:
+			secrets = append(secrets, append(append([]string{}, prefix...), key+secretFileSuffix))
+		}
+		paths[configEnvName(path)] = path
+	}
+
+	// A key ending with _file (e.g. cert_file) takes precedence over the secret file of another key.
+	for _, path := range secrets {
+		if _, exists := paths[configEnvName(path)]; !exists {
+			paths[configEnvName(path)] = path
+		}
+	}
+}
+
+// configEnvName returns the name of the environment variable of a key without the prefix, e.g. DATABASE_PASSWORD.
+func configEnvName(path []string) string {
+	return strings.ToUpper(strings.Join(path, "_"))
+}
+
+// setConfigTreeValue sets the value at the path of the tree, creating the missing tables.
+func setConfigTreeValue(tree map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		table, ok := tree[key].(map[string]interface{})
+		if !ok {
+			table = map[string]interface{}{}
+			tree[key] = table
+		}
+		tree = table
+	}
+	tree[path[len(path)-1]] = value
+}
+
+// decodeConfigTree sets the fields of the struct from a tree of keys, matched by their toml tags.
+// A string key can also be read from the file named by its _file key. Unknown keys are reported as errors.
+func decodeConfigTree(v reflect.Value, tree map[string]interface{}, name string) []error {
+	fields := map[string]reflect.Value{}
+	for i := 0; i < v.NumField(); i++ {
+		if key := v.Type().Field(i).Tag.Get("toml"); key != "" {
+			fields[key] = v.Field(i)
+		}
+	}
+
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		value := tree[key]
+		keyName := strings.TrimSpace(name + " " + key)
+		if field, ok := fields[key]; ok {
+			errs = append(errs, decodeConfigValue(field, value, keyName)...)
+			continue
+		}
+
+		base := strings.TrimSuffix(key, secretFileSuffix)
+		field, ok := fields[base]
+		if base == key || !ok || field.Kind() != reflect.String {
+			errs = append(errs, unknownKeyError(keyName))
+			continue
+		}
+		if _, conflict := tree[base]; conflict {
+			errs = append(errs, invalidKeyError(keyName, fmt.Sprintf("%v (%s is also set)", value, base)))
+			continue
+		}
+		path, ok := value.(string)
+		if !ok {
+			errs = append(errs, invalidKeyError(keyName, value))
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read the %s key: %w", keyName, err))
+			continue
+		}
+		field.SetString(strings.TrimRight(string(content), "\r\n"))
+	}
+	return errs
+}
+
+// decodeConfigValue sets a field from a value decoded from a file, or from the string of an environment variable or a flag.
+func decodeConfigValue(field reflect.Value, value interface{}, name string) []error {
+	invalid := []error{invalidKeyError(name, value)}
+
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		switch value := value.(type) {
+		case time.Time:
+			field.Set(reflect.ValueOf(value))
+		case string:
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return invalid
+			}
+			field.Set(reflect.ValueOf(parsed))
+		default:
+			return invalid
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Struct:
+		table, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid
+		}
+		return decodeConfigTree(field, table, name)
+
+	case reflect.String:
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, []map[string]interface{}:
+			return invalid
+		}
+		field.SetString(fmt.Sprint(value))
+
+	case reflect.Bool:
+		switch value := value.(type) {
+		case bool:
+			field.SetBool(value)
+		case string:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return invalid
+			}
+			field.SetBool(parsed)
+		default:
+			return invalid
+		}
+
+	case reflect.Int, reflect.Int64:
+		number, ok := configNumber(value)
+		if !ok || number != math.Trunc(number) || field.OverflowInt(int64(number)) {
+			return invalid
+		}
+		field.SetInt(int64(number))
+
+	case reflect.Float64:
+		number, ok := configNumber(value)
+		if !ok {
+			return invalid
+		}
+		field.SetFloat(number)
+
+	case reflect.Slice:
+		var items []interface{}
+		switch value := value.(type) {
+		case []interface{}:
+			items = value
+		case []map[string]interface{}:
+			for _, item := range value {
+				items = append(items, item)
+			}
+		case string:
+			// Lists are comma separated in the environment variables and the flags.
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		default:
+			return invalid
+		}
+
+		// A layer replaces the whole list of the previous ones.
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		var errs []error
+		for i, item := range items {
+			errs = append(errs, decodeConfigValue(slice.Index(i), item, fmt.Sprintf("%s[%d]", name, i))...)
+		}
+		field.Set(slice)
+		return errs
+
+	case reflect.Map:
+		table, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid
+		}
+		entries := reflect.MakeMapWithSize(field.Type(), len(table))
+		for key, item := range table {
+			entries.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(fmt.Sprint(item)))
+		}
+		field.Set(entries)
+
+	default:
+		return invalid
+	}
+	return nil
+}
+
+// configNumber converts the numbers decoded from TOML, YAML and JSON, and the numeric strings.
+func configNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case float64:
+		return value, true
+	case json.Number:
+		number, err := value.Float64()
+		return number, err == nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	yamlFile := write("config.yaml", `
+database:
+  host: db.internal
+  user: envserver
+  name: envserver
+  password: from-file
+server:
+  jwt_secret_key: xyz
+  encryption_key: abc
+  admins: [admin@example.com]
+  jwt_keys:
+    - kid: "2023"
+      private_key_file: /keys/2023.pem
+      retired_at: 2023-06-01T00:00:00Z
+    - kid: "2024"
+      private_key_file: /keys/2024.pem
+tracing:
+  sample_ratio: 0.5
+  headers:
+    x-api-key: secret
+`)
+
+	t.Run("defaults", func(t *testing.T) {
+		config, err := LoadConfig(ConfigSources{Flags: map[string]string{
+			"database.host":         "localhost",
+			"database.user":         "postgres",
+			"database.name":         "postgres",
+			"database.password":     "postgres",
+			"server.jwt_secret_key": "xyz",
+			"server.encryption_key": "abc",
+		}})
+		assert.NoError(t, err)
+		assert.Equal(t, "localhost", config.Server.Host)
+		assert.Equal(t, 8080, config.Server.Port)
+		assert.Equal(t, int64(5432), config.Database.Port)
+	})
+
+	t.Run("yaml file", func(t *testing.T) {
+		config, err := LoadConfig(ConfigSources{File: yamlFile})
+		assert.NoError(t, err)
+		assert.Equal(t, "db.internal", config.Database.Host)
+		assert.Equal(t, []string{"admin@example.com"}, config.Server.Admins)
+		assert.Len(t, config.Server.JWTKeys, 2)
+		assert.Equal(t, time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), config.Server.JWTKeys[0].RetiredAt.UTC())
+		assert.Equal(t, 0.5, config.Tracing.SampleRatio)
+		assert.Equal(t, map[string]string{"x-api-key": "secret"}, config.Tracing.Headers)
+	})
+
+	t.Run("json file", func(t *testing.T) {
+		config, err := LoadConfig(ConfigSources{File: write("config.json", `{
+			"database": {"host": "localhost", "port": 5433, "user": "postgres", "name": "postgres", "password": "postgres"},
+			"server": {"jwt_secret_key": "xyz", "encryption_key": "abc", "tls": {"min_version": "1.3"}}
+		}`)})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5433), config.Database.Port)
+		assert.Equal(t, "1.3", config.Server.TLS.MinVersion)
+	})
+
+	t.Run("toml file", func(t *testing.T) {
+		config, err := LoadConfig(ConfigSources{File: write("config.toml", fileContent)})
+		assert.NoError(t, err)
+		assert.Equal(t, serverExpectation(), config.Server)
+	})
+
+	t.Run("environment variables and flags override the file", func(t *testing.T) {
+		config, err := LoadConfig(ConfigSources{
+			File: yamlFile,
+			Environ: []string{
+				"ENVSERVER_DATABASE_PASSWORD=from-env",
+				"ENVSERVER_SERVER_PORT=9000",
+				"ENVSERVER_SERVER_TLS_MIN_VERSION=1.3",
+				"ENVSERVER_CORS_ALLOWED_ORIGINS=https://a.example.com, https://b.example.com",
+				"ENVSERVER_URL=https://envserver.example.com",
+				"PATH=/usr/bin",
+			},
+			Flags: map[string]string{"server.port": "9001"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "from-env", config.Database.Password)
+		assert.Equal(t, 9001, config.Server.Port)
+		assert.Equal(t, "1.3", config.Server.TLS.MinVersion)
+		assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, config.CORS.AllowedOrigins)
+	})
+
+	t.Run("secret files", func(t *testing.T) {
+		secret := write("db-password", "from-secret\n")
+		config, err := LoadConfig(ConfigSources{
+			File:    yamlFile,
+			Environ: []string{"ENVSERVER_DATABASE_PASSWORD_FILE=" + secret},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "from-secret", config.Database.Password)
+
+		// The keys ending with _file aren't secret files.
+		config, err = LoadConfig(ConfigSources{File: yamlFile, Environ: []string{
+			"ENVSERVER_SERVER_TLS_CERT_FILE=/certs/server.crt",
+			"ENVSERVER_SERVER_TLS_KEY_FILE=/certs/server.key",
+		}})
+		assert.NoError(t, err)
+		assert.Equal(t, "/certs/server.crt", config.Server.TLS.CertFile)
+
+		_, err = LoadConfig(ConfigSources{File: yamlFile, Flags: map[string]string{"database.password_file": filepath.Join(dir, "missing")}})
+		assert.ErrorContains(t, err, "failed to read the database password_file key")
+	})
+
+	t.Run("all errors at once", func(t *testing.T) {
+		_, err := LoadConfig(ConfigSources{
+			File: write("invalid.yaml", `
+database:
+  host: localhost
+  port: many
+server:
+  jwt_secret_key: xyz
+  encryption_key: abc
+  unknown: true
+log:
+  level: loud
+`),
+			Flags: map[string]string{"server.max_body_size": "-1"},
+		})
+		assert.ErrorContains(t, err, invalidKeyError("database port", "many").Error())
+		assert.ErrorContains(t, err, unknownKeyError("server unknown").Error())
+		assert.ErrorContains(t, err, missingKeyError("database user").Error())
+		assert.ErrorContains(t, err, missingKeyError("database password").Error())
+		assert.ErrorContains(t, err, invalidKeyError("log level", "loud").Error())
+		assert.ErrorContains(t, err, invalidKeyError("server max_body_size", -1).Error())
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadConfig(ConfigSources{File: filepath.Join(dir, "missing.toml")})
+		assert.ErrorIs(t, err, cantLoadConfigFileError)
+	})
+}
+
+func TestConfigFlags(t *testing.T) {
+	flags := ConfigFlags{}
+	assert.NoError(t, flags.Set("server.port=9000"))
+	assert.NoError(t, flags.Set("cors.allowed_origins=https://a.example.com,https://b.example.com"))
+	assert.Error(t, flags.Set("server.port"))
+	assert.Equal(t, "cors.allowed_origins=https://a.example.com,https://b.example.com,server.port=9000", flags.String())
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AnyOrigin allows the cross-origin requests of every origin.
+const AnyOrigin = "*"
+
+// Default methods and request headers allowed in the preflight requests.
+var (
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	DefaultCORSHeaders = []string{"Authorization", "Content-Type", "Last-Event-ID", "X-Request-ID"}
+)
+
+// CORSExposedHeaders are the response headers readable by the browser dashboard.
+var CORSExposedHeaders = []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}
+
+// AllowsOrigin returns true if the cross-origin requests of the origin are allowed, origins are compared case-insensitively.
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == AnyOrigin || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAnyOrigin returns true if every origin is allowed.
+func (c CORSConfig) AllowsAnyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == AnyOrigin {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedMethodsOrDefault returns the methods allowed in the preflight requests.
+func (c CORSConfig) AllowedMethodsOrDefault() []string {
+	if len(c.AllowedMethods) == 0 {
+		return DefaultCORSMethods
+	}
+	return c.AllowedMethods
+}
+
+// AllowedHeadersOrDefault returns the request headers allowed in the preflight requests.
+func (c CORSConfig) AllowedHeadersOrDefault() []string {
+	if len(c.AllowedHeaders) == 0 {
+		return DefaultCORSHeaders
+	}
+	return c.AllowedHeaders
+}
+
+// AllowsMethod returns true if the method is allowed, methods are case-sensitive.
+func (c CORSConfig) AllowsMethod(method string) bool {
+	for _, allowed := range c.AllowedMethodsOrDefault() {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsHeader returns true if the request header is allowed, header names are case-insensitive.
+func (c CORSConfig) AllowsHeader(header string) bool {
+	for _, allowed := range c.AllowedHeadersOrDefault() {
+		if strings.EqualFold(allowed, header) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == AnyOrigin {
+			if c.AllowCredentials {
+				return invalidKeyError("cors allowed_origins", origin+" (not allowed with allow_credentials)")
+			}
+			continue
+		}
+		// An origin is a scheme and a host, with an optional port.
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+			parsed.Path != "" || parsed.RawQuery != "" || parsed.User != nil {
+			return invalidKeyError("cors allowed_origins", origin)
+		}
+	}
+	for _, method := range c.AllowedMethods {
+		if strings.TrimSpace(method) == "" || strings.ToUpper(method) != method {
+			return invalidKeyError("cors allowed_methods", method)
+		}
+	}
+	for _, header := range c.AllowedHeaders {
+		if strings.TrimSpace(header) == "" {
+			return invalidKeyError("cors allowed_headers", header)
+		}
+	}
+	return nil
+}
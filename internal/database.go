@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	models "github.com/Mahmoud-Emad/envserver/models"
 	"github.com/rs/zerolog/log"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // DB struct hold db instance
@@ -27,7 +29,7 @@ func (d *Database) Connect(dbConfig DatabaseConfig) error {
 	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		dbConfig.Host, dbConfig.Port, dbConfig.User, dbConfig.Password, dbConfig.Name)
 
-	gormDB, err := gorm.Open(postgres.Open(connStr), &gorm.Config{})
+	gormDB, err := gorm.Open(postgres.Open(connStr), &gorm.Config{Logger: newGormLogger()})
 
 	if err != nil {
 		return err
@@ -38,12 +40,25 @@ func (d *Database) Connect(dbConfig DatabaseConfig) error {
 	return nil
 }
 
+// Close closes the connections of the pool.
+func (d *Database) Close() error {
+	if d.db == nil {
+		return nil
+	}
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// migratedTables are the models whose tables are migrated by Migrate.
+var migratedTables = []interface{}{&models.User{}, &models.Project{}, &models.EnvironmentKey{}, &models.RecoveryCode{}, &models.ActionToken{}, &models.AuditEvent{}, &models.AuditHead{}, &models.Webhook{}, &models.WebhookDelivery{}}
+
 // Migrate migrates the database schema.
 func (d *Database) Migrate() error {
-	tables := []interface{}{&models.User{}, &models.Project{}, &models.EnvironmentKey{}}
-
 	log.Info().Msg("Database migration started")
-	for _, table := range tables {
+	for _, table := range migratedTables {
 		tableName := reflect.TypeOf(table).Elem().Name()
 		log.Info().Msgf("Migrating table: %s", tableName)
 		if err := d.db.AutoMigrate(table); err != nil {
@@ -106,6 +121,7 @@ func (d *Database) UpdateProject(project *models.Project) error {
 	existingProject.EnvironmentName = project.EnvironmentName
 	existingProject.Team = project.Team
 	existingProject.Owner = project.Owner
+	existingProject.RequireMFA = project.RequireMFA
 
 	// Clear the existing keys association to avoid any conflicts.
 	if err := d.db.Model(existingProject).Association("Keys").Clear(); err != nil {
@@ -127,32 +143,50 @@ func (d *Database) UpdateProject(project *models.Project) error {
 	return nil
 }
 
-// UpdateProjectEnvironment updates project environment by its iD.
-func (d *Database) UpdateProjectEnvironment(env models.EnvironmentKey) error {
-	err := d.db.Model(&models.Project{}).Where("id = ?", env.ID).Updates(
-		models.EnvironmentKey{
-			Key:       env.Key,
-			ID:        env.ID,
-			ProjectID: env.ProjectID,
-			Value:     env.Value,
+// UpdateProjectEnvironment updates project environment by its iD and increments its version.
+func (d *Database) UpdateProjectEnvironment(env *models.EnvironmentKey) error {
+	return updateEnvKey(d.db, env)
+}
+
+func updateEnvKey(tx *gorm.DB, env *models.EnvironmentKey) error {
+	return tx.Model(env).Clauses(clause.Returning{Columns: []clause.Column{{Name: "version"}}}).Updates(
+		map[string]interface{}{
+			"key":     env.Key,
+			"value":   env.Value,
+			"version": gorm.Expr("version + 1"),
 		}).Error
-	return err
 }
 
-// GetUsers returns a list of all user records
-func (d *Database) GetUsers() ([]models.User, error) {
-	// Retrieve all users
-	var users []models.User
-	result := d.db.Find(&users)
-	return users, result.Error
+// GetUsers returns a page of the users matching the filter, and the cursor of the next page.
+// The name prefix matches the first name, last name or email of the users.
+func (d *Database) GetUsers(filter ListFilter) ([]models.User, string, error) {
+	query := d.db.Model(&models.User{})
+	if filter.NamePrefix != "" {
+		prefix := escapeLike(filter.NamePrefix) + "%"
+		query = query.Where("first_name LIKE ? OR last_name LIKE ? OR email LIKE ?", prefix, prefix, prefix)
+	}
+	if !filter.UpdatedSince.IsZero() {
+		query = query.Where("updated_at >= ?", filter.UpdatedSince)
+	}
+	return findPage(query, filter, userSortFields, func(u models.User) int { return u.ID })
 }
 
-// GetProjects returns a list of all project records
-func (d *Database) GetProjects() ([]models.Project, error) {
-	// Retrieve all projects
-	var projects []models.Project
-	result := d.db.Find(&projects)
-	return projects, result.Error
+// GetProjects returns a page of the projects matching the filter, and the cursor of the next page.
+func (d *Database) GetProjects(filter ListFilter) ([]models.Project, string, error) {
+	query := d.db.Model(&models.Project{})
+	if filter.NamePrefix != "" {
+		query = query.Where("name LIKE ?", escapeLike(filter.NamePrefix)+"%")
+	}
+	if filter.Owner != 0 {
+		query = query.Where("owner = ?", filter.Owner)
+	}
+	if filter.EnvironmentName != "" {
+		query = query.Where("environment_name = ?", filter.EnvironmentName)
+	}
+	if !filter.UpdatedSince.IsZero() {
+		query = query.Where("updated_at >= ?", filter.UpdatedSince)
+	}
+	return findPage(query, filter, projectSortFields, func(p models.Project) int { return p.ID })
 }
 
 // DeleteUserByEmail deletes a user by their email
@@ -228,10 +262,169 @@ func (d *Database) GetEnvKeyByKeyName(keyName string) (models.EnvironmentKey, er
 	return env, query.Error
 }
 
-// GetEnvKeyByKeyName returns user by its key name
-func (d *Database) GetEnvKeysAndValuesById(id int) ([]models.EnvironmentKey, error) {
-	// Retrieve all project env keys/values
-	var env []models.EnvironmentKey
-	result := d.db.Find(&env).Where("project_id = ?", id)
-	return env, result.Error
+// GetEnvKeysAndValuesById returns a page of the env keys of the project matching the filter, and the cursor of the next page.
+// The name prefix matches the key names.
+func (d *Database) GetEnvKeysAndValuesById(id int, filter ListFilter) ([]models.EnvironmentKey, string, error) {
+	query := d.db.Model(&models.EnvironmentKey{}).Where("project_id = ?", id)
+	if filter.NamePrefix != "" {
+		query = query.Where("key LIKE ?", escapeLike(filter.NamePrefix)+"%")
+	}
+	if !filter.UpdatedSince.IsZero() {
+		query = query.Where("updated_at >= ?", filter.UpdatedSince)
+	}
+	return findPage(query, filter, envSortFields, func(e models.EnvironmentKey) int { return e.ID })
+}
+
+// UpdateUserMFA updates the MFA state and encrypted secret of a user.
+func (d *Database) UpdateUserMFA(userID int, enabled bool, secret []byte) error {
+	result := d.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"mfa_enabled": enabled,
+		"mfa_secret":  secret,
+	})
+	return result.Error
+}
+
+// ReplaceRecoveryCodes deletes the existing recovery codes of a user and stores the new hashed ones.
+func (d *Database) ReplaceRecoveryCodes(userID int, hashedCodes [][]byte) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		for _, hashed := range hashedCodes {
+			code := models.RecoveryCode{UserID: userID, HashedCode: hashed}
+			if err := tx.Create(&code).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetUnusedRecoveryCodes returns the recovery codes of a user that were not used yet.
+func (d *Database) GetUnusedRecoveryCodes(userID int) ([]models.RecoveryCode, error) {
+	var codes []models.RecoveryCode
+	result := d.db.Where("user_id = ? AND used = ?", userID, false).Find(&codes)
+	return codes, result.Error
+}
+
+// UseTOTPStep stores the time step of an accepted TOTP code, it fails if a code of this step or a later one was already used,
+// e.g. by a concurrent request replaying the same code.
+func (d *Database) UseTOTPStep(userID int, step int64) error {
+	result := d.db.Model(&models.User{}).Where("id = ? AND last_totp_step < ?", userID, step).Update("last_totp_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return InvalidMFACodeError
+	}
+	return nil
+}
+
+// MarkRecoveryCodeUsed marks a recovery code as used so it can't be used again.
+func (d *Database) MarkRecoveryCodeUsed(id int) error {
+	result := d.db.Model(&models.RecoveryCode{}).Where("id = ? AND used = ?", id, false).Update("used", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateActionToken stores a new action token record.
+func (d *Database) CreateActionToken(token *models.ActionToken) error {
+	result := d.db.Create(token)
+	return result.Error
+}
+
+// UseActionToken marks the token with the given jti and purpose as used, it fails if the token is unknown, expired or already used.
+func (d *Database) UseActionToken(jti, purpose string) (models.ActionToken, error) {
+	var token models.ActionToken
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&token, "jti = ? AND purpose = ?", jti, purpose).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.ActionToken{}).
+			Where("id = ? AND used = ? AND expires_at > ?", token.ID, false, time.Now()).
+			Update("used", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	return token, err
+}
+
+// UpdateUserPassword updates the hashed password of a user.
+func (d *Database) UpdateUserPassword(userID int, hashedPassword []byte) error {
+	result := d.db.Model(&models.User{}).Where("id = ?", userID).Update("hashed_password", hashedPassword)
+	return result.Error
+}
+
+// SetUserEmailVerified marks the email address of a user as verified.
+func (d *Database) SetUserEmailVerified(userID int) error {
+	result := d.db.Model(&models.User{}).Where("id = ?", userID).Update("email_verified", true)
+	return result.Error
+}
+
+// IncrementFailedSignins increments the consecutive failed signins counter of a user and returns its new value.
+func (d *Database) IncrementFailedSignins(userID int) (int, error) {
+	result := d.db.Model(&models.User{}).Where("id = ?", userID).
+		Update("failed_signins", gorm.Expr("failed_signins + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	var u models.User
+	if err := d.db.Select("failed_signins").First(&u, "id = ?", userID).Error; err != nil {
+		return 0, err
+	}
+	return u.FailedSignins, nil
+}
+
+// LockUser locks the signin of a user until the given time.
+func (d *Database) LockUser(userID int, until time.Time) error {
+	result := d.db.Model(&models.User{}).Where("id = ?", userID).Update("locked_until", until)
+	return result.Error
+}
+
+// ResetFailedSignins resets the failed signins counter of a user and unlocks it.
+func (d *Database) ResetFailedSignins(userID int) error {
+	result := d.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_signins": 0,
+		"locked_until":   nil,
+	})
+	return result.Error
+}
+
+// PromoteAdmin grants the site administrator role to the user with the given email.
+// With verifiedOnly, the user must have verified the email, so that whoever signs up first with an admin email isn't promoted.
+func (d *Database) PromoteAdmin(email string, verifiedOnly bool) error {
+	query := d.db.Model(&models.User{}).Where("email = ?", email)
+	if verifiedOnly {
+		query = query.Where("email_verified = ?", true)
+	}
+	result := query.Update("is_admin", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if _, err := d.GetUserByEmail(email); err != nil {
+		return err
+	}
+	return EmailNotVerifiedError
+}
+
+// SetUserSuspended suspends or reinstates a user.
+func (d *Database) SetUserSuspended(userID int, suspended bool) error {
+	result := d.db.Model(&models.User{}).Where("id = ?", userID).Update("suspended", suspended)
+	return result.Error
 }
@@ -12,6 +12,7 @@ var configContent = `
 host = "localhost"
 port = 8080
 jwt_secret_key = "xyz"
+encryption_key = "abc"
 shutdown_timeout = 10
 
 [database]
@@ -97,6 +98,21 @@ func TestUser(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("promote admin", func(t *testing.T) {
+		// Test only a verified email is promoted from the config, the operator can promote any user.
+		db, _ := setupDB(t)
+		assert.ErrorIs(t, db.PromoteAdmin(email, true), EmailNotVerifiedError)
+		assert.Error(t, db.PromoteAdmin("unknown@gmail.com", false))
+
+		assert.NoError(t, db.PromoteAdmin(email, false))
+		user, err := db.GetUserByEmail(email)
+		assert.NoError(t, err)
+		assert.True(t, user.IsAdmin)
+
+		assert.NoError(t, db.SetUserEmailVerified(user.ID))
+		assert.NoError(t, db.PromoteAdmin(email, true))
+	})
+
 	t.Run("delete created user", func(t *testing.T) {
 		// Test delete user record from the database by it's email.
 		db, _ := setupDB(t)
@@ -46,6 +46,9 @@ func DecryptAES(ciphered []byte, keyPhrase string) ([]byte, error) {
 	}
 
 	nonceSize := gcmInstance.NonceSize()
+	if len(ciphered) < nonceSize {
+		return []byte{}, InvalidCipherTextError
+	}
 	nonce, cipheredText := ciphered[:nonceSize], ciphered[nonceSize:]
 
 	originalText, err := gcmInstance.Open(nil, nonce, cipheredText, nil)
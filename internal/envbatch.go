@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"fmt"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// EnvSetOperation creates an env key or updates its value.
+	EnvSetOperation = "set"
+	// EnvDeleteOperation deletes an env key.
+	EnvDeleteOperation = "delete"
+	// DefaultEnvBatchSize is the maximum number of operations of a bulk env request if it's not configured.
+	DefaultEnvBatchSize = 100
+)
+
+// EnvOperation is a validated operation of a bulk env request, the value is encrypted.
+type EnvOperation struct {
+	Op    string
+	Key   string
+	Value []byte
+}
+
+// EnvOperationResult is the outcome of an operation of a bulk env request.
+type EnvOperationResult struct {
+	Op  string `json:"op"`
+	Key string `json:"key"`
+	// Event is the change made by the operation: env.created, env.updated or env.deleted.
+	Event   string `json:"event,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// EnvOperationError is returned when an operation of a batch fails, none of the operations are applied.
+type EnvOperationError struct {
+	Index int
+	Err   error
+}
+
+func (e *EnvOperationError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *EnvOperationError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyEnvOperations applies the operations to the env keys of the project in a single transaction,
+// either all of them are applied or none. The changed keys are locked until the transaction ends.
+func (d *Database) ApplyEnvOperations(projectID int, operations []EnvOperation) ([]EnvOperationResult, error) {
+	results := make([]EnvOperationResult, len(operations))
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		keys := make([]string, len(operations))
+		for i, operation := range operations {
+			keys[i] = operation.Key
+		}
+
+		var existing []models.EnvironmentKey
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("project_id = ? AND key IN ?", projectID, keys).
+			Order("id").
+			Find(&existing)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		byKey := map[string]models.EnvironmentKey{}
+		for _, env := range existing {
+			if _, found := byKey[env.Key]; !found {
+				byKey[env.Key] = env
+			}
+		}
+
+		for i, operation := range operations {
+			env, found := byKey[operation.Key]
+			results[i] = EnvOperationResult{Op: operation.Op, Key: operation.Key}
+
+			var err error
+			switch {
+			case operation.Op == EnvDeleteOperation && !found:
+				err = EnvKeyNotFoundError
+			case operation.Op == EnvDeleteOperation:
+				err = tx.Unscoped().Delete(&models.EnvironmentKey{}, env.ID).Error
+				results[i].Event = models.EnvDeletedEvent
+			case found:
+				env.Value = operation.Value
+				err = updateEnvKey(tx, &env)
+				results[i].Event = models.EnvUpdatedEvent
+			default:
+				env = models.EnvironmentKey{ProjectID: projectID, Key: operation.Key, Value: operation.Value}
+				err = tx.Create(&env).Error
+				results[i].Event = models.EnvCreatedEvent
+			}
+			if err != nil {
+				return &EnvOperationError{Index: i, Err: err}
+			}
+			results[i].Version = env.Version
+		}
+		return nil
+	})
+	return results, err
+}
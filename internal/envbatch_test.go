@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvBatchInputs(t *testing.T) {
+	t.Run("valid batch", func(t *testing.T) {
+		batch := EnvBatchInputs{Operations: []EnvOperationInputs{
+			{Op: EnvSetOperation, Key: "API_URL", Value: "http://api"},
+			{Op: EnvDeleteOperation, Key: "API_TOKEN"},
+		}}
+		errs, err := batch.Validate(2)
+		assert.NoError(t, err)
+		assert.Equal(t, []error{nil, nil}, errs)
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		_, err := (&EnvBatchInputs{}).Validate(2)
+		assert.Error(t, err)
+	})
+
+	t.Run("batch too large", func(t *testing.T) {
+		batch := EnvBatchInputs{Operations: make([]EnvOperationInputs, 3)}
+		_, err := batch.Validate(2)
+		assert.ErrorIs(t, err, EnvBatchTooLargeError)
+	})
+
+	t.Run("invalid operations", func(t *testing.T) {
+		batch := EnvBatchInputs{Operations: []EnvOperationInputs{
+			{Op: EnvSetOperation, Key: "API_URL", Value: "http://api"},
+			{Op: EnvSetOperation, Key: "API_TOKEN"},
+			{Op: "rename", Key: "API_KEY"},
+			{Op: EnvDeleteOperation, Key: "API_URL"},
+		}}
+		errs, err := batch.Validate(10)
+		assert.ErrorIs(t, err, InvalidEnvBatchError)
+		assert.NoError(t, errs[0])
+		assert.Error(t, errs[1])
+		assert.ErrorIs(t, errs[2], InvalidEnvOperationError)
+		assert.ErrorIs(t, errs[3], DuplicateEnvOperationError)
+	})
+}
+
+func TestApplyEnvOperations(t *testing.T) {
+	db, _ := setupDB(t)
+	project := models.Project{Name: fmt.Sprintf("bulk-%d", time.Now().UnixNano())}
+	assert.NoError(t, db.CreateProject(&project))
+	defer db.DeleteProjectByID(project.ID)
+
+	t.Run("apply all the operations", func(t *testing.T) {
+		results, err := db.ApplyEnvOperations(project.ID, []EnvOperation{
+			{Op: EnvSetOperation, Key: "API_URL", Value: []byte("a")},
+			{Op: EnvSetOperation, Key: "API_TOKEN", Value: []byte("b")},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, models.EnvCreatedEvent, results[0].Event)
+		assert.Equal(t, models.EnvCreatedEvent, results[1].Event)
+	})
+
+	t.Run("roll back all the operations", func(t *testing.T) {
+		_, err := db.ApplyEnvOperations(project.ID, []EnvOperation{
+			{Op: EnvSetOperation, Key: "API_URL", Value: []byte("c")},
+			{Op: EnvDeleteOperation, Key: "API_KEY"},
+		})
+		var operationErr *EnvOperationError
+		assert.ErrorAs(t, err, &operationErr)
+		assert.Equal(t, 1, operationErr.Index)
+		assert.ErrorIs(t, err, EnvKeyNotFoundError)
+
+		env, _, err := db.GetEnvKeysAndValuesById(project.ID, ListFilter{NamePrefix: "API_URL"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("a"), env[0].Value)
+		assert.Equal(t, 1, env[0].Version)
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		results, err := db.ApplyEnvOperations(project.ID, []EnvOperation{
+			{Op: EnvSetOperation, Key: "API_URL", Value: []byte("c")},
+			{Op: EnvDeleteOperation, Key: "API_TOKEN"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, models.EnvUpdatedEvent, results[0].Event)
+		assert.Equal(t, 2, results[0].Version)
+		assert.Equal(t, models.EnvDeletedEvent, results[1].Event)
+	})
+}
@@ -6,14 +6,48 @@ import (
 )
 
 var (
-	cantLoadConfigFileError   = errors.New("failed to open config file, Please make sure that you have a config file called config.toml in your main root, please see the ./config.toml.template")
-	cantDecodeConfigError     = errors.New("failed to decode config from reader")
-	InternalServerError       = errors.New("something went wrong")
-	UserEmailNotUniqueError   = errors.New("the user email field must be unique")
-	UserIdNotProvidedError    = errors.New("user id should be provided")
-	ProjectIdNotProvidedError = errors.New("project id should be provided")
+	cantLoadConfigFileError    = errors.New("failed to open config file, Please make sure that you have a config file called config.toml in your main root, please see the ./config.toml.template")
+	cantDecodeConfigError      = errors.New("failed to decode config from reader")
+	InternalServerError        = errors.New("something went wrong")
+	UserEmailNotUniqueError    = errors.New("the user email field must be unique")
+	UserIdNotProvidedError     = errors.New("user id should be provided")
+	ProjectIdNotProvidedError  = errors.New("project id should be provided")
+	InvalidTOTPSecretError     = errors.New("invalid totp secret")
+	InvalidMFACodeError        = errors.New("invalid authentication code")
+	MFANotEnrolledError        = errors.New("two-factor authentication is not enrolled")
+	MFAAlreadyEnabledError     = errors.New("two-factor authentication is already enabled")
+	MFARequiredError           = errors.New("this project requires two-factor authentication to read production keys")
+	InvalidActionTokenError    = errors.New("the token is invalid, expired or already used")
+	EmailNotVerifiedError      = errors.New("the email address is not verified")
+	AccountLockedError         = errors.New("the account is temporarily locked after too many failed signins")
+	TooManyFailedSigninsError  = errors.New("too many failed signins from this address")
+	AdminRequiredError         = errors.New("this action requires an administrator")
+	UserSuspendedError         = errors.New("the account is suspended")
+	ForbiddenUserError         = errors.New("users can only access their own profile")
+	ProjectOwnerRequiredError  = errors.New("this action requires the project owner")
+	InvalidWebhookURLError     = errors.New("the webhook url must be an absolute http or https url")
+	PrivateWebhookURLError     = errors.New("the webhook url must not target a loopback, private or link-local address")
+	InvalidWebhookEventError   = errors.New("unknown webhook event")
+	InvalidCipherTextError     = errors.New("the encrypted value is too short")
+	UndecryptableEnvValueError = errors.New("the env value can't be decrypted, values stored by older versions must be set again")
+	InvalidSortError           = errors.New("the list can't be sorted by this field")
+	InvalidCursorError         = errors.New("the cursor is invalid or was returned for another sort")
+	InvalidEnvOperationError   = errors.New("unknown env operation")
+	DuplicateEnvOperationError = errors.New("the key is changed by another operation of the batch")
+	EnvBatchTooLargeError      = errors.New("the batch exceeds the maximum size")
+	InvalidEnvBatchError       = errors.New("some operations of the batch are invalid")
+	EnvKeyNotFoundError        = errors.New("the env key doesn't exist")
+	DatabaseNotConnectedError  = errors.New("the database is not connected")
 )
 
 func missingKeyError(keyName string) error {
 	return fmt.Errorf("the %s key is missing in the config file", keyName)
 }
+
+func unknownKeyError(keyName string) error {
+	return fmt.Errorf("the %s key is unknown in the config file", keyName)
+}
+
+func invalidKeyError(keyName string, value interface{}) error {
+	return fmt.Errorf("the %s key has an invalid value %v in the config file", keyName, value)
+}
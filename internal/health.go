@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Statuses of the health checks.
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing"
+)
+
+// ComponentHealth is the result of the health check of a component.
+// It never holds the error of the check, e.g. the database address, since the probes aren't authenticated.
+type ComponentHealth struct {
+	Status string `json:"status"`
+}
+
+// NewComponentHealth returns the health of a component from the error of its check.
+func NewComponentHealth(err error) ComponentHealth {
+	if err != nil {
+		return ComponentHealth{Status: HealthFailing}
+	}
+	return ComponentHealth{Status: HealthOK}
+}
+
+// Ping checks that a connection of the pool can reach the database.
+func (d *Database) Ping(ctx context.Context) error {
+	if d.db == nil {
+		return DatabaseNotConnectedError
+	}
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// PendingMigrations returns the tables and columns of the models that are missing from the database, e.g. "users.mfa_enabled".
+func (d *Database) PendingMigrations(ctx context.Context) ([]string, error) {
+	if d.db == nil {
+		return nil, DatabaseNotConnectedError
+	}
+	db := d.db.WithContext(ctx)
+	migrator := db.Migrator()
+
+	var pending []string
+	for _, table := range migratedTables {
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(table); err != nil {
+			return nil, err
+		}
+
+		if !migrator.HasTable(table) {
+			pending = append(pending, statement.Schema.Table)
+			continue
+		}
+		for _, column := range statement.Schema.DBNames {
+			if !migrator.HasColumn(table, column) {
+				pending = append(pending, fmt.Sprintf("%s.%s", statement.Schema.Table, column))
+			}
+		}
+	}
+	return pending, nil
+}
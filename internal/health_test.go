@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthChecks(t *testing.T) {
+	t.Run("not connected", func(t *testing.T) {
+		db := NewDatabase()
+		assert.ErrorIs(t, db.Ping(context.Background()), DatabaseNotConnectedError)
+
+		_, err := db.PendingMigrations(context.Background())
+		assert.ErrorIs(t, err, DatabaseNotConnectedError)
+	})
+
+	t.Run("migrated database", func(t *testing.T) {
+		db, _ := setupDB(t)
+		assert.NoError(t, db.Ping(context.Background()))
+
+		pending, err := db.PendingMigrations(context.Background())
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+}
@@ -24,3 +24,52 @@ type EnvironmentKeyInputs struct {
 	Key   string
 	Value string
 }
+
+// EnvOperationInputs represents an operation of a bulk env request.
+// The set operation creates the key or updates its value, the delete operation deletes it.
+type EnvOperationInputs struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// EnvBatchInputs represents the input data for applying several env operations at once.
+type EnvBatchInputs struct {
+	Operations []EnvOperationInputs `json:"operations"`
+}
+
+// MFACodeInputs represents the input data for verifying or disabling two-factor authentication.
+type MFACodeInputs struct {
+	Code string `json:"code"`
+}
+
+// MFASigninInputs represents the input data for the second step of the signin process.
+// Code is either a TOTP code or one of the user recovery codes.
+type MFASigninInputs struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// EmailInputs represents the input data for requesting a password reset or a verification email.
+type EmailInputs struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetInputs represents the input data for resetting a password with a reset token.
+type PasswordResetInputs struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// TokenInputs represents the input data for endpoints consuming an emailed token.
+type TokenInputs struct {
+	Token string `json:"token"`
+}
+
+// WebhookInputs represents the input data for creating a project webhook.
+// The secret is generated if it's not provided.
+type WebhookInputs struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// DefaultAccessTokenTTL is the lifetime of the access tokens if it's not configured.
+	DefaultAccessTokenTTL = 24 * time.Hour
+	// minKeyGracePeriod is the minimum time a retired key stays valid, it covers the emailed action tokens.
+	minKeyGracePeriod = 24 * time.Hour
+	// rsaKeyBits is the size of generated RSA keys.
+	rsaKeyBits = 2048
+)
+
+// SigningMethodEdDSA signs tokens with Ed25519 keys, it's not provided by the jwt package.
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+type signingMethodEdDSA struct{}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// SigningKey is a key used to sign or verify tokens, identified by its kid.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   interface{}
+	Public    interface{}
+	RetiredAt time.Time
+}
+
+// KeySet holds the keys used to sign and verify JWT tokens.
+// The first active key signs new tokens, retired keys only verify tokens until their grace period ends.
+type KeySet struct {
+	keys    map[string]*SigningKey
+	signing *SigningKey
+	hmac    []byte
+}
+
+// NewHMACKeySet creates a key set signing with HS256 and the shared secret, used when no asymmetric keys are configured.
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{hmac: []byte(secret)}
+}
+
+// LoadKeySet loads the configured asymmetric keys, or falls back to the shared jwt secret if there are none.
+func LoadKeySet(config ServerConfig) (*KeySet, error) {
+	if len(config.JWTKeys) == 0 {
+		return NewHMACKeySet(config.JWTSecretKey), nil
+	}
+
+	gracePeriod := config.AccessTokenTTLOrDefault()
+	if gracePeriod < minKeyGracePeriod {
+		gracePeriod = minKeyGracePeriod
+	}
+
+	set := &KeySet{keys: map[string]*SigningKey{}}
+	now := time.Now()
+	for _, keyConfig := range config.JWTKeys {
+		retired := !keyConfig.RetiredAt.IsZero()
+		// Tokens signed by this key are all expired, so it's no longer needed.
+		if retired && now.After(keyConfig.RetiredAt.Add(gracePeriod)) {
+			continue
+		}
+
+		key, err := readSigningKey(keyConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		set.keys[key.ID] = key
+		if !retired && set.signing == nil {
+			set.signing = key
+		}
+	}
+
+	if set.signing == nil {
+		return nil, errors.New("no active jwt signing key is configured")
+	}
+	return set, nil
+}
+
+// readSigningKey reads a PEM encoded RSA or Ed25519 private key.
+func readSigningKey(config JWTKeyConfig) (*SigningKey, error) {
+	content, err := os.ReadFile(config.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt key %s: %w", config.ID, err)
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("jwt key %s is not PEM encoded", config.ID)
+	}
+
+	var privateKey interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwt key %s: %w", config.ID, err)
+	}
+
+	key := &SigningKey{ID: config.ID, Private: privateKey, RetiredAt: config.RetiredAt}
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.Public = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method = SigningMethodEdDSA
+		key.Public = k.Public()
+	default:
+		return nil, fmt.Errorf("jwt key %s has an unsupported type %T", config.ID, privateKey)
+	}
+	return key, nil
+}
+
+// Sign signs the claims with the active key, setting the kid header.
+func (k *KeySet) Sign(claims jwt.MapClaims) (string, error) {
+	if k.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.hmac)
+	}
+
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signing.Private)
+}
+
+// Keyfunc returns the verification key of a token based on its kid, it rejects tokens whose algorithm doesn't match the key.
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if k.signing == nil {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return k.hmac, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+	}
+	return key.Public, nil
+}
+
+// JWK is a public JSON Web Key as defined by RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set, the shared HMAC secret is never published.
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// GenerateJWTKeyFile generates a new "rsa" or "ed25519" private key and writes it PEM encoded to the path.
+func GenerateJWTKeyFile(path, keyType string) error {
+	var privateKey interface{}
+	var err error
+
+	switch keyType {
+	case "rsa":
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case "ed25519":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return fmt.Errorf("unsupported jwt key type %q", keyType)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	content := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(path, content, 0600)
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// Create a key file of the given type in a temporary directory.
+func createJWTKeyFile(t *testing.T, keyType string) string {
+	path := filepath.Join(t.TempDir(), keyType+".pem")
+	err := GenerateJWTKeyFile(path, keyType)
+	assert.NoError(t, err)
+	return path
+}
+
+// Test signing and verifying tokens with the shared secret.
+func TestHMACKeySet(t *testing.T) {
+	keys, err := LoadKeySet(ServerConfig{JWTSecretKey: "xyz"})
+	assert.NoError(t, err)
+
+	token, err := keys.Sign(jwt.MapClaims{"id": 1})
+	assert.NoError(t, err)
+
+	parsed, err := jwt.Parse(token, keys.Keyfunc)
+	assert.NoError(t, err)
+	assert.True(t, parsed.Valid)
+
+	t.Run("secret is never published", func(t *testing.T) {
+		assert.Empty(t, keys.JWKS().Keys)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		_, err := jwt.Parse(token, NewHMACKeySet("abc").Keyfunc)
+		assert.Error(t, err)
+	})
+}
+
+// Test signing with asymmetric keys and verifying with rotated keys.
+func TestAsymmetricKeySet(t *testing.T) {
+	rsaPath := createJWTKeyFile(t, "rsa")
+	edPath := createJWTKeyFile(t, "ed25519")
+
+	oldKeys, err := LoadKeySet(ServerConfig{JWTKeys: []JWTKeyConfig{{ID: "old", PrivateKeyFile: rsaPath}}})
+	assert.NoError(t, err)
+
+	oldToken, err := oldKeys.Sign(jwt.MapClaims{"id": 1})
+	assert.NoError(t, err)
+
+	rotatedKeys, err := LoadKeySet(ServerConfig{JWTKeys: []JWTKeyConfig{
+		{ID: "new", PrivateKeyFile: edPath},
+		{ID: "old", PrivateKeyFile: rsaPath, RetiredAt: time.Now()},
+	}})
+	assert.NoError(t, err)
+
+	t.Run("new tokens are signed with the active key", func(t *testing.T) {
+		token, err := rotatedKeys.Sign(jwt.MapClaims{"id": 1})
+		assert.NoError(t, err)
+
+		parsed, err := jwt.Parse(token, rotatedKeys.Keyfunc)
+		assert.NoError(t, err)
+		assert.Equal(t, "new", parsed.Header["kid"])
+		assert.Equal(t, "EdDSA", parsed.Method.Alg())
+	})
+
+	t.Run("retired key still verifies its tokens", func(t *testing.T) {
+		parsed, err := jwt.Parse(oldToken, rotatedKeys.Keyfunc)
+		assert.NoError(t, err)
+		assert.Equal(t, "RS256", parsed.Method.Alg())
+	})
+
+	t.Run("expired retired key is dropped", func(t *testing.T) {
+		keys, err := LoadKeySet(ServerConfig{JWTKeys: []JWTKeyConfig{
+			{ID: "new", PrivateKeyFile: edPath},
+			{ID: "old", PrivateKeyFile: rsaPath, RetiredAt: time.Now().Add(-48 * time.Hour)},
+		}})
+		assert.NoError(t, err)
+
+		_, err = jwt.Parse(oldToken, keys.Keyfunc)
+		assert.Error(t, err)
+		assert.Len(t, keys.JWKS().Keys, 1)
+	})
+
+	t.Run("jwks publishes the public keys", func(t *testing.T) {
+		jwks := rotatedKeys.JWKS()
+		assert.Len(t, jwks.Keys, 2)
+		for _, key := range jwks.Keys {
+			switch key.Kid {
+			case "new":
+				assert.Equal(t, "OKP", key.Kty)
+				assert.NotEmpty(t, key.X)
+			case "old":
+				assert.Equal(t, "RSA", key.Kty)
+				assert.NotEmpty(t, key.N)
+				assert.Equal(t, "AQAB", key.E)
+			}
+		}
+	})
+
+	t.Run("hmac token is rejected", func(t *testing.T) {
+		token, err := NewHMACKeySet("xyz").Sign(jwt.MapClaims{"id": 1})
+		assert.NoError(t, err)
+
+		_, err = jwt.Parse(token, rotatedKeys.Keyfunc)
+		assert.Error(t, err)
+	})
+
+	t.Run("no active key", func(t *testing.T) {
+		_, err := LoadKeySet(ServerConfig{JWTKeys: []JWTKeyConfig{
+			{ID: "old", PrivateKeyFile: rsaPath, RetiredAt: time.Now()},
+		}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm/logger"
+)
+
+// Formats of the logs.
+const (
+	LogFormatConsole = "console"
+	LogFormatJSON    = "json"
+)
+
+const (
+	// RedactedValue replaces the redacted values in the logs.
+	RedactedValue = "[REDACTED]"
+	// slowQueryThreshold is the duration above which the database queries are logged.
+	slowQueryThreshold = 200 * time.Millisecond
+)
+
+// sensitiveFields are the substrings of the names of the log fields whose values are always redacted.
+var sensitiveFields = []string{"password", "secret", "token", "authorization", "cookie", "value", "private_key", "recovery_code"}
+
+var (
+	// sensitiveAssignment matches the sensitive values assigned in free text, e.g. `password=omda` or `"value": "omda"`.
+	sensitiveAssignment = regexp.MustCompile(`(?i)((?:password|secret|token|value)"?\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s,;&"]+)`)
+	// jwtPattern matches the JSON web tokens.
+	jwtPattern = regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]+`)
+)
+
+// NewLogger creates a logger writing in the configured format to out.
+// Every event goes through the redaction, so that the env values, passwords and tokens never hit the logs.
+func NewLogger(config LogConfig, out io.Writer) zerolog.Logger {
+	if config.FormatOrDefault() == LogFormatConsole {
+		out = zerolog.ConsoleWriter{Out: out}
+	}
+	return zerolog.New(RedactingWriter(out)).With().Timestamp().Logger()
+}
+
+// RedactingWriter returns a writer replacing the sensitive values of the JSON log events with RedactedValue before writing them to out.
+// The values of the sensitive fields are redacted, and so are the sensitive assignments and tokens found in the other strings.
+func RedactingWriter(out io.Writer) io.Writer {
+	return redactingWriter{out: out}
+}
+
+type redactingWriter struct {
+	out io.Writer
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+
+	var event map[string]interface{}
+	if err := decoder.Decode(&event); err != nil {
+		// Not a JSON event, redact it as free text.
+		if _, err := w.out.Write([]byte(redactText(string(p)))); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	redacted, changed := redactValue(event)
+	if !changed {
+		return w.out.Write(p)
+	}
+
+	content, err := json.Marshal(redacted)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(append(content, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redactValue returns the value with its sensitive fields and strings redacted, and whether anything was redacted.
+func redactValue(value interface{}) (interface{}, bool) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		changed := false
+		for key, field := range value {
+			if isSensitiveField(key) {
+				value[key] = RedactedValue
+				changed = true
+				continue
+			}
+			redacted, fieldChanged := redactValue(field)
+			value[key] = redacted
+			changed = changed || fieldChanged
+		}
+		return value, changed
+	case []interface{}:
+		changed := false
+		for i, item := range value {
+			redacted, itemChanged := redactValue(item)
+			value[i] = redacted
+			changed = changed || itemChanged
+		}
+		return value, changed
+	case string:
+		redacted := redactText(value)
+		return redacted, redacted != value
+	default:
+		return value, false
+	}
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range sensitiveFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactText redacts the sensitive assignments and the tokens of a free text.
+func redactText(text string) string {
+	text = sensitiveAssignment.ReplaceAllString(text, "${1}"+RedactedValue)
+	return jwtPattern.ReplaceAllString(text, RedactedValue)
+}
+
+// newGormLogger logs the failed and slow queries through zerolog.
+// The statements are logged with placeholders, so that the encrypted values and password hashes never hit the logs.
+func newGormLogger() logger.Interface {
+	return logger.New(gormLogWriter{}, logger.Config{
+		SlowThreshold:             slowQueryThreshold,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      true,
+	})
+}
+
+type gormLogWriter struct{}
+
+func (gormLogWriter) Printf(format string, args ...interface{}) {
+	log.Warn().Msgf(format, args...)
+}
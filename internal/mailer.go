@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// SMTPMailBackend sends emails through an SMTP server.
+	SMTPMailBackend = "smtp"
+	// LogMailBackend writes emails to a file, or to the logs if no file is configured. Used for local testing.
+	LogMailBackend = "log"
+)
+
+// Mailer delivers emails to users.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer creates the mailer selected by the mail config backend, the log mailer is the default.
+func NewMailer(config MailConfig) (Mailer, error) {
+	switch config.Backend {
+	case SMTPMailBackend:
+		return NewSMTPMailer(config), nil
+	case LogMailBackend, "":
+		return NewLogMailer(config.LogFile), nil
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", config.Backend)
+	}
+}
+
+// SMTPMailer sends emails using an SMTP server.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailer creates a new SMTPMailer from the mail config.
+func NewSMTPMailer(config MailConfig) *SMTPMailer {
+	return &SMTPMailer{
+		Host:     config.Host,
+		Port:     config.Port,
+		Username: config.Username,
+		Password: config.Password,
+		From:     config.From,
+	}
+}
+
+// Send sends a plain text email through the SMTP server.
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, m.From, []string{to}, buildMessage(m.From, to, subject, body))
+}
+
+// LogMailer appends emails to a file instead of sending them, if Path is empty they are written to the logs.
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewLogMailer creates a new LogMailer writing to the given path.
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{Path: path}
+}
+
+// Send writes the email to the file or the logs.
+func (m *LogMailer) Send(to, subject, body string) error {
+	if m.Path == "" {
+		log.Info().Msgf("Mail to: %s | Subject: %s\n%s", to, subject, body)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\n%s\n", time.Now().Format(time.RFC1123Z), buildMessage("envserver", to, subject, body))
+	return err
+}
+
+// buildMessage formats a plain text email message with its headers.
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	b.WriteString(body)
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
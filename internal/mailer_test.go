@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test selecting the mailer from the config backend.
+func TestNewMailer(t *testing.T) {
+	t.Run("default log mailer", func(t *testing.T) {
+		mailer, err := NewMailer(MailConfig{})
+		assert.NoError(t, err)
+		assert.IsType(t, &LogMailer{}, mailer)
+	})
+
+	t.Run("smtp mailer", func(t *testing.T) {
+		mailer, err := NewMailer(MailConfig{Backend: SMTPMailBackend, Host: "localhost", Port: 25, From: "envserver@localhost"})
+		assert.NoError(t, err)
+		assert.IsType(t, &SMTPMailer{}, mailer)
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		_, err := NewMailer(MailConfig{Backend: "pigeon"})
+		assert.Error(t, err)
+	})
+}
+
+// Test the log mailer writes the emails to its file.
+func TestLogMailerSend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mails.log")
+	mailer := NewLogMailer(path)
+
+	err := mailer.Send("omda@gmail.com", "Reset your envserver password", "token")
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "To: omda@gmail.com")
+	assert.Contains(t, string(content), "Subject: Reset your envserver password")
+	assert.Contains(t, string(content), "token")
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const (
+	metricsNamespace = "envserver"
+	// metricsStartKey is the gorm instance key holding the start time of an instrumented query.
+	metricsStartKey = "envserver:metrics_start"
+)
+
+// Reasons of the counted authentication failures.
+const (
+	AuthFailureMissingToken       = "missing_token"
+	AuthFailureInvalidToken       = "invalid_token"
+	AuthFailureInvalidCredentials = "invalid_credentials"
+	AuthFailureLocked             = "locked"
+	AuthFailureInvalidClientCert  = "invalid_client_certificate"
+)
+
+// Metrics holds the Prometheus collectors of the server, a nil Metrics records nothing.
+type Metrics struct {
+	registry         *prometheus.Registry
+	requests         *prometheus.CounterVec
+	requestDurations *prometheus.HistogramVec
+	queryDurations   *prometheus.HistogramVec
+	queryErrors      *prometheus.CounterVec
+	authFailures     *prometheus.CounterVec
+}
+
+// NewMetrics creates the collectors of the server, and of the Go runtime and process.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of the HTTP requests by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		queryDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Duration of the database queries by operation and table.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "db_query_errors_total",
+			Help:      "Number of failed database queries by operation and table, missing records aren't counted.",
+		}, []string{"operation", "table"}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "auth_failures_total",
+			Help:      "Number of authentication failures by reason.",
+		}, []string{"reason"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDurations,
+		m.queryDurations,
+		m.queryErrors,
+		m.authFailures,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records an HTTP request, the route is the path template to keep the number of series bounded.
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	labels := prometheus.Labels{"route": route, "method": method, "status": strconv.Itoa(status)}
+	m.requests.With(labels).Inc()
+	m.requestDurations.With(labels).Observe(duration.Seconds())
+}
+
+// AuthFailure records an authentication failure.
+func (m *Metrics) AuthFailure(reason string) {
+	if m == nil {
+		return
+	}
+	m.authFailures.WithLabelValues(reason).Inc()
+}
+
+// observeQuery records a database query.
+func (m *Metrics) observeQuery(operation, table string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.queryDurations.WithLabelValues(operation, table).Observe(duration.Seconds())
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		m.queryErrors.WithLabelValues(operation, table).Inc()
+	}
+}
+
+// Instrument records the duration and the errors of the database queries.
+func (d *Database) Instrument(m *Metrics) error {
+	start := func(db *gorm.DB) {
+		db.InstanceSet(metricsStartKey, time.Now())
+	}
+	observe := func(operation string) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			if started, ok := db.InstanceGet(metricsStartKey); ok {
+				m.observeQuery(operation, db.Statement.Table, time.Since(started.(time.Time)), db.Error)
+			}
+		}
+	}
+
+	callbacks := d.db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", start),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", observe("create")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", start),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", observe("query")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", start),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", observe("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", start),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", observe("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", start),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", observe("row")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", start),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", observe("raw")),
+	)
+}
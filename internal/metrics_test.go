@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// scrapeMetrics returns the metrics served by the handler.
+func scrapeMetrics(t *testing.T, m *Metrics) string {
+	responseRecorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, responseRecorder.Code)
+
+	body, err := io.ReadAll(responseRecorder.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("requests and auth failures", func(t *testing.T) {
+		m := NewMetrics()
+		m.ObserveRequest("/api/v1/projects/{id}", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+		m.ObserveRequest("/api/v1/projects/{id}", http.MethodGet, http.StatusOK, 30*time.Millisecond)
+		m.AuthFailure(AuthFailureInvalidToken)
+
+		body := scrapeMetrics(t, m)
+		assert.Contains(t, body, `envserver_http_requests_total{method="GET",route="/api/v1/projects/{id}",status="200"} 2`)
+		assert.Contains(t, body, `envserver_http_request_duration_seconds_count{method="GET",route="/api/v1/projects/{id}",status="200"} 2`)
+		assert.Contains(t, body, `envserver_auth_failures_total{reason="invalid_token"} 1`)
+		assert.Contains(t, body, "go_goroutines")
+	})
+
+	t.Run("nil metrics", func(t *testing.T) {
+		var m *Metrics
+		assert.NotPanics(t, func() {
+			m.ObserveRequest("/", http.MethodGet, http.StatusOK, time.Millisecond)
+			m.AuthFailure(AuthFailureLocked)
+		})
+	})
+
+	t.Run("database queries", func(t *testing.T) {
+		// Queries aren't sent in dry run mode, so no database is needed.
+		conn, err := sql.Open("pgx", "host=localhost")
+		assert.NoError(t, err)
+		db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+		assert.NoError(t, err)
+
+		m := NewMetrics()
+		d := Database{db: db}
+		assert.NoError(t, d.Instrument(m))
+
+		var project models.Project
+		d.db.Find(&project, "name = ?", "backend")
+		d.db.Create(&models.Project{Name: "backend"})
+		m.observeQuery("query", "projects", time.Millisecond, errors.New("connection reset"))
+		m.observeQuery("query", "projects", time.Millisecond, gorm.ErrRecordNotFound)
+
+		body := scrapeMetrics(t, m)
+		assert.Contains(t, body, `envserver_db_query_duration_seconds_count{operation="query",table="projects"} 3`)
+		assert.Contains(t, body, `envserver_db_query_duration_seconds_count{operation="create",table="projects"} 1`)
+		assert.Contains(t, body, `envserver_db_query_errors_total{operation="query",table="projects"} 1`)
+	})
+}
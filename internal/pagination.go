@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultListLimit is the number of records returned by a list query without limit.
+	DefaultListLimit = 100
+	// MaxListLimit is the maximum number of records returned by a list query.
+	MaxListLimit = 1000
+)
+
+// ListFilter filters, sorts and paginates a list query, zero values are ignored.
+// Sort is the name of a sortable field, prefixed with "-" to sort in descending order, records are sorted by id by default.
+// Cursor is the next cursor returned with the previous page, the sort must be the same.
+type ListFilter struct {
+	NamePrefix      string
+	Owner           int
+	EnvironmentName string
+	UpdatedSince    time.Time
+	Sort            string
+	Cursor          string
+	Limit           int
+}
+
+// sortField is a field a list can be sorted by, value returns the field of a record to build the next cursor.
+type sortField[T any] struct {
+	column string
+	value  func(T) interface{}
+}
+
+// listCursor is the position of the last record of a page, encoded as base64 JSON.
+type listCursor struct {
+	Sort string    `json:"s"`
+	ID   int       `json:"i"`
+	Text string    `json:"t,omitempty"`
+	Time time.Time `json:"d,omitempty"`
+}
+
+var userSortFields = map[string]sortField[models.User]{
+	"id":         {"id", func(u models.User) interface{} { return nil }},
+	"email":      {"email", func(u models.User) interface{} { return u.Email }},
+	"first_name": {"first_name", func(u models.User) interface{} { return u.FirstName }},
+	"last_name":  {"last_name", func(u models.User) interface{} { return u.LastName }},
+	"created_at": {"created_at", func(u models.User) interface{} { return u.CreatedAt }},
+	"updated_at": {"updated_at", func(u models.User) interface{} { return u.UpdatedAt }},
+}
+
+var projectSortFields = map[string]sortField[models.Project]{
+	"id":               {"id", func(p models.Project) interface{} { return nil }},
+	"name":             {"name", func(p models.Project) interface{} { return p.Name }},
+	"environment_name": {"environment_name", func(p models.Project) interface{} { return p.EnvironmentName }},
+	"created_at":       {"created_at", func(p models.Project) interface{} { return p.CreatedAt }},
+	"updated_at":       {"updated_at", func(p models.Project) interface{} { return p.UpdatedAt }},
+}
+
+var envSortFields = map[string]sortField[models.EnvironmentKey]{
+	"id":         {"id", func(e models.EnvironmentKey) interface{} { return nil }},
+	"key":        {"key", func(e models.EnvironmentKey) interface{} { return e.Key }},
+	"created_at": {"created_at", func(e models.EnvironmentKey) interface{} { return e.CreatedAt }},
+	"updated_at": {"updated_at", func(e models.EnvironmentKey) interface{} { return e.UpdatedAt }},
+}
+
+// ListLimit returns the number of records returned for the requested limit.
+func ListLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		return MaxListLimit
+	}
+	return limit
+}
+
+// findPage sorts and paginates the query, and returns the records of the page with the cursor of the next page.
+// The next cursor is empty on the last page.
+func findPage[T any](query *gorm.DB, filter ListFilter, fields map[string]sortField[T], id func(T) int) ([]T, string, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = "id"
+	}
+	descending := strings.HasPrefix(sort, "-")
+	field, ok := fields[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, "", InvalidSortError
+	}
+
+	direction, comparison := "asc", ">"
+	if descending {
+		direction, comparison = "desc", "<"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeListCursor(filter.Cursor)
+		if err != nil || cursor.Sort != sort {
+			return nil, "", InvalidCursorError
+		}
+		value, err := cursorValue(field, cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if field.column == "id" {
+			query = query.Where("id "+comparison+" ?", cursor.ID)
+		} else {
+			query = query.Where("("+field.column+", id) "+comparison+" (?, ?)", value, cursor.ID)
+		}
+	}
+
+	if field.column != "id" {
+		query = query.Order(field.column + " " + direction)
+	}
+	limit := ListLimit(filter.Limit)
+
+	// Fetch one more record to know if there's a next page.
+	var records []T
+	if err := query.Order("id " + direction).Limit(limit + 1).Find(&records).Error; err != nil {
+		return nil, "", err
+	}
+	if len(records) <= limit {
+		return records, "", nil
+	}
+
+	records = records[:limit]
+	last := records[limit-1]
+	cursor := listCursor{Sort: sort, ID: id(last)}
+	switch value := field.value(last).(type) {
+	case string:
+		cursor.Text = value
+	case time.Time:
+		cursor.Time = value
+	}
+	return records, encodeListCursor(cursor), nil
+}
+
+// cursorValue returns the sort value of the cursor, it fails if its type doesn't match the sort field, e.g. a forged cursor
+// holding a text for a time field, so that it's never compared to a column of another type.
+func cursorValue[T any](field sortField[T], cursor listCursor) (interface{}, error) {
+	var zero T
+	switch field.value(zero).(type) {
+	case string:
+		if !cursor.Time.IsZero() {
+			return nil, InvalidCursorError
+		}
+		return cursor.Text, nil
+	case time.Time:
+		if cursor.Time.IsZero() || cursor.Text != "" {
+			return nil, InvalidCursorError
+		}
+		return cursor.Time, nil
+	default:
+		if cursor.Text != "" || !cursor.Time.IsZero() {
+			return nil, InvalidCursorError
+		}
+		return nil, nil
+	}
+}
+
+func encodeListCursor(cursor listCursor) string {
+	content, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func decodeListCursor(encoded string) (listCursor, error) {
+	var cursor listCursor
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(content, &cursor)
+	return cursor, err
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
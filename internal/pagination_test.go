@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListLimit(t *testing.T) {
+	assert.Equal(t, DefaultListLimit, ListLimit(0))
+	assert.Equal(t, 10, ListLimit(10))
+	assert.Equal(t, MaxListLimit, ListLimit(MaxListLimit+1))
+}
+
+func TestListCursor(t *testing.T) {
+	t.Run("encode and decode", func(t *testing.T) {
+		cursor := listCursor{Sort: "-updated_at", ID: 4, Time: time.Date(2023, 8, 1, 0, 0, 0, 1000, time.UTC)}
+		decoded, err := decodeListCursor(encodeListCursor(cursor))
+		assert.NoError(t, err)
+		assert.Equal(t, cursor, decoded)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := decodeListCursor("not a cursor")
+		assert.Error(t, err)
+	})
+	t.Run("cursor value of the sort field type", func(t *testing.T) {
+		date := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+		value, err := cursorValue(projectSortFields["name"], listCursor{Sort: "name", ID: 4, Text: "backend"})
+		assert.NoError(t, err)
+		assert.Equal(t, "backend", value)
+
+		value, err = cursorValue(projectSortFields["created_at"], listCursor{Sort: "created_at", ID: 4, Time: date})
+		assert.NoError(t, err)
+		assert.Equal(t, date, value)
+
+		_, err = cursorValue(projectSortFields["id"], listCursor{Sort: "id", ID: 4})
+		assert.NoError(t, err)
+	})
+
+	t.Run("cursor value of another type", func(t *testing.T) {
+		_, err := cursorValue(projectSortFields["created_at"], listCursor{Sort: "created_at", ID: 4, Text: "yesterday"})
+		assert.ErrorIs(t, err, InvalidCursorError)
+
+		_, err = cursorValue(projectSortFields["name"], listCursor{Sort: "name", ID: 4, Time: time.Now()})
+		assert.ErrorIs(t, err, InvalidCursorError)
+
+		_, err = cursorValue(projectSortFields["id"], listCursor{Sort: "id", ID: 4, Text: "backend"})
+		assert.ErrorIs(t, err, InvalidCursorError)
+	})
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `API\_KEY\%\\`, escapeLike(`API_KEY%\`))
+}
+
+func TestProjectsPagination(t *testing.T) {
+	db, _ := setupDB(t)
+	prefix := fmt.Sprintf("paginated-%d-", time.Now().UnixNano())
+	for i := 0; i < 5; i++ {
+		err := db.CreateProject(&models.Project{Name: fmt.Sprintf("%s%d", prefix, i), EnvironmentName: "production"})
+		assert.NoError(t, err)
+	}
+	defer func() {
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, db.DeleteProjectByName(fmt.Sprintf("%s%d", prefix, i)))
+		}
+	}()
+
+	t.Run("iterate the pages", func(t *testing.T) {
+		filter := ListFilter{NamePrefix: prefix, Sort: "-name", Limit: 2}
+		var names []string
+		for {
+			projects, next, err := db.GetProjects(filter)
+			assert.NoError(t, err)
+			for _, project := range projects {
+				names = append(names, project.Name)
+			}
+			if next == "" {
+				break
+			}
+			filter.Cursor = next
+		}
+		assert.Equal(t, []string{prefix + "4", prefix + "3", prefix + "2", prefix + "1", prefix + "0"}, names)
+	})
+
+	t.Run("filter by environment name", func(t *testing.T) {
+		projects, _, err := db.GetProjects(ListFilter{NamePrefix: prefix, EnvironmentName: "development"})
+		assert.NoError(t, err)
+		assert.Empty(t, projects)
+	})
+
+	t.Run("cursor of another sort", func(t *testing.T) {
+		_, next, err := db.GetProjects(ListFilter{NamePrefix: prefix, Limit: 1})
+		assert.NoError(t, err)
+
+		_, _, err = db.GetProjects(ListFilter{NamePrefix: prefix, Sort: "name", Cursor: next})
+		assert.ErrorIs(t, err, InvalidCursorError)
+	})
+
+	t.Run("unknown sort", func(t *testing.T) {
+		_, _, err := db.GetProjects(ListFilter{Sort: "password"})
+		assert.ErrorIs(t, err, InvalidSortError)
+	})
+}
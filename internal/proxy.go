@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"net"
+	"strings"
+)
+
+// TrustedProxies holds the networks of the reverse proxies whose X-Forwarded-For header is trusted.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses the IP addresses and CIDR ranges of the trusted proxies.
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, invalidKeyError("server trusted_proxies", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, invalidKeyError("server trusted_proxies", value)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Contains returns true if the address is one of a trusted proxy.
+func (p TrustedProxies) Contains(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client of a request received from remoteIP, with the values of its X-Forwarded-For headers.
+// The headers are only read if remoteIP is a trusted proxy, then the client is the rightmost address that isn't a trusted proxy,
+// since the addresses on its left can be set by the client.
+func (p TrustedProxies) ClientIP(remoteIP string, forwardedFor []string) string {
+	if !p.Contains(remoteIP) {
+		return remoteIP
+	}
+
+	var addresses []string
+	for _, header := range forwardedFor {
+		for _, address := range strings.Split(header, ",") {
+			addresses = append(addresses, strings.TrimSpace(address))
+		}
+	}
+
+	clientIP := remoteIP
+	for i := len(addresses) - 1; i >= 0; i-- {
+		if net.ParseIP(addresses[i]) == nil {
+			// The chain is broken, don't trust the addresses on its left.
+			break
+		}
+		clientIP = addresses[i]
+		if !p.Contains(addresses[i]) {
+			break
+		}
+	}
+	return clientIP
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	assert.NoError(t, err)
+	assert.True(t, proxies.Contains("10.1.2.3"))
+	assert.True(t, proxies.Contains("192.168.1.1"))
+	assert.True(t, proxies.Contains("::1"))
+	assert.False(t, proxies.Contains("192.168.1.2"))
+	assert.False(t, proxies.Contains("unknown"))
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.EqualError(t, err, "the server trusted_proxies key has an invalid value 10.0.0.0/33 in the config file")
+	_, err = ParseTrustedProxies([]string{"proxy.example.com"})
+	assert.Error(t, err)
+}
+
+// Test the client address is only taken from X-Forwarded-For behind a trusted proxy.
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+
+	t.Run("untrusted remote address", func(t *testing.T) {
+		assert.Equal(t, "203.0.113.7", proxies.ClientIP("203.0.113.7", []string{"198.51.100.1"}))
+	})
+
+	t.Run("trusted proxy", func(t *testing.T) {
+		assert.Equal(t, "198.51.100.1", proxies.ClientIP("10.0.0.1", []string{"198.51.100.1"}))
+		assert.Equal(t, "10.0.0.1", proxies.ClientIP("10.0.0.1", nil))
+	})
+
+	t.Run("spoofed addresses on the left are ignored", func(t *testing.T) {
+		assert.Equal(t, "198.51.100.1", proxies.ClientIP("10.0.0.1", []string{"1.2.3.4, 198.51.100.1, 10.0.0.2"}))
+		assert.Equal(t, "198.51.100.1", proxies.ClientIP("10.0.0.1", []string{"1.2.3.4", "198.51.100.1"}))
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		assert.Equal(t, "10.0.0.2", proxies.ClientIP("10.0.0.1", []string{"198.51.100.1, garbage, 10.0.0.2"}))
+	})
+}
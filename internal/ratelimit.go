@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Budgets of the rate limiter, every request is counted in one of them.
+const (
+	DefaultRateLimitBudget = "default"
+	AuthRateLimitBudget    = "auth"
+	SecretsRateLimitBudget = "secrets"
+)
+
+// Default rate limits, in requests per minute and burst size.
+const (
+	DefaultRequestsPerMinute       = 600
+	DefaultRequestsBurst           = 100
+	DefaultAuthRequestsPerMinute   = 30
+	DefaultAuthRequestsBurst       = 10
+	DefaultSecretRequestsPerMinute = 300
+	DefaultSecretRequestsBurst     = 50
+)
+
+// rateLimitCleanupInterval is the minimum interval between two cleanups of the full buckets of the memory store.
+const rateLimitCleanupInterval = time.Minute
+
+// RateLimit is a token bucket refilled at Rate tokens per second up to Burst tokens, every request takes a token.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitResult is the state of a bucket after a request took a token.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// ResetAfter is the delay until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is the delay until a rejected request can be retried, zero if the request is allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets of the rate limiter by key.
+// The memory store limits each replica on its own, a store shared by the replicas (e.g. Redis) can implement it to enforce global limits.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of the key, and returns whether the request is allowed.
+	Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// MemoryRateLimitStore keeps the token buckets in memory.
+type MemoryRateLimitStore struct {
+	mu          sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   RateLimit
+}
+
+// NewMemoryRateLimitStore creates an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}}
+}
+
+// Take takes a token from the bucket of the key, a new bucket starts full.
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanup(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = bucket
+	}
+	bucket.limit = limit
+	bucket.refill(now)
+
+	result := RateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = limit.delay(1 - bucket.tokens)
+	}
+	result.Remaining = int(bucket.tokens)
+	result.ResetAfter = limit.delay(float64(limit.Burst) - bucket.tokens)
+	return result, nil
+}
+
+// refill adds the tokens earned since the last update.
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens += elapsed * b.limit.Rate
+		b.updated = now
+	}
+	// The burst may have been lowered by a config reload.
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens)
+}
+
+// cleanup drops the buckets that are full again, they're the same as new ones.
+func (s *MemoryRateLimitStore) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < rateLimitCleanupInterval {
+		return
+	}
+	s.lastCleanup = now
+
+	for key, bucket := range s.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= float64(bucket.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// delay returns the time needed to earn the tokens.
+func (l RateLimit) delay(tokens float64) time.Duration {
+	if tokens <= 0 || l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// RateLimiter limits the requests of every client, with a separate budget for each kind of request.
+type RateLimiter struct {
+	store RateLimitStore
+
+	mu      sync.RWMutex
+	budgets map[string]RateLimit
+}
+
+// NewRateLimiter creates a RateLimiter with the configured budgets, using defaults for unset values.
+func NewRateLimiter(config RateLimitConfig, store RateLimitStore) *RateLimiter {
+	l := &RateLimiter{store: store}
+	l.Configure(config)
+	return l
+}
+
+// Configure replaces the budgets, e.g. when the config is reloaded. The buckets are kept and refilled at their new rate.
+func (l *RateLimiter) Configure(config RateLimitConfig) {
+	budgets := map[string]RateLimit{
+		DefaultRateLimitBudget: config.Default.limitOrDefault(DefaultRequestsPerMinute, DefaultRequestsBurst),
+		AuthRateLimitBudget:    config.Auth.limitOrDefault(DefaultAuthRequestsPerMinute, DefaultAuthRequestsBurst),
+		SecretsRateLimitBudget: config.Secrets.limitOrDefault(DefaultSecretRequestsPerMinute, DefaultSecretRequestsBurst),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.budgets = budgets
+}
+
+// Allow takes a token from the budget of the client, identified by key, and returns the limit of the budget with the result.
+func (l *RateLimiter) Allow(budget, key string, now time.Time) (RateLimit, RateLimitResult, error) {
+	l.mu.RLock()
+	limit, ok := l.budgets[budget]
+	if !ok {
+		limit = l.budgets[DefaultRateLimitBudget]
+	}
+	l.mu.RUnlock()
+
+	result, err := l.store.Take(budget+":"+key, limit, now)
+	return limit, result, err
+}
+
+// limitOrDefault returns the token bucket of the budget.
+func (b RateLimitBudgetConfig) limitOrDefault(requestsPerMinute, burst int) RateLimit {
+	if b.RequestsPerMinute != 0 {
+		requestsPerMinute = b.RequestsPerMinute
+	}
+	if b.Burst != 0 {
+		burst = b.Burst
+	}
+	return RateLimit{Rate: float64(requestsPerMinute) / 60, Burst: burst}
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	limit := RateLimit{Rate: 1, Burst: 2}
+	now := time.Now()
+
+	t.Run("burst then reject", func(t *testing.T) {
+		store := NewMemoryRateLimitStore()
+
+		result, err := store.Take("ip:10.0.0.1", limit, now)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 1, result.Remaining)
+		assert.Equal(t, time.Second, result.ResetAfter)
+
+		result, _ = store.Take("ip:10.0.0.1", limit, now)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+
+		result, _ = store.Take("ip:10.0.0.1", limit, now)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Second, result.RetryAfter)
+		assert.Equal(t, 2*time.Second, result.ResetAfter)
+
+		result, _ = store.Take("ip:10.0.0.2", limit, now)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		store := NewMemoryRateLimitStore()
+		store.Take("user:1", limit, now)
+		store.Take("user:1", limit, now)
+
+		result, _ := store.Take("user:1", limit, now.Add(500*time.Millisecond))
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+
+		result, _ = store.Take("user:1", limit, now.Add(time.Second))
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("cleanup of full buckets", func(t *testing.T) {
+		store := NewMemoryRateLimitStore()
+		store.Take("user:1", limit, now)
+		store.Take("user:2", limit, now.Add(2*time.Minute))
+
+		assert.Len(t, store.buckets, 1)
+		assert.Contains(t, store.buckets, "user:2")
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("separate budgets", func(t *testing.T) {
+		limiter := NewRateLimiter(RateLimitConfig{Auth: RateLimitBudgetConfig{RequestsPerMinute: 60, Burst: 1}}, NewMemoryRateLimitStore())
+		now := time.Now()
+
+		limit, result, err := limiter.Allow(AuthRateLimitBudget, "ip:10.0.0.1", now)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, RateLimit{Rate: 1, Burst: 1}, limit)
+
+		_, result, _ = limiter.Allow(AuthRateLimitBudget, "ip:10.0.0.1", now)
+		assert.False(t, result.Allowed)
+
+		limit, result, _ = limiter.Allow(DefaultRateLimitBudget, "ip:10.0.0.1", now)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, DefaultRequestsBurst, limit.Burst)
+	})
+
+	t.Run("reconfigured budgets", func(t *testing.T) {
+		limiter := NewRateLimiter(RateLimitConfig{}, NewMemoryRateLimitStore())
+		now := time.Now()
+		_, result, _ := limiter.Allow(DefaultRateLimitBudget, "ip:10.0.0.1", now)
+		assert.Equal(t, DefaultRequestsBurst-1, result.Remaining)
+
+		limiter.Configure(RateLimitConfig{Default: RateLimitBudgetConfig{RequestsPerMinute: 60, Burst: 2}})
+		limit, result, _ := limiter.Allow(DefaultRateLimitBudget, "ip:10.0.0.1", now)
+		assert.Equal(t, 2, limit.Burst)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 1, result.Remaining)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := ReadConfigFromString(fileContent + "[rate_limit.secrets]\nburst = -1\n")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxFailedSignins is the number of failed signins before an account is locked.
+	DefaultMaxFailedSignins = 5
+	// DefaultMaxFailedSigninsPerIP is the number of failed signins before an IP is locked.
+	DefaultMaxFailedSigninsPerIP = 20
+	// DefaultLockoutDuration is the duration of the first lockout.
+	DefaultLockoutDuration = 15 * time.Minute
+	// DefaultMaxLockoutDuration caps the exponential backoff of the lockout duration.
+	DefaultMaxLockoutDuration = 24 * time.Hour
+)
+
+// LockoutDuration returns how long to lock after the given number of failures.
+// Nothing is locked under maxAttempts failures, then the base duration doubles with every extra failure up to max.
+func LockoutDuration(failures, maxAttempts int, base, max time.Duration) time.Duration {
+	if failures < maxAttempts {
+		return 0
+	}
+
+	duration := base
+	for i := maxAttempts; i < failures; i++ {
+		duration *= 2
+		if duration >= max {
+			return max
+		}
+	}
+	return duration
+}
+
+// LoginThrottler tracks failed signin attempts in memory by key (e.g. a client IP) and locks keys with exponential backoff.
+type LoginThrottler struct {
+	mu          sync.Mutex
+	attempts    map[string]*failedAttempts
+	maxAttempts int
+	base        time.Duration
+	max         time.Duration
+}
+
+type failedAttempts struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// NewLoginThrottler creates a LoginThrottler locking a key after maxAttempts failures.
+func NewLoginThrottler(maxAttempts int, base, max time.Duration) *LoginThrottler {
+	return &LoginThrottler{
+		attempts:    map[string]*failedAttempts{},
+		maxAttempts: maxAttempts,
+		base:        base,
+		max:         max,
+	}
+}
+
+// NewIPSigninThrottler creates the per-IP LoginThrottler from the server config, using defaults for unset values.
+func NewIPSigninThrottler(config ServerConfig) *LoginThrottler {
+	return NewLoginThrottler(config.MaxFailedSigninsPerIPOrDefault(), config.LockoutDurationOrDefault(), config.MaxLockoutDurationOrDefault())
+}
+
+// LockedUntil returns the time until which the key is locked, and whether it is currently locked.
+func (t *LoginThrottler) LockedUntil(key string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	attempts, ok := t.attempts[key]
+	if !ok || !time.Now().Before(attempts.lockedUntil) {
+		return time.Time{}, false
+	}
+	return attempts.lockedUntil, true
+}
+
+// Fail records a failed attempt for the key and returns the time until which it is locked, zero if it isn't.
+func (t *LoginThrottler) Fail(key string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.cleanup(now)
+
+	attempts, ok := t.attempts[key]
+	if !ok {
+		attempts = &failedAttempts{}
+		t.attempts[key] = attempts
+	}
+
+	attempts.count++
+	attempts.lastFailure = now
+	if duration := LockoutDuration(attempts.count, t.maxAttempts, t.base, t.max); duration > 0 {
+		attempts.lockedUntil = now.Add(duration)
+	}
+	return attempts.lockedUntil
+}
+
+// Reset forgets the failed attempts of the key.
+func (t *LoginThrottler) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.attempts, key)
+}
+
+// cleanup drops keys that are not locked and didn't fail for longer than the max lockout duration.
+func (t *LoginThrottler) cleanup(now time.Time) {
+	for key, attempts := range t.attempts {
+		if now.After(attempts.lockedUntil) && now.Sub(attempts.lastFailure) > t.max {
+			delete(t.attempts, key)
+		}
+	}
+}
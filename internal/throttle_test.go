@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the exponential backoff of the lockout duration.
+func TestLockoutDuration(t *testing.T) {
+	base := time.Minute
+	max := 10 * time.Minute
+
+	assert.Equal(t, time.Duration(0), LockoutDuration(4, 5, base, max))
+	assert.Equal(t, time.Minute, LockoutDuration(5, 5, base, max))
+	assert.Equal(t, 2*time.Minute, LockoutDuration(6, 5, base, max))
+	assert.Equal(t, 8*time.Minute, LockoutDuration(8, 5, base, max))
+	assert.Equal(t, max, LockoutDuration(9, 5, base, max))
+	assert.Equal(t, max, LockoutDuration(100, 5, base, max))
+}
+
+// Test locking and resetting keys in the login throttler.
+func TestLoginThrottler(t *testing.T) {
+	throttler := NewLoginThrottler(3, time.Minute, time.Hour)
+	ip := "10.0.0.1"
+
+	t.Run("not locked under the max attempts", func(t *testing.T) {
+		assert.True(t, throttler.Fail(ip).IsZero())
+		assert.True(t, throttler.Fail(ip).IsZero())
+
+		_, locked := throttler.LockedUntil(ip)
+		assert.False(t, locked)
+	})
+
+	t.Run("locked at the max attempts", func(t *testing.T) {
+		lockedUntil := throttler.Fail(ip)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), lockedUntil, time.Second)
+
+		until, locked := throttler.LockedUntil(ip)
+		assert.True(t, locked)
+		assert.Equal(t, lockedUntil, until)
+	})
+
+	t.Run("other keys are not locked", func(t *testing.T) {
+		_, locked := throttler.LockedUntil("10.0.0.2")
+		assert.False(t, locked)
+	})
+
+	t.Run("reset unlocks the key", func(t *testing.T) {
+		throttler.Reset(ip)
+		_, locked := throttler.LockedUntil(ip)
+		assert.False(t, locked)
+	})
+}
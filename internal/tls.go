@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// certificateCheckInterval is the minimum interval between two checks of the certificate files.
+const certificateCheckInterval = 5 * time.Second
+
+// tlsVersions are the supported minimum TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Enabled returns true if the API is served over TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// MinVersionOrDefault returns the minimum TLS version.
+func (c TLSConfig) MinVersionOrDefault() uint16 {
+	if version, ok := tlsVersions[c.MinVersion]; ok {
+		return version
+	}
+	return tls.VersionTLS12
+}
+
+// ClientIdentity returns the identity of a verified client certificate, matched by its common name, DNS names or URIs.
+func (c TLSConfig) ClientIdentity(cert *x509.Certificate) (ClientIdentityConfig, bool) {
+	subjects := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+
+	for _, identity := range c.ClientIdentities {
+		for _, subject := range subjects {
+			if subject != "" && subject == identity.Subject {
+				return identity, true
+			}
+		}
+	}
+	return ClientIdentityConfig{}, false
+}
+
+func (c TLSConfig) validate() error {
+	if c.CertFile == "" && c.KeyFile != "" {
+		return missingKeyError("server tls cert_file")
+	}
+	if c.CertFile != "" && c.KeyFile == "" {
+		return missingKeyError("server tls key_file")
+	}
+	if c.MinVersion != "" {
+		if _, ok := tlsVersions[c.MinVersion]; !ok {
+			return invalidKeyError("server tls min_version", c.MinVersion)
+		}
+	}
+	if c.ClientCAFile != "" && !c.Enabled() {
+		return missingKeyError("server tls cert_file")
+	}
+	if (c.RequireClientCert || len(c.ClientIdentities) > 0) && c.ClientCAFile == "" {
+		return missingKeyError("server tls client_ca_file")
+	}
+
+	subjects := map[string]bool{}
+	for _, identity := range c.ClientIdentities {
+		if strings.TrimSpace(identity.Name) == "" {
+			return missingKeyError("server tls client_identities name")
+		}
+		if strings.TrimSpace(identity.Subject) == "" {
+			return missingKeyError(fmt.Sprintf("server tls client_identities %s subject", identity.Name))
+		}
+		if strings.TrimSpace(identity.UserEmail) == "" {
+			return missingKeyError(fmt.Sprintf("server tls client_identities %s user_email", identity.Name))
+		}
+		if subjects[identity.Subject] {
+			return invalidKeyError("server tls client_identities subject", identity.Subject+" (duplicated)")
+		}
+		subjects[identity.Subject] = true
+	}
+	return nil
+}
+
+// NewTLSConfig creates the TLS config of the API servers, it returns nil if TLS isn't enabled.
+// The certificate is reloaded when its files change, the client authorities are only loaded once.
+func NewTLSConfig(config TLSConfig) (*tls.Config, error) {
+	if !config.Enabled() {
+		return nil, nil
+	}
+
+	reloader, err := NewCertificateReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     config.MinVersionOrDefault(),
+		GetCertificate: reloader.GetCertificate,
+		// gRPC needs HTTP/2 to be negotiated.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if config.ClientCAFile != "" {
+		content, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no certificate found in %s", config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if config.RequireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return tlsConfig, nil
+}
+
+// CertificateReloader serves a certificate and reloads it when its files change, e.g. after a renewal.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	modTimes    [2]time.Time
+	lastChecked time.Time
+}
+
+// NewCertificateReloader loads the certificate, it fails if the files are missing or invalid.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, it's used as tls.Config.GetCertificate.
+// The files are checked at most every few seconds, an invalid new certificate is logged and the previous one is kept.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastChecked) >= certificateCheckInterval {
+		if err := r.reload(now); err != nil {
+			log.Error().Err(err).Str("cert_file", r.certFile).Msg("Failed to reload the TLS certificate, keeping the previous one")
+		}
+	}
+	return r.cert, nil
+}
+
+// reload loads the certificate if its files changed since the last load.
+func (r *CertificateReloader) reload(now time.Time) error {
+	r.lastChecked = now
+
+	var modTimes [2]time.Time
+	for i, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.cert != nil && modTimes == r.modTimes {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil {
+		log.Info().Str("cert_file", r.certFile).Msg("TLS certificate reloaded")
+	}
+	r.cert = &cert
+	r.modTimes = modTimes
+	return nil
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestCertificate writes a certificate and its key signed by the parent, or self-signed if parent is nil.
+func writeTestCertificate(t *testing.T, dir, name string, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return cert, key
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeTestCertificate(t, dir, "ca", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "envserver test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	writeTestCertificate(t, dir, "server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+
+	config := TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		MinVersion:   "1.3",
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(TLSConfig{})
+		assert.NoError(t, err)
+		assert.Nil(t, tlsConfig)
+	})
+
+	t.Run("server and client authorities", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(config)
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+		assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+
+		cert, err := tlsConfig.GetCertificate(nil)
+		assert.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "localhost", leaf.Subject.CommonName)
+
+		required := config
+		required.RequireClientCert = true
+		tlsConfig, err = NewTLSConfig(required)
+		assert.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	})
+
+	t.Run("reload on file change", func(t *testing.T) {
+		reloader, err := NewCertificateReloader(config.CertFile, config.KeyFile)
+		assert.NoError(t, err)
+
+		writeTestCertificate(t, dir, "server", &x509.Certificate{Subject: pkix.Name{CommonName: "renewed"}}, ca, caKey)
+		later := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(config.CertFile, later, later))
+		reloader.lastChecked = time.Time{}
+
+		cert, err := reloader.GetCertificate(nil)
+		assert.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "renewed", leaf.Subject.CommonName)
+	})
+
+	t.Run("keep the certificate if the new one is invalid", func(t *testing.T) {
+		reloader, err := NewCertificateReloader(config.CertFile, config.KeyFile)
+		assert.NoError(t, err)
+
+		assert.NoError(t, os.WriteFile(config.CertFile, []byte("not a certificate"), 0600))
+		reloader.lastChecked = time.Time{}
+
+		cert, err := reloader.GetCertificate(nil)
+		assert.NoError(t, err)
+		assert.NotNil(t, cert)
+	})
+
+	t.Run("client identities", func(t *testing.T) {
+		spiffe, _ := url.Parse("spiffe://example.com/deployer")
+		identities := TLSConfig{ClientIdentities: []ClientIdentityConfig{
+			{Name: "ci", Subject: "ci.example.com", UserEmail: "ci@example.com"},
+			{Name: "deployer", Subject: "spiffe://example.com/deployer", UserEmail: "deployer@example.com"},
+		}}
+
+		identity, ok := identities.ClientIdentity(&x509.Certificate{Subject: pkix.Name{CommonName: "ci.example.com"}})
+		assert.True(t, ok)
+		assert.Equal(t, "ci", identity.Name)
+
+		identity, ok = identities.ClientIdentity(&x509.Certificate{URIs: []*url.URL{spiffe}})
+		assert.True(t, ok)
+		assert.Equal(t, "deployer@example.com", identity.UserEmail)
+
+		_, ok = identities.ClientIdentity(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}})
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid configs", func(t *testing.T) {
+		invalid := []TLSConfig{
+			{KeyFile: "server.key"},
+			{CertFile: "server.crt"},
+			{CertFile: "server.crt", KeyFile: "server.key", MinVersion: "1.0"},
+			{ClientCAFile: "ca.crt"},
+			{CertFile: "server.crt", KeyFile: "server.key", RequireClientCert: true},
+			{CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: "ca.crt", ClientIdentities: []ClientIdentityConfig{{Name: "ci", Subject: "ci"}}},
+		}
+		for _, config := range invalid {
+			assert.Error(t, config.validate())
+		}
+	})
+}
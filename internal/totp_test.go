@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 SHA1 test secret "12345678901234567890".
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// Test generated codes against the RFC 6238 test vectors (truncated to 6 digits).
+func TestGenerateTOTPCode(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := GenerateTOTPCode(rfcSecret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		_, err := GenerateTOTPCode("not base32!", time.Now())
+		assert.ErrorIs(t, err, InvalidTOTPSecretError)
+	})
+}
+
+// Test code validation with clock skew.
+func TestValidateTOTPCode(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	code, err := GenerateTOTPCode(secret, now)
+	assert.NoError(t, err)
+
+	step := now.Unix() / TOTPPeriod
+
+	t.Run("current code", func(t *testing.T) {
+		matched, ok := ValidateTOTPCode(secret, code, now, 0)
+		assert.True(t, ok)
+		assert.Equal(t, step, matched)
+	})
+
+	t.Run("previous time step", func(t *testing.T) {
+		matched, ok := ValidateTOTPCode(secret, code, now.Add(TOTPPeriod*time.Second), 0)
+		assert.True(t, ok)
+		assert.Equal(t, step, matched)
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		_, ok := ValidateTOTPCode(secret, code, now.Add(3*TOTPPeriod*time.Second), 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("wrong code length", func(t *testing.T) {
+		_, ok := ValidateTOTPCode(secret, code+"1", now, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("replayed code", func(t *testing.T) {
+		_, ok := ValidateTOTPCode(secret, code, now, step)
+		assert.False(t, ok)
+		_, ok = ValidateTOTPCode(secret, code, now, step+1)
+		assert.False(t, ok)
+	})
+}
+
+// Test the otpauth URI and recovery codes format.
+func TestTOTPURIAndRecoveryCodes(t *testing.T) {
+	uri := TOTPURI("envserver", "omda@gmail.com", rfcSecret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/envserver:omda@gmail.com?"))
+	assert.Contains(t, uri, "secret="+rfcSecret)
+	assert.Contains(t, uri, "issuer=envserver")
+
+	codes, err := GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	for _, code := range codes {
+		assert.Len(t, code, 11)
+	}
+}
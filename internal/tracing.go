@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	// TracerName is the name of the tracer of the envserver spans.
+	TracerName = "github.com/Mahmoud-Emad/envserver"
+	// DefaultTracingServiceName is the service name of the exported spans if it's not configured.
+	DefaultTracingServiceName = "envserver"
+	// tracingSpanKey is the gorm instance key holding the span of a traced query.
+	tracingSpanKey = "envserver:tracing_span"
+	// databaseMethodPrefix prefixes the names of the Database methods in the stack frames.
+	databaseMethodPrefix = "github.com/Mahmoud-Emad/envserver/internal.(*Database)."
+)
+
+// Span attributes identifying the user and the project of a request, values are never recorded.
+const (
+	UserIDAttribute    = attribute.Key("envserver.user.id")
+	ProjectIDAttribute = attribute.Key("envserver.project.id")
+)
+
+// Tracer returns the tracer of the envserver spans, they are exported by the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// SetupTracing exports the spans to the configured OTLP/HTTP endpoint and returns a function flushing and stopping the exporter.
+// Nothing is exported if no endpoint is configured.
+func SetupTracing(ctx context.Context, config TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if config.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(config.URLPath))
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(config.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewTracerProvider(sdktrace.NewBatchSpanProcessor(exporter), config)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider creates a tracer provider sending the spans to the processor, e.g. a syncer of an in-memory exporter in tests.
+func NewTracerProvider(processor sdktrace.SpanProcessor, config TracingConfig) *sdktrace.TracerProvider {
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultTracingServiceName
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.SampleRatio > 0 && config.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(config.SampleRatio)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+}
+
+// WithContext returns a copy of the database running its queries with the context, so that their spans are children of the context span.
+func (d *Database) WithContext(ctx context.Context) *Database {
+	return &Database{db: d.db.WithContext(ctx)}
+}
+
+// Trace records a span for every query, named after the Database method running it.
+// The spans hold the SQL statement with placeholders, never the values.
+func (d *Database) Trace() error {
+	start := func(db *gorm.DB) {
+		ctx, span := Tracer().Start(db.Statement.Context, databaseMethod(), trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+		db.InstanceSet(tracingSpanKey, span)
+	}
+	end := func(operation string) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			value, ok := db.InstanceGet(tracingSpanKey)
+			if !ok {
+				return
+			}
+			span := value.(trace.Span)
+			span.SetAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperation(operation),
+				semconv.DBSQLTable(db.Statement.Table),
+				semconv.DBStatement(db.Statement.SQL.String()),
+			)
+			if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+				span.RecordError(db.Error)
+				span.SetStatus(codes.Error, db.Error.Error())
+			}
+			span.End()
+		}
+	}
+
+	callbacks := d.db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", start),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", end("create")),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", start),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", end("query")),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", start),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", end("update")),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", start),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", end("delete")),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", start),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", end("row")),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", start),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", end("raw")),
+	)
+}
+
+// databaseMethod returns the span name of a query, e.g. "Database.GetProjectByID", from the Database method in the call stack.
+func databaseMethod() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if method, found := strings.CutPrefix(frame.Function, databaseMethodPrefix); found {
+			// Queries in transactions run in closures, e.g. "AppendAuditEvent.func1".
+			method, _, _ = strings.Cut(method, ".")
+			return "Database." + method
+		}
+		if !more {
+			return "Database.query"
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), TracingConfig{})
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	// Queries aren't sent in dry run mode, so no database is needed.
+	conn, err := sql.Open("pgx", "host=localhost")
+	assert.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.NoError(t, err)
+
+	d := &Database{db: db}
+	assert.NoError(t, d.Trace())
+
+	t.Run("database method spans", func(t *testing.T) {
+		exporter.Reset()
+		ctx, parent := Tracer().Start(context.Background(), "GET /api/v1/projects/{id}")
+		_, _ = d.WithContext(ctx).GetProjectByID(7)
+		parent.End()
+
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 2)
+		query := spans[0]
+		assert.Equal(t, "Database.GetProjectByID", query.Name)
+		assert.Equal(t, parent.SpanContext().SpanID(), query.Parent.SpanID())
+		assert.Equal(t, parent.SpanContext().TraceID(), query.SpanContext.TraceID())
+
+		attributes := attribute.NewSet(query.Attributes...)
+		table, _ := attributes.Value("db.sql.table")
+		assert.Equal(t, "projects", table.AsString())
+		statement, _ := attributes.Value("db.statement")
+		assert.Contains(t, statement.AsString(), "$1")
+		assert.NotContains(t, statement.AsString(), "7")
+	})
+
+	t.Run("queries without a trace", func(t *testing.T) {
+		exporter.Reset()
+		_, _ = d.GetProjectByID(7)
+
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 1)
+		assert.False(t, spans[0].Parent.IsValid())
+	})
+}
@@ -2,8 +2,11 @@ package internal
 
 import (
 	"fmt"
+	"net/url"
 	"reflect"
 
+	models "github.com/Mahmoud-Emad/envserver/models"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -42,11 +45,107 @@ func (e *EnvironmentKeyInputs) Validate() error {
 	return ValidateFields(e)
 }
 
+// Validate checks the operation name and the presence of the key, and of the value of set operations.
+func (o *EnvOperationInputs) Validate() error {
+	switch o.Op {
+	case EnvSetOperation:
+		return (&EnvironmentKeyInputs{Key: o.Key, Value: o.Value}).Validate()
+	case EnvDeleteOperation:
+		if o.Key == "" {
+			return fmt.Errorf("Key field is required")
+		}
+		return nil
+	}
+	return fmt.Errorf("%w %q", InvalidEnvOperationError, o.Op)
+}
+
+// Validate checks the size of the batch and every operation, it returns the error of each operation, nil for valid ones.
+// A key can't be changed by two operations of the same batch.
+func (b *EnvBatchInputs) Validate(maxSize int) ([]error, error) {
+	if len(b.Operations) == 0 {
+		return nil, fmt.Errorf("Operations field is required")
+	}
+	if len(b.Operations) > maxSize {
+		return nil, fmt.Errorf("%w of %d operations", EnvBatchTooLargeError, maxSize)
+	}
+
+	var err error
+	errs := make([]error, len(b.Operations))
+	seen := map[string]bool{}
+	for i := range b.Operations {
+		operation := &b.Operations[i]
+		errs[i] = operation.Validate()
+		if errs[i] == nil && seen[operation.Key] {
+			errs[i] = fmt.Errorf("%w %q", DuplicateEnvOperationError, operation.Key)
+		}
+		seen[operation.Key] = true
+
+		if errs[i] != nil && err == nil {
+			err = fmt.Errorf("%w, operation %d: %v", InvalidEnvBatchError, i, errs[i])
+		}
+	}
+	return errs, err
+}
+
 // ValidateProjectFields checks for the presence of required fields in the project struct.
 func (p *ProjectInputs) Validate() error {
 	return ValidateFields(p)
 }
 
+// Validate checks for the presence of required fields in the mfa code inputs struct.
+func (m *MFACodeInputs) Validate() error {
+	return ValidateFields(m)
+}
+
+// Validate checks for the presence of required fields in the mfa signin inputs struct.
+func (m *MFASigninInputs) Validate() error {
+	return ValidateFields(m)
+}
+
+// Validate checks for the presence of required fields in the email inputs struct.
+func (e *EmailInputs) Validate() error {
+	return ValidateFields(e)
+}
+
+// Validate checks for the presence of required fields in the password reset inputs struct.
+func (p *PasswordResetInputs) Validate() error {
+	return ValidateFields(p)
+}
+
+// Validate checks for the presence of required fields in the token inputs struct.
+func (t *TokenInputs) Validate() error {
+	return ValidateFields(t)
+}
+
+// Validate checks the url and the events of the webhook inputs struct.
+func (w *WebhookInputs) Validate() error {
+	parsed, err := url.Parse(w.URL)
+	if err != nil || !parsed.IsAbs() || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return InvalidWebhookURLError
+	}
+	if err := validateWebhookHost(parsed.Hostname()); err != nil {
+		return err
+	}
+
+	if len(w.Events) == 0 {
+		return fmt.Errorf("Events field is required")
+	}
+
+	for _, event := range w.Events {
+		known := false
+		for _, e := range models.WebhookEvents {
+			if e == event {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w %q", InvalidWebhookEventError, event)
+		}
+	}
+	return nil
+}
+
 // HashPassword hashes the given plain-text password using bcrypt.
 // It returns the hashed password or an error if hashing fails.
 func HashPassword(password string) ([]byte, error) {
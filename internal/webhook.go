@@ -0,0 +1,416 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultWebhookMaxAttempts is the number of attempts before a delivery is marked as failed.
+	DefaultWebhookMaxAttempts = 8
+	// DefaultWebhookTimeout is the timeout of a webhook call.
+	DefaultWebhookTimeout = 10 * time.Second
+	// DefaultWebhookPollInterval is the interval between two checks of the delivery queue.
+	DefaultWebhookPollInterval = 5 * time.Second
+
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = time.Hour
+	// webhookBatchSize is the number of deliveries claimed at once.
+	webhookBatchSize = 20
+	// webhookClaimLease delays the next claim of a delivery while it's being sent.
+	webhookClaimLease = 5 * time.Minute
+	// webhookMaxRedirects is the number of redirects followed by a webhook call.
+	webhookMaxRedirects = 5
+	// webhookResolveTimeout is the timeout of the resolution of the webhook host when a webhook is created.
+	webhookResolveTimeout = 5 * time.Second
+
+	// Headers sent with every webhook call.
+	WebhookSignatureHeader = "X-Envserver-Signature"
+	WebhookTimestampHeader = "X-Envserver-Timestamp"
+	WebhookEventHeader     = "X-Envserver-Event"
+	WebhookDeliveryHeader  = "X-Envserver-Delivery"
+)
+
+// nonPublicNetworks are the reserved networks not covered by the net.IP methods, e.g. the shared address space of the carrier-grade NATs.
+var nonPublicNetworks = parseNetworks("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4")
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isPublicIP returns false for the addresses a webhook must never reach: loopback, private, link-local
+// (e.g. the cloud metadata endpoint 169.254.169.254), multicast, unspecified and reserved addresses.
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateWebhookHost rejects the webhook hosts that are, or resolve to, a non public address.
+// Hosts that can't be resolved yet are accepted, every call checks the address it connects to anyway.
+func validateWebhookHost(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return PrivateWebhookURLError
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookResolveTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, address := range addresses {
+		if !isPublicIP(address.IP) {
+			return PrivateWebhookURLError
+		}
+	}
+	return nil
+}
+
+// newWebhookClient returns the client calling the webhooks. It refuses to connect to a non public address once the host
+// is resolved, so that a webhook can't reach the internal services, even through a redirect or a DNS change.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", PrivateWebhookURLError, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be the only checked address.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= webhookMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", webhookMaxRedirects)
+			}
+			if request.URL.Scheme != "http" && request.URL.Scheme != "https" {
+				return InvalidWebhookURLError
+			}
+			return validateWebhookHost(request.URL.Hostname())
+		},
+	}
+}
+
+// SignWebhookPayload returns the HMAC-SHA256 signature of the timestamp and body, as sent in the signature header.
+// Receivers should recompute it over "<timestamp>.<body>" and reject old timestamps to prevent replays.
+func SignWebhookPayload(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookBackoff returns the delay before the next attempt after the given number of failed attempts.
+func WebhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// CreateWebhook stores a new webhook.
+func (d *Database) CreateWebhook(webhook *models.Webhook) error {
+	return d.db.Create(webhook).Error
+}
+
+// GetProjectWebhooks returns the webhooks of a project.
+func (d *Database) GetProjectWebhooks(projectID int) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	result := d.db.Where("project_id = ?", projectID).Order("id").Find(&webhooks)
+	return webhooks, result.Error
+}
+
+// GetWebhookByID returns a webhook by its id.
+func (d *Database) GetWebhookByID(id int) (models.Webhook, error) {
+	var webhook models.Webhook
+	result := d.db.First(&webhook, "id = ?", id)
+	return webhook, result.Error
+}
+
+// DeleteWebhookByID deletes a webhook and its deliveries.
+func (d *Database) DeleteWebhookByID(id int) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id = ?", id).Delete(&models.Webhook{}).Error
+	})
+}
+
+// CreateWebhookDelivery queues a webhook delivery.
+func (d *Database) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return d.db.Create(delivery).Error
+}
+
+// GetWebhookDeliveries returns the latest deliveries of a webhook.
+func (d *Database) GetWebhookDeliveries(webhookID int, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	result := d.db.Where("webhook_id = ?", webhookID).Order("id desc").Limit(limit).Find(&deliveries)
+	return deliveries, result.Error
+}
+
+// GetWebhookDeliveryByID returns a webhook delivery by its id.
+func (d *Database) GetWebhookDeliveryByID(id int) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	result := d.db.First(&delivery, "id = ?", id)
+	return delivery, result.Error
+}
+
+// ClaimDueDeliveries returns the pending deliveries whose next attempt is due, and delays them by a lease
+// so that they are not claimed again while being sent. Deliveries claimed by another server are skipped.
+func (d *Database) ClaimDueDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at").Limit(limit).Find(&deliveries)
+		if result.Error != nil || len(deliveries) == 0 {
+			return result.Error
+		}
+
+		ids := make([]int, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return deliveries, err
+}
+
+// UpdateWebhookDelivery saves the result of a delivery attempt.
+func (d *Database) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return d.db.Save(delivery).Error
+}
+
+// WebhookDispatcher sends the queued webhook deliveries in the background and retries failed ones with exponential backoff.
+type WebhookDispatcher struct {
+	db        Database
+	client    *http.Client
+	secretKey string
+	// legacyKeys decrypt the secrets created before they were encrypted with secretKey.
+	legacyKeys []string
+	stop       chan struct{}
+	done       chan struct{}
+
+	mu           sync.Mutex
+	timeout      time.Duration
+	maxAttempts  int
+	pollInterval time.Duration
+}
+
+// NewWebhookDispatcher creates a dispatcher, secretKey decrypts the webhook secrets.
+// The secrets it can't decrypt are tried with the legacyKeys, e.g. the JWT secret the older versions encrypted them with.
+func NewWebhookDispatcher(db Database, config WebhookConfig, secretKey string, legacyKeys ...string) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		db:         db,
+		client:     newWebhookClient(),
+		secretKey:  secretKey,
+		legacyKeys: legacyKeys,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	d.Configure(config)
+	return d
+}
+
+// Configure applies the webhook settings, using defaults for unset values, e.g. when the config is reloaded.
+// A new poll interval is applied after the next poll.
+func (d *WebhookDispatcher) Configure(config WebhookConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.timeout = DefaultWebhookTimeout
+	if config.Timeout > 0 {
+		d.timeout = time.Duration(config.Timeout) * time.Second
+	}
+	d.pollInterval = DefaultWebhookPollInterval
+	if config.PollInterval > 0 {
+		d.pollInterval = time.Duration(config.PollInterval) * time.Second
+	}
+	d.maxAttempts = DefaultWebhookMaxAttempts
+	if config.MaxAttempts > 0 {
+		d.maxAttempts = config.MaxAttempts
+	}
+}
+
+// settings returns the current timeout, maximum attempts and poll interval.
+func (d *WebhookDispatcher) settings() (time.Duration, int, time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.timeout, d.maxAttempts, d.pollInterval
+}
+
+// Start polls the delivery queue until Stop is called.
+func (d *WebhookDispatcher) Start() {
+	go func() {
+		defer close(d.done)
+		_, _, pollInterval := d.settings()
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.dispatchDue()
+				if _, _, interval := d.settings(); interval != pollInterval {
+					pollInterval = interval
+					ticker.Reset(pollInterval)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for the in-flight deliveries.
+func (d *WebhookDispatcher) Stop() {
+	close(d.stop)
+	<-d.done
+}
+
+func (d *WebhookDispatcher) dispatchDue() {
+	deliveries, err := d.db.ClaimDueDeliveries(webhookBatchSize, webhookClaimLease)
+	if err != nil {
+		log.Error().Msgf("Failed to claim webhook deliveries: %v", err)
+		return
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		webhook, err := d.db.GetWebhookByID(delivery.WebhookID)
+		if err != nil {
+			delivery.Status = models.DeliveryFailed
+			delivery.LastError = "webhook not found"
+		} else {
+			d.Deliver(webhook, delivery)
+		}
+
+		if err := d.db.UpdateWebhookDelivery(delivery); err != nil {
+			log.Error().Msgf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+		}
+	}
+}
+
+// Deliver sends the delivery payload to the webhook and updates the delivery with the result of the attempt.
+func (d *WebhookDispatcher) Deliver(webhook models.Webhook, delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	statusCode, err := d.send(webhook, delivery)
+	delivery.LastStatusCode = statusCode
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if _, maxAttempts, _ := d.settings(); delivery.Attempts >= maxAttempts {
+		delivery.Status = models.DeliveryFailed
+		return
+	}
+	delivery.Status = models.DeliveryPending
+	delivery.NextAttemptAt = time.Now().Add(WebhookBackoff(delivery.Attempts))
+}
+
+// decryptSecret decrypts a webhook secret with the secret key, then with the legacy keys.
+func (d *WebhookDispatcher) decryptSecret(encrypted []byte) ([]byte, error) {
+	secret, err := DecryptAES(encrypted, d.secretKey)
+	if err == nil {
+		return secret, nil
+	}
+	for _, key := range d.legacyKeys {
+		if secret, legacyErr := DecryptAES(encrypted, key); legacyErr == nil {
+			return secret, nil
+		}
+	}
+	return nil, err
+}
+
+func (d *WebhookDispatcher) send(webhook models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	secret, err := d.decryptSecret(webhook.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("cannot decrypt webhook secret: %w", err)
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	timeout, _, _ := d.settings()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "envserver-webhooks")
+	request.Header.Set(WebhookEventHeader, delivery.Event)
+	request.Header.Set(WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+	request.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	// The response body isn't stored, it could leak the content of the called service.
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	models "github.com/Mahmoud-Emad/envserver/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the exponential backoff between delivery attempts.
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, WebhookBackoff(1))
+	assert.Equal(t, time.Minute, WebhookBackoff(2))
+	assert.Equal(t, 4*time.Minute, WebhookBackoff(4))
+	assert.Equal(t, time.Hour, WebhookBackoff(20))
+}
+
+// Test validating the webhook inputs.
+func TestWebhookInputsValidate(t *testing.T) {
+	valid := WebhookInputs{URL: "https://deploy.example.com/hooks", Events: []string{models.EnvUpdatedEvent}}
+	assert.NoError(t, valid.Validate())
+
+	invalidURL := WebhookInputs{URL: "ftp://deploy.example.com", Events: []string{models.EnvUpdatedEvent}}
+	assert.ErrorIs(t, invalidURL.Validate(), InvalidWebhookURLError)
+
+	noEvents := WebhookInputs{URL: "https://deploy.example.com/hooks"}
+	assert.Error(t, noEvents.Validate())
+
+	unknownEvent := WebhookInputs{URL: "https://deploy.example.com/hooks", Events: []string{"env.read"}}
+	assert.ErrorIs(t, unknownEvent.Validate(), InvalidWebhookEventError)
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hooks",
+		"http://localhost/hooks",
+		"http://10.0.0.5/hooks",
+		"http://192.168.1.1/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hooks",
+		"http://[::1]/hooks",
+		"http://[fd00::1]/hooks",
+		"http://0.0.0.0/hooks",
+	} {
+		private := WebhookInputs{URL: url, Events: []string{models.EnvUpdatedEvent}}
+		assert.ErrorIs(t, private.Validate(), PrivateWebhookURLError, url)
+	}
+}
+
+// Test the webhook client never connects to a non public address, even through a redirect.
+func TestWebhookClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := newWebhookClient()
+
+	_, err := client.Post(server.URL, "application/json", nil)
+	assert.ErrorIs(t, err, PrivateWebhookURLError)
+
+	redirect, err := http.NewRequest(http.MethodPost, "http://169.254.169.254/latest/meta-data", nil)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, client.CheckRedirect(redirect, []*http.Request{{}}), PrivateWebhookURLError)
+
+	assert.True(t, isPublicIP(net.ParseIP("93.184.216.34")))
+	assert.True(t, isPublicIP(net.ParseIP("2606:2800:220:1::")))
+	assert.False(t, isPublicIP(net.ParseIP("::ffff:127.0.0.1")))
+}
+
+// Test delivering a signed payload and retrying failed deliveries.
+func TestWebhookDispatcherDeliver(t *testing.T) {
+	secretKey := "xyz"
+	secret := []byte("webhook-secret")
+	encryptedSecret, err := EncryptAES(secret, secretKey)
+	assert.NoError(t, err)
+
+	status := http.StatusOK
+	var received *http.Request
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	dispatcher := NewWebhookDispatcher(Database{}, WebhookConfig{MaxAttempts: 2}, secretKey)
+	// The test server listens on the loopback address, refused by the webhook client.
+	dispatcher.client = server.Client()
+	webhook := models.Webhook{ID: 1, URL: server.URL, Events: models.EnvUpdatedEvent, Secret: encryptedSecret}
+	payload := `{"event":"env.updated","project_id":2,"key":"DATABASE_URL"}`
+
+	t.Run("signed delivery", func(t *testing.T) {
+		delivery := &models.WebhookDelivery{ID: 5, Event: models.EnvUpdatedEvent, Payload: payload, Status: models.DeliveryPending}
+		dispatcher.Deliver(webhook, delivery)
+
+		assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.NotNil(t, delivery.DeliveredAt)
+		assert.Equal(t, payload, string(receivedBody))
+		assert.Equal(t, models.EnvUpdatedEvent, received.Header.Get(WebhookEventHeader))
+		assert.Equal(t, "5", received.Header.Get(WebhookDeliveryHeader))
+
+		timestamp, err := strconv.ParseInt(received.Header.Get(WebhookTimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, SignWebhookPayload(secret, timestamp, receivedBody), received.Header.Get(WebhookSignatureHeader))
+	})
+
+	t.Run("failed delivery is retried then marked as failed", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		delivery := &models.WebhookDelivery{ID: 6, Event: models.EnvUpdatedEvent, Payload: payload, Status: models.DeliveryPending}
+
+		dispatcher.Deliver(webhook, delivery)
+		assert.Equal(t, models.DeliveryPending, delivery.Status)
+		assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+		assert.WithinDuration(t, time.Now().Add(30*time.Second), delivery.NextAttemptAt, time.Second)
+
+		dispatcher.Deliver(webhook, delivery)
+		assert.Equal(t, models.DeliveryFailed, delivery.Status)
+		assert.Equal(t, 2, delivery.Attempts)
+		assert.Equal(t, "unexpected status 500", delivery.LastError)
+	})
+}
+
+// Test the secrets encrypted with a legacy key are still decrypted, and only with the known keys.
+func TestWebhookDispatcherDecryptSecret(t *testing.T) {
+	dispatcher := NewWebhookDispatcher(Database{}, WebhookConfig{}, "encryption-key", "jwt-secret")
+
+	encryptedSecret, err := EncryptAES([]byte("webhook-secret"), "encryption-key")
+	assert.NoError(t, err)
+	secret, err := dispatcher.decryptSecret(encryptedSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "webhook-secret", string(secret))
+
+	legacySecret, err := EncryptAES([]byte("legacy-secret"), "jwt-secret")
+	assert.NoError(t, err)
+	secret, err = dispatcher.decryptSecret(legacySecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy-secret", string(secret))
+
+	unknownSecret, err := EncryptAES([]byte("other-secret"), "other-key")
+	assert.NoError(t, err)
+	_, err = dispatcher.decryptSecret(unknownSecret)
+	assert.Error(t, err)
+}
@@ -0,0 +1,178 @@
+// Package envserver loads the env keys of an envserver project into a Go config struct.
+//
+// Struct fields are tagged with the name of their key and an optional default value:
+//
+//	type Config struct {
+//		DatabaseURL string        `env:"DATABASE_URL,required"`
+//		Workers     int           `env:"WORKERS" default:"4"`
+//		Timeout     time.Duration `env:"TIMEOUT" default:"30s"`
+//	}
+//
+//	var config Config
+//	err := envserver.Load(ctx, "backend", "production", &config)
+package envserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Mahmoud-Emad/envserver/client"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// URLEnv is the environment variable holding the envserver url used by Load.
+	URLEnv = "ENVSERVER_URL"
+	// TokenEnv is the environment variable holding the access token used by Load.
+	TokenEnv = "ENVSERVER_TOKEN"
+	// CacheDirEnv is the environment variable holding the cache directory used by Load, the cache is disabled if it's empty.
+	CacheDirEnv = "ENVSERVER_CACHE_DIR"
+	// CacheKeyEnv is the environment variable holding the key encrypting the cache used by Load.
+	CacheKeyEnv = "ENVSERVER_CACHE_KEY"
+)
+
+var (
+	// ErrProjectNotFound is returned when no project has the requested name and environment.
+	ErrProjectNotFound = errors.New("envserver: project not found")
+	// ErrMissingURL is returned by Load when the ENVSERVER_URL environment variable isn't set.
+	ErrMissingURL = errors.New("envserver: " + URLEnv + " is not set")
+	// ErrMissingCacheKey is returned by Load when ENVSERVER_CACHE_DIR is set without ENVSERVER_CACHE_KEY.
+	ErrMissingCacheKey = errors.New("envserver: " + CacheKeyEnv + " is not set, it's required by " + CacheDirEnv)
+)
+
+// Loader fetches the env keys of envserver projects.
+// If a cache is configured, the fetched keys are saved encrypted and used when the server is unreachable.
+type Loader struct {
+	client *client.Client
+	cache  *cache
+}
+
+// Option configures a Loader.
+type Option func(*Loader)
+
+// WithCache saves the fetched keys in the directory, encrypted with the key.
+func WithCache(dir, key string) Option {
+	return func(l *Loader) {
+		l.cache = newCache(dir, key)
+	}
+}
+
+// NewLoader creates a loader fetching the keys with the client, it must be authenticated.
+func NewLoader(c *client.Client, opts ...Option) *Loader {
+	l := &Loader{client: c}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Load fetches the keys of the project environment into the config struct, configured from the environment variables:
+// ENVSERVER_URL, ENVSERVER_TOKEN and optionally ENVSERVER_CACHE_DIR and ENVSERVER_CACHE_KEY.
+func Load(ctx context.Context, project, env string, config interface{}) error {
+	loader, err := NewLoaderFromEnv()
+	if err != nil {
+		return err
+	}
+	return loader.Load(ctx, project, env, config)
+}
+
+// NewLoaderFromEnv creates a loader configured from the environment variables, see Load.
+func NewLoaderFromEnv() (*Loader, error) {
+	url := os.Getenv(URLEnv)
+	if url == "" {
+		return nil, ErrMissingURL
+	}
+
+	var opts []Option
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		key := os.Getenv(CacheKeyEnv)
+		if key == "" {
+			return nil, ErrMissingCacheKey
+		}
+		opts = append(opts, WithCache(dir, key))
+	}
+	return NewLoader(client.New(url, client.WithToken(os.Getenv(TokenEnv))), opts...), nil
+}
+
+// Load fetches the keys of the project environment into the config struct, see Populate.
+func (l *Loader) Load(ctx context.Context, project, env string, config interface{}) error {
+	snapshot, err := l.fetch(ctx, project, env)
+	if err != nil {
+		return err
+	}
+	return Populate(config, snapshot.Values)
+}
+
+// snapshot holds the keys of a project environment at a point in time.
+type snapshot struct {
+	ProjectID int               `json:"project_id"`
+	Values    map[string]string `json:"values"`
+}
+
+// fetch returns the keys of the project environment from the server, or from the cache if the server is unreachable.
+// Failing to save the cache doesn't fail the fetch, it's only logged.
+func (l *Loader) fetch(ctx context.Context, project, env string) (snapshot, error) {
+	fetched, err := l.fetchRemote(ctx, project, env)
+	if err == nil {
+		if l.cache != nil {
+			if err := l.cache.Save(project, env, fetched); err != nil {
+				log.Warn().Err(err).Str("project", project).Str("env", env).Msg("envserver: cannot save the cache")
+			}
+		}
+		return fetched, nil
+	}
+
+	if l.cache == nil || !isUnreachable(err) {
+		return snapshot{}, err
+	}
+
+	cached, cacheErr := l.cache.Load(project, env)
+	if cacheErr != nil {
+		return snapshot{}, fmt.Errorf("%w, and no cache is available: %v", err, cacheErr)
+	}
+	return cached, nil
+}
+
+func (l *Loader) fetchRemote(ctx context.Context, project, env string) (snapshot, error) {
+	projectID, err := l.findProject(ctx, project, env)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	keys, err := l.client.Env(ctx, projectID)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		values[key.Key] = string(key.Value)
+	}
+	return snapshot{ProjectID: projectID, Values: values}, nil
+}
+
+// findProject returns the id of the project with the name and environment name.
+func (l *Loader) findProject(ctx context.Context, project, env string) (int, error) {
+	projects, err := l.client.Projects(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range projects {
+		if p.Name == project && p.EnvironmentName == env {
+			return p.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s (%s)", ErrProjectNotFound, project, env)
+}
+
+// isUnreachable reports whether the error means that the server couldn't answer, rather than it refused the request.
+func isUnreachable(err error) bool {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		return errors.Is(err, client.ErrServer) || errors.Is(err, client.ErrTooManyRequests)
+	}
+	return !errors.Is(err, ErrProjectNotFound) && !errors.Is(err, context.Canceled)
+}
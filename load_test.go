@@ -0,0 +1,202 @@
+package envserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mahmoud-Emad/envserver/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type appConfig struct {
+	DatabaseURL string `env:"DATABASE_URL,required"`
+	Workers     int    `env:"WORKERS" default:"4"`
+}
+
+// fakeServer serves the endpoints used by the loader, like envserver.
+type fakeServer struct {
+	mu      sync.Mutex
+	values  map[string]string
+	events  chan string
+	failing bool
+}
+
+func (s *fakeServer) setValue(key, value string) {
+	s.mu.Lock()
+	s.values[key] = value
+	s.mu.Unlock()
+	s.events <- key
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	failing := s.failing
+	s.mu.Unlock()
+	if failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "token" {
+		writeData(w, http.StatusUnauthorized, nil)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/v1/projects":
+		writeData(w, http.StatusOK, []map[string]interface{}{
+			{"ID": 1, "name": "backend", "environment_name": "test"},
+			{"ID": 2, "name": "backend", "environment_name": "production"},
+		})
+	case "/api/v1/projects/2/env":
+		s.mu.Lock()
+		var env []map[string]interface{}
+		for key, value := range s.values {
+			env = append(env, map[string]interface{}{"project_id": 2, "Key": key, "Value": base64.StdEncoding.EncodeToString([]byte(value))})
+		}
+		s.mu.Unlock()
+		writeData(w, http.StatusOK, env)
+	case "/api/v1/projects/2/env/stream":
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for id := 1; ; id++ {
+			select {
+			case <-r.Context().Done():
+				return
+			case key := <-s.events:
+				fmt.Fprintf(w, ": heartbeat\n\nid: %d\nevent: env.updated\ndata: {\"id\":%d,\"project_id\":2,\"key\":%q,\"type\":\"env.updated\"}\n\n", id, id, key)
+				w.(http.Flusher).Flush()
+			}
+		}
+	default:
+		writeData(w, http.StatusNotFound, nil)
+	}
+}
+
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": http.StatusText(status), "status": status, "data": data})
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	fake := &fakeServer{values: map[string]string{"DATABASE_URL": "postgres://db"}, events: make(chan string)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func TestLoad(t *testing.T) {
+	fake, server := newFakeServer(t)
+	ctx := context.Background()
+
+	t.Run("load from the environment variables", func(t *testing.T) {
+		t.Setenv(URLEnv, server.URL)
+		t.Setenv(TokenEnv, "token")
+
+		var config appConfig
+		assert.NoError(t, Load(ctx, "backend", "production", &config))
+		assert.Equal(t, appConfig{DatabaseURL: "postgres://db", Workers: 4}, config)
+	})
+
+	t.Run("missing url", func(t *testing.T) {
+		t.Setenv(URLEnv, "")
+		assert.ErrorIs(t, Load(ctx, "backend", "production", &appConfig{}), ErrMissingURL)
+	})
+
+	t.Run("missing cache key", func(t *testing.T) {
+		t.Setenv(URLEnv, server.URL)
+		t.Setenv(CacheDirEnv, t.TempDir())
+		t.Setenv(CacheKeyEnv, "")
+		assert.ErrorIs(t, Load(ctx, "backend", "production", &appConfig{}), ErrMissingCacheKey)
+	})
+
+	t.Run("cache write errors don't fail the load", func(t *testing.T) {
+		// The cache directory can't be created under a file.
+		file := filepath.Join(t.TempDir(), "file")
+		assert.NoError(t, os.WriteFile(file, nil, 0600))
+		loader := NewLoader(client.New(server.URL, client.WithToken("token")), WithCache(filepath.Join(file, "cache"), "cache-key"))
+
+		var config appConfig
+		assert.NoError(t, loader.Load(ctx, "backend", "production", &config))
+		assert.Equal(t, "postgres://db", config.DatabaseURL)
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		loader := NewLoader(client.New(server.URL, client.WithToken("token")))
+		assert.ErrorIs(t, loader.Load(ctx, "frontend", "production", &appConfig{}), ErrProjectNotFound)
+	})
+
+	t.Run("use the cache when the server is unreachable", func(t *testing.T) {
+		dir := t.TempDir()
+		loader := NewLoader(client.New(server.URL, client.WithToken("token"), client.WithRetries(0, 0)), WithCache(dir, "cache-key"))
+
+		var config appConfig
+		assert.NoError(t, loader.Load(ctx, "backend", "production", &config))
+
+		fake.mu.Lock()
+		fake.failing = true
+		fake.mu.Unlock()
+		defer func() {
+			fake.mu.Lock()
+			fake.failing = false
+			fake.mu.Unlock()
+		}()
+
+		var cached appConfig
+		assert.NoError(t, loader.Load(ctx, "backend", "production", &cached))
+		assert.Equal(t, config, cached)
+
+		// The cache can't be read with another key.
+		other := NewLoader(client.New(server.URL, client.WithRetries(0, 0)), WithCache(dir, "other-key"))
+		assert.Error(t, other.Load(ctx, "backend", "production", &appConfig{}))
+
+		// Without a cache, the error is returned.
+		uncached := NewLoader(client.New(server.URL, client.WithRetries(0, 0)))
+		assert.ErrorIs(t, uncached.Load(ctx, "backend", "production", &appConfig{}), client.ErrServer)
+	})
+
+	t.Run("refused requests don't use the cache", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, NewLoader(client.New(server.URL, client.WithToken("token")), WithCache(dir, "cache-key")).Load(ctx, "backend", "production", &appConfig{}))
+
+		loader := NewLoader(client.New(server.URL, client.WithToken("expired")), WithCache(dir, "cache-key"))
+		assert.ErrorIs(t, loader.Load(ctx, "backend", "production", &appConfig{}), client.ErrUnauthorized)
+	})
+}
+
+func TestWatch(t *testing.T) {
+	fake, server := newFakeServer(t)
+	loader := NewLoader(client.New(server.URL, client.WithToken("token")))
+
+	var config appConfig
+	changes := make(chan *appConfig, 1)
+	var changedKeys []string
+	watcher, err := loader.Watch(context.Background(), "backend", "production", &config, func(updated interface{}, changed []string) {
+		changedKeys = changed
+		changes <- updated.(*appConfig)
+	})
+	assert.NoError(t, err)
+	defer watcher.Stop()
+	assert.Equal(t, "postgres://db", config.DatabaseURL)
+
+	fake.setValue("DATABASE_URL", "postgres://replica")
+
+	select {
+	case updated := <-changes:
+		assert.Equal(t, &appConfig{DatabaseURL: "postgres://replica", Workers: 4}, updated)
+		assert.Equal(t, []string{"DATABASE_URL"}, changedKeys)
+		assert.Equal(t, "postgres://db", config.DatabaseURL)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change wasn't received")
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	// AuditSuccess is the result of an audited request that succeeded.
+	AuditSuccess = "success"
+	// AuditFailure is the result of an audited request that failed.
+	AuditFailure = "failure"
+)
+
+// AuditEvent is an append-only record of a request, it never contains env values.
+// Every event stores the hash of the previous one so that any change to the log can be detected.
+type AuditEvent struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	ActorID   int       `json:"actor_id" gorm:"index"` // 0 for unauthenticated requests.
+	Action    string    `json:"action" gorm:"index"`   // e.g. "GET /api/v1/projects/{id}/env".
+	ProjectID int       `json:"project_id" gorm:"index"`
+	KeyName   string    `json:"key_name"`
+	SourceIP  string    `json:"source_ip"`
+	UserAgent string    `json:"user_agent"`
+	Result    string    `json:"result"`
+	Status    int       `json:"status"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash" gorm:"uniqueIndex"`
+}
+
+// AuditHead is the single row pointing to the last event of the audit chain, so that deleting the newest events is detected.
+type AuditHead struct {
+	ID        int    `json:"-" gorm:"primaryKey"`
+	EventID   int    `json:"event_id"`
+	Hash      string `json:"hash"`
+	Signature string `json:"signature"` // HMAC of the event ID and hash.
+}
@@ -13,6 +13,7 @@ type Project struct {
 	Team            []*User           `gorm:"many2many:project_team;default:nil"`
 	Owner           int               // Foreign key referencing User's ID field
 	Keys            []*EnvironmentKey `gorm:"default:nil"`
+	RequireMFA      bool              `json:"require_mfa"` // Members must enable MFA before reading production keys.
 }
 
 // Env keys model, containes all project keys.
@@ -22,4 +23,5 @@ type EnvironmentKey struct {
 	ProjectID int `json:"project_id" binding:"required"`
 	Key       string
 	Value     []byte
+	Version   int `json:"version" gorm:"default:1"` // Incremented on every update of the key.
 }
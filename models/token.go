@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// PasswordResetPurpose is the purpose of tokens sent to reset a password.
+	PasswordResetPurpose = "password_reset"
+	// EmailVerificationPurpose is the purpose of tokens sent to verify an email address.
+	EmailVerificationPurpose = "email_verification"
+)
+
+// ActionToken tracks a signed token sent to a user by email, so it can only be used once.
+type ActionToken struct {
+	gorm.Model
+	ID        int       `gorm:"primaryKey"`
+	JTI       string    `json:"-" gorm:"uniqueIndex"` // Unique identifier embedded in the signed token.
+	UserID    int       `json:"user_id" gorm:"index"`
+	Purpose   string    `json:"purpose"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"used"`
+}
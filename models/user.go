@@ -13,8 +13,25 @@ type User struct {
 	FirstName      string     `json:"first_name" binding:"required"`
 	LastName       string     `json:"last_name" binding:"required"`
 	Email          string     `json:"email" gorm:"unique" binding:"required"`
-	HashedPassword []byte     `json:"hashed_password" binding:"required"`
+	HashedPassword []byte     `json:"-" binding:"required"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 	IsOwner        bool       `json:"is_owner"`
 	Projects       []*Project `gorm:"many2many:user_projects;"`
+	MFAEnabled     bool       `json:"mfa_enabled"`
+	MFASecret      []byte     `json:"-"` // Encrypted TOTP secret, set on enrollment.
+	LastTOTPStep   int64      `json:"-"` // Time step of the last accepted TOTP code, older codes are rejected.
+	EmailVerified  bool       `json:"email_verified"`
+	IsAdmin        bool       `json:"is_admin"` // Site administrator, granted from the config or the CLI only.
+	Suspended      bool       `json:"suspended"`
+	FailedSignins  int        `json:"-"` // Consecutive failed signins, reset on success or unlock.
+	LockedUntil    *time.Time `json:"locked_until"`
+}
+
+// RecoveryCode holds a hashed single-use code that can replace a TOTP code at signin.
+type RecoveryCode struct {
+	gorm.Model
+	ID         int    `gorm:"primaryKey"`
+	UserID     int    `json:"user_id" gorm:"index"`
+	HashedCode []byte `json:"-"`
+	Used       bool   `json:"used"`
 }
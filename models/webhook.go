@@ -0,0 +1,63 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook events.
+const (
+	EnvCreatedEvent     = "env.created"
+	EnvUpdatedEvent     = "env.updated"
+	EnvDeletedEvent     = "env.deleted"
+	ProjectUpdatedEvent = "project.updated"
+	ProjectDeletedEvent = "project.deleted"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to.
+var WebhookEvents = []string{EnvCreatedEvent, EnvUpdatedEvent, EnvDeletedEvent, ProjectUpdatedEvent, ProjectDeletedEvent}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a project subscription notified when the subscribed events happen.
+type Webhook struct {
+	gorm.Model
+	ID        int    `gorm:"primaryKey"`
+	ProjectID int    `json:"project_id" gorm:"index"`
+	URL       string `json:"url"`
+	Events    string `json:"events"` // Comma separated list of the subscribed events.
+	Secret    []byte `json:"-"`      // Encrypted secret used to sign the payloads.
+}
+
+// Subscribed returns true if the webhook subscribed to the event.
+func (w Webhook) Subscribed(event string) bool {
+	for _, e := range strings.Split(w.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is a queued or done webhook call, kept as the delivery log.
+type WebhookDelivery struct {
+	ID             int        `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	WebhookID      int        `json:"webhook_id" gorm:"index"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status" gorm:"index"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
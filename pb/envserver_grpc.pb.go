@@ -0,0 +1,699 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: pb/envserver.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Envserver_Signup_FullMethodName         = "/envserver.v1.Envserver/Signup"
+	Envserver_Signin_FullMethodName         = "/envserver.v1.Envserver/Signin"
+	Envserver_SigninMFA_FullMethodName      = "/envserver.v1.Envserver/SigninMFA"
+	Envserver_GetCurrentUser_FullMethodName = "/envserver.v1.Envserver/GetCurrentUser"
+	Envserver_ListProjects_FullMethodName   = "/envserver.v1.Envserver/ListProjects"
+	Envserver_GetProject_FullMethodName     = "/envserver.v1.Envserver/GetProject"
+	Envserver_CreateProject_FullMethodName  = "/envserver.v1.Envserver/CreateProject"
+	Envserver_UpdateProject_FullMethodName  = "/envserver.v1.Envserver/UpdateProject"
+	Envserver_DeleteProject_FullMethodName  = "/envserver.v1.Envserver/DeleteProject"
+	Envserver_ListEnv_FullMethodName        = "/envserver.v1.Envserver/ListEnv"
+	Envserver_GetEnvKey_FullMethodName      = "/envserver.v1.Envserver/GetEnvKey"
+	Envserver_CreateEnvKey_FullMethodName   = "/envserver.v1.Envserver/CreateEnvKey"
+	Envserver_UpdateEnvKey_FullMethodName   = "/envserver.v1.Envserver/UpdateEnvKey"
+	Envserver_DeleteEnvKey_FullMethodName   = "/envserver.v1.Envserver/DeleteEnvKey"
+	Envserver_ApplyEnv_FullMethodName       = "/envserver.v1.Envserver/ApplyEnv"
+	Envserver_WatchEnv_FullMethodName       = "/envserver.v1.Envserver/WatchEnv"
+)
+
+// EnvserverClient is the client API for Envserver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EnvserverClient interface {
+	Signup(ctx context.Context, in *SignupRequest, opts ...grpc.CallOption) (*User, error)
+	// Signin returns an access token, or a two-factor authentication challenge completed with SigninMFA.
+	Signin(ctx context.Context, in *SigninRequest, opts ...grpc.CallOption) (*SigninResponse, error)
+	SigninMFA(ctx context.Context, in *SigninMFARequest, opts ...grpc.CallOption) (*SigninResponse, error)
+	GetCurrentUser(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*User, error)
+	ListProjects(ctx context.Context, in *ListProjectsRequest, opts ...grpc.CallOption) (*ListProjectsResponse, error)
+	GetProject(ctx context.Context, in *ProjectRequest, opts ...grpc.CallOption) (*Project, error)
+	CreateProject(ctx context.Context, in *CreateProjectRequest, opts ...grpc.CallOption) (*Project, error)
+	UpdateProject(ctx context.Context, in *Project, opts ...grpc.CallOption) (*Project, error)
+	DeleteProject(ctx context.Context, in *ProjectRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListEnv(ctx context.Context, in *ListEnvRequest, opts ...grpc.CallOption) (*ListEnvResponse, error)
+	GetEnvKey(ctx context.Context, in *EnvKeyRequest, opts ...grpc.CallOption) (*EnvKey, error)
+	CreateEnvKey(ctx context.Context, in *CreateEnvKeyRequest, opts ...grpc.CallOption) (*EnvKey, error)
+	UpdateEnvKey(ctx context.Context, in *UpdateEnvKeyRequest, opts ...grpc.CallOption) (*EnvKey, error)
+	DeleteEnvKey(ctx context.Context, in *EnvKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ApplyEnv applies the operations in a single transaction, either all of them are applied or none.
+	ApplyEnv(ctx context.Context, in *ApplyEnvRequest, opts ...grpc.CallOption) (*ApplyEnvResponse, error)
+	// WatchEnv streams the changes of the project env keys, resumed after last_event_id if it's set.
+	WatchEnv(ctx context.Context, in *WatchEnvRequest, opts ...grpc.CallOption) (Envserver_WatchEnvClient, error)
+}
+
+type envserverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEnvserverClient(cc grpc.ClientConnInterface) EnvserverClient {
+	return &envserverClient{cc}
+}
+
+func (c *envserverClient) Signup(ctx context.Context, in *SignupRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, Envserver_Signup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) Signin(ctx context.Context, in *SigninRequest, opts ...grpc.CallOption) (*SigninResponse, error) {
+	out := new(SigninResponse)
+	err := c.cc.Invoke(ctx, Envserver_Signin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) SigninMFA(ctx context.Context, in *SigninMFARequest, opts ...grpc.CallOption) (*SigninResponse, error) {
+	out := new(SigninResponse)
+	err := c.cc.Invoke(ctx, Envserver_SigninMFA_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) GetCurrentUser(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, Envserver_GetCurrentUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) ListProjects(ctx context.Context, in *ListProjectsRequest, opts ...grpc.CallOption) (*ListProjectsResponse, error) {
+	out := new(ListProjectsResponse)
+	err := c.cc.Invoke(ctx, Envserver_ListProjects_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) GetProject(ctx context.Context, in *ProjectRequest, opts ...grpc.CallOption) (*Project, error) {
+	out := new(Project)
+	err := c.cc.Invoke(ctx, Envserver_GetProject_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) CreateProject(ctx context.Context, in *CreateProjectRequest, opts ...grpc.CallOption) (*Project, error) {
+	out := new(Project)
+	err := c.cc.Invoke(ctx, Envserver_CreateProject_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) UpdateProject(ctx context.Context, in *Project, opts ...grpc.CallOption) (*Project, error) {
+	out := new(Project)
+	err := c.cc.Invoke(ctx, Envserver_UpdateProject_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) DeleteProject(ctx context.Context, in *ProjectRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Envserver_DeleteProject_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) ListEnv(ctx context.Context, in *ListEnvRequest, opts ...grpc.CallOption) (*ListEnvResponse, error) {
+	out := new(ListEnvResponse)
+	err := c.cc.Invoke(ctx, Envserver_ListEnv_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) GetEnvKey(ctx context.Context, in *EnvKeyRequest, opts ...grpc.CallOption) (*EnvKey, error) {
+	out := new(EnvKey)
+	err := c.cc.Invoke(ctx, Envserver_GetEnvKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) CreateEnvKey(ctx context.Context, in *CreateEnvKeyRequest, opts ...grpc.CallOption) (*EnvKey, error) {
+	out := new(EnvKey)
+	err := c.cc.Invoke(ctx, Envserver_CreateEnvKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) UpdateEnvKey(ctx context.Context, in *UpdateEnvKeyRequest, opts ...grpc.CallOption) (*EnvKey, error) {
+	out := new(EnvKey)
+	err := c.cc.Invoke(ctx, Envserver_UpdateEnvKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) DeleteEnvKey(ctx context.Context, in *EnvKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Envserver_DeleteEnvKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) ApplyEnv(ctx context.Context, in *ApplyEnvRequest, opts ...grpc.CallOption) (*ApplyEnvResponse, error) {
+	out := new(ApplyEnvResponse)
+	err := c.cc.Invoke(ctx, Envserver_ApplyEnv_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *envserverClient) WatchEnv(ctx context.Context, in *WatchEnvRequest, opts ...grpc.CallOption) (Envserver_WatchEnvClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Envserver_ServiceDesc.Streams[0], Envserver_WatchEnv_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &envserverWatchEnvClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Envserver_WatchEnvClient interface {
+	Recv() (*EnvChange, error)
+	grpc.ClientStream
+}
+
+type envserverWatchEnvClient struct {
+	grpc.ClientStream
+}
+
+func (x *envserverWatchEnvClient) Recv() (*EnvChange, error) {
+	m := new(EnvChange)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EnvserverServer is the server API for Envserver service.
+// All implementations must embed UnimplementedEnvserverServer
+// for forward compatibility
+type EnvserverServer interface {
+	Signup(context.Context, *SignupRequest) (*User, error)
+	// Signin returns an access token, or a two-factor authentication challenge completed with SigninMFA.
+	Signin(context.Context, *SigninRequest) (*SigninResponse, error)
+	SigninMFA(context.Context, *SigninMFARequest) (*SigninResponse, error)
+	GetCurrentUser(context.Context, *emptypb.Empty) (*User, error)
+	ListProjects(context.Context, *ListProjectsRequest) (*ListProjectsResponse, error)
+	GetProject(context.Context, *ProjectRequest) (*Project, error)
+	CreateProject(context.Context, *CreateProjectRequest) (*Project, error)
+	UpdateProject(context.Context, *Project) (*Project, error)
+	DeleteProject(context.Context, *ProjectRequest) (*emptypb.Empty, error)
+	ListEnv(context.Context, *ListEnvRequest) (*ListEnvResponse, error)
+	GetEnvKey(context.Context, *EnvKeyRequest) (*EnvKey, error)
+	CreateEnvKey(context.Context, *CreateEnvKeyRequest) (*EnvKey, error)
+	UpdateEnvKey(context.Context, *UpdateEnvKeyRequest) (*EnvKey, error)
+	DeleteEnvKey(context.Context, *EnvKeyRequest) (*emptypb.Empty, error)
+	// ApplyEnv applies the operations in a single transaction, either all of them are applied or none.
+	ApplyEnv(context.Context, *ApplyEnvRequest) (*ApplyEnvResponse, error)
+	// WatchEnv streams the changes of the project env keys, resumed after last_event_id if it's set.
+	WatchEnv(*WatchEnvRequest, Envserver_WatchEnvServer) error
+	mustEmbedUnimplementedEnvserverServer()
+}
+
+// UnimplementedEnvserverServer must be embedded to have forward compatible implementations.
+type UnimplementedEnvserverServer struct {
+}
+
+func (UnimplementedEnvserverServer) Signup(context.Context, *SignupRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Signup not implemented")
+}
+func (UnimplementedEnvserverServer) Signin(context.Context, *SigninRequest) (*SigninResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Signin not implemented")
+}
+func (UnimplementedEnvserverServer) SigninMFA(context.Context, *SigninMFARequest) (*SigninResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SigninMFA not implemented")
+}
+func (UnimplementedEnvserverServer) GetCurrentUser(context.Context, *emptypb.Empty) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCurrentUser not implemented")
+}
+func (UnimplementedEnvserverServer) ListProjects(context.Context, *ListProjectsRequest) (*ListProjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProjects not implemented")
+}
+func (UnimplementedEnvserverServer) GetProject(context.Context, *ProjectRequest) (*Project, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProject not implemented")
+}
+func (UnimplementedEnvserverServer) CreateProject(context.Context, *CreateProjectRequest) (*Project, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateProject not implemented")
+}
+func (UnimplementedEnvserverServer) UpdateProject(context.Context, *Project) (*Project, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateProject not implemented")
+}
+func (UnimplementedEnvserverServer) DeleteProject(context.Context, *ProjectRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProject not implemented")
+}
+func (UnimplementedEnvserverServer) ListEnv(context.Context, *ListEnvRequest) (*ListEnvResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEnv not implemented")
+}
+func (UnimplementedEnvserverServer) GetEnvKey(context.Context, *EnvKeyRequest) (*EnvKey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEnvKey not implemented")
+}
+func (UnimplementedEnvserverServer) CreateEnvKey(context.Context, *CreateEnvKeyRequest) (*EnvKey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEnvKey not implemented")
+}
+func (UnimplementedEnvserverServer) UpdateEnvKey(context.Context, *UpdateEnvKeyRequest) (*EnvKey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateEnvKey not implemented")
+}
+func (UnimplementedEnvserverServer) DeleteEnvKey(context.Context, *EnvKeyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteEnvKey not implemented")
+}
+func (UnimplementedEnvserverServer) ApplyEnv(context.Context, *ApplyEnvRequest) (*ApplyEnvResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyEnv not implemented")
+}
+func (UnimplementedEnvserverServer) WatchEnv(*WatchEnvRequest, Envserver_WatchEnvServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEnv not implemented")
+}
+func (UnimplementedEnvserverServer) mustEmbedUnimplementedEnvserverServer() {}
+
+// UnsafeEnvserverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EnvserverServer will
+// result in compilation errors.
+type UnsafeEnvserverServer interface {
+	mustEmbedUnimplementedEnvserverServer()
+}
+
+func RegisterEnvserverServer(s grpc.ServiceRegistrar, srv EnvserverServer) {
+	s.RegisterService(&Envserver_ServiceDesc, srv)
+}
+
+func _Envserver_Signup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).Signup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_Signup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).Signup(ctx, req.(*SignupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_Signin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SigninRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).Signin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_Signin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).Signin(ctx, req.(*SigninRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_SigninMFA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SigninMFARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).SigninMFA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_SigninMFA_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).SigninMFA(ctx, req.(*SigninMFARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_GetCurrentUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).GetCurrentUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_GetCurrentUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).GetCurrentUser(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_ListProjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).ListProjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_ListProjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).ListProjects(ctx, req.(*ListProjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_GetProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).GetProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_GetProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).GetProject(ctx, req.(*ProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_CreateProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).CreateProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_CreateProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).CreateProject(ctx, req.(*CreateProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_UpdateProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Project)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).UpdateProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_UpdateProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).UpdateProject(ctx, req.(*Project))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_DeleteProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).DeleteProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_DeleteProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).DeleteProject(ctx, req.(*ProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_ListEnv_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEnvRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).ListEnv(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_ListEnv_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).ListEnv(ctx, req.(*ListEnvRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_GetEnvKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnvKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).GetEnvKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_GetEnvKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).GetEnvKey(ctx, req.(*EnvKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_CreateEnvKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateEnvKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).CreateEnvKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_CreateEnvKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).CreateEnvKey(ctx, req.(*CreateEnvKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_UpdateEnvKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateEnvKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).UpdateEnvKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_UpdateEnvKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).UpdateEnvKey(ctx, req.(*UpdateEnvKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_DeleteEnvKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnvKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).DeleteEnvKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_DeleteEnvKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).DeleteEnvKey(ctx, req.(*EnvKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_ApplyEnv_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyEnvRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvserverServer).ApplyEnv(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envserver_ApplyEnv_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvserverServer).ApplyEnv(ctx, req.(*ApplyEnvRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envserver_WatchEnv_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEnvRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EnvserverServer).WatchEnv(m, &envserverWatchEnvServer{stream})
+}
+
+type Envserver_WatchEnvServer interface {
+	Send(*EnvChange) error
+	grpc.ServerStream
+}
+
+type envserverWatchEnvServer struct {
+	grpc.ServerStream
+}
+
+func (x *envserverWatchEnvServer) Send(m *EnvChange) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Envserver_ServiceDesc is the grpc.ServiceDesc for Envserver service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Envserver_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "envserver.v1.Envserver",
+	HandlerType: (*EnvserverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Signup",
+			Handler:    _Envserver_Signup_Handler,
+		},
+		{
+			MethodName: "Signin",
+			Handler:    _Envserver_Signin_Handler,
+		},
+		{
+			MethodName: "SigninMFA",
+			Handler:    _Envserver_SigninMFA_Handler,
+		},
+		{
+			MethodName: "GetCurrentUser",
+			Handler:    _Envserver_GetCurrentUser_Handler,
+		},
+		{
+			MethodName: "ListProjects",
+			Handler:    _Envserver_ListProjects_Handler,
+		},
+		{
+			MethodName: "GetProject",
+			Handler:    _Envserver_GetProject_Handler,
+		},
+		{
+			MethodName: "CreateProject",
+			Handler:    _Envserver_CreateProject_Handler,
+		},
+		{
+			MethodName: "UpdateProject",
+			Handler:    _Envserver_UpdateProject_Handler,
+		},
+		{
+			MethodName: "DeleteProject",
+			Handler:    _Envserver_DeleteProject_Handler,
+		},
+		{
+			MethodName: "ListEnv",
+			Handler:    _Envserver_ListEnv_Handler,
+		},
+		{
+			MethodName: "GetEnvKey",
+			Handler:    _Envserver_GetEnvKey_Handler,
+		},
+		{
+			MethodName: "CreateEnvKey",
+			Handler:    _Envserver_CreateEnvKey_Handler,
+		},
+		{
+			MethodName: "UpdateEnvKey",
+			Handler:    _Envserver_UpdateEnvKey_Handler,
+		},
+		{
+			MethodName: "DeleteEnvKey",
+			Handler:    _Envserver_DeleteEnvKey_Handler,
+		},
+		{
+			MethodName: "ApplyEnv",
+			Handler:    _Envserver_ApplyEnv_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEnv",
+			Handler:       _Envserver_WatchEnv_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pb/envserver.proto",
+}
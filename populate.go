@@ -0,0 +1,149 @@
+package envserver
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// envTag holds the key name of a field, optionally followed by ",required".
+	envTag = "env"
+	// defaultTag holds the value of a field whose key is missing.
+	defaultTag = "default"
+)
+
+// ErrInvalidConfig is returned when the config isn't a pointer to a struct.
+var ErrInvalidConfig = errors.New("envserver: config must be a non-nil pointer to a struct")
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Populate sets the fields of the config struct tagged with `env:"NAME"` from the values.
+// A field whose key is missing is set from its `default:"..."` tag, or left unchanged, unless it's tagged `env:"NAME,required"`.
+// Supported field types are strings, bools, integers, floats, time.Duration, encoding.TextUnmarshaler,
+// and slices of them as comma-separated values. Untagged struct fields are populated recursively.
+func Populate(config interface{}, values map[string]string) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidConfig
+	}
+	return populateStruct(v.Elem(), values)
+}
+
+func populateStruct(v reflect.Value, values map[string]string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag, tagged := field.Tag.Lookup(envTag)
+		if !tagged {
+			if field.Type.Kind() == reflect.Struct && !isScalar(field.Type) {
+				if err := populateStruct(v.Field(i), values); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		value, ok := values[name]
+		if !ok {
+			if options == "required" {
+				return fmt.Errorf("envserver: the required key %s is missing", name)
+			}
+			value, ok = field.Tag.Lookup(defaultTag)
+			if !ok {
+				continue
+			}
+		}
+
+		if err := setValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("envserver: cannot set %s from the key %s: %w", field.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// isScalar reports whether the type is set from a single value rather than populated field by field.
+func isScalar(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func setValue(v reflect.Value, value string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	if v.Type() == durationType {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(duration))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(value))
+			return nil
+		}
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Pointer:
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(ptr)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
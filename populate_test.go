@@ -0,0 +1,80 @@
+package envserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type databaseConfig struct {
+	URL      string `env:"DATABASE_URL,required"`
+	MaxConns int    `env:"DATABASE_MAX_CONNS" default:"10"`
+}
+
+type serviceConfig struct {
+	Database databaseConfig
+	Debug    bool          `env:"DEBUG"`
+	Ratio    float64       `env:"RATIO"`
+	Port     uint16        `env:"PORT" default:"8080"`
+	Timeout  time.Duration `env:"TIMEOUT" default:"30s"`
+	Hosts    []string      `env:"HOSTS"`
+	Ports    []int         `env:"PORTS"`
+	IP       net.IP        `env:"IP"`
+	Secret   []byte        `env:"SECRET"`
+	Limit    *int          `env:"LIMIT"`
+	Name     string        `env:"NAME"`
+	ignored  string
+}
+
+func TestPopulate(t *testing.T) {
+	t.Run("set the fields from the values and defaults", func(t *testing.T) {
+		config := serviceConfig{Name: "unchanged"}
+		err := Populate(&config, map[string]string{
+			"DATABASE_URL": "postgres://localhost",
+			"DEBUG":        "true",
+			"RATIO":        "0.5",
+			"TIMEOUT":      "1m",
+			"HOSTS":        "a.example.com, b.example.com",
+			"PORTS":        "80,443",
+			"IP":           "10.0.0.1",
+			"SECRET":       "s3cr3t",
+			"LIMIT":        "3",
+		})
+		assert.NoError(t, err)
+
+		limit := 3
+		assert.Equal(t, serviceConfig{
+			Database: databaseConfig{URL: "postgres://localhost", MaxConns: 10},
+			Debug:    true,
+			Ratio:    0.5,
+			Port:     8080,
+			Timeout:  time.Minute,
+			Hosts:    []string{"a.example.com", "b.example.com"},
+			Ports:    []int{80, 443},
+			IP:       net.ParseIP("10.0.0.1"),
+			Secret:   []byte("s3cr3t"),
+			Limit:    &limit,
+			Name:     "unchanged",
+		}, config)
+	})
+
+	t.Run("missing required key", func(t *testing.T) {
+		var config serviceConfig
+		err := Populate(&config, map[string]string{})
+		assert.ErrorContains(t, err, "DATABASE_URL")
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		var config serviceConfig
+		err := Populate(&config, map[string]string{"DATABASE_URL": "postgres://localhost", "PORT": "http"})
+		assert.ErrorContains(t, err, "Port")
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		var config serviceConfig
+		assert.ErrorIs(t, Populate(config, nil), ErrInvalidConfig)
+		assert.ErrorIs(t, Populate((*serviceConfig)(nil), nil), ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,130 @@
+package envserver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/Mahmoud-Emad/envserver/client"
+)
+
+const (
+	// watchMinBackoff is the delay before reconnecting to the env stream after a failure.
+	watchMinBackoff = time.Second
+	// watchMaxBackoff caps the delay between two reconnections.
+	watchMaxBackoff = time.Minute
+)
+
+// ChangeFunc is called with a newly populated config, of the same type as the watched one, and the names of the changed keys.
+type ChangeFunc func(config interface{}, changed []string)
+
+// Watcher refreshes the keys of a project environment when they change, until it's stopped.
+type Watcher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Watch loads the keys of the project environment into the config struct like Load, then watches the env stream of the project.
+// Whenever keys change, a new config of the same type is populated and passed to onChange, the initial config is never modified.
+// The watcher reconnects after failures and reloads the keys on every reconnection.
+func (l *Loader) Watch(ctx context.Context, project, env string, config interface{}, onChange ChangeFunc) (*Watcher, error) {
+	current, err := l.fetch(ctx, project, env)
+	if err != nil {
+		return nil, err
+	}
+	if err := Populate(config, current.Values); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Watcher{cancel: cancel, done: make(chan struct{})}
+	configType := reflect.TypeOf(config).Elem()
+
+	reload := func() {
+		next, err := l.fetch(ctx, project, env)
+		if err != nil {
+			return
+		}
+		changed := changedKeys(current.Values, next.Values)
+		if len(changed) == 0 {
+			return
+		}
+
+		config := reflect.New(configType).Interface()
+		if err := Populate(config, next.Values); err != nil {
+			return
+		}
+		current = next
+		onChange(config, changed)
+	}
+
+	go func() {
+		defer close(w.done)
+
+		lastEventID := ""
+		backoff := watchMinBackoff
+		for {
+			if current.ProjectID == 0 {
+				// The keys were loaded from an old cache, find the project once the server is reachable.
+				reload()
+			}
+
+			err := l.client.StreamEnv(ctx, current.ProjectID, lastEventID, func(event client.EnvEvent) error {
+				backoff = watchMinBackoff
+				if event.ID != "" {
+					lastEventID = event.ID
+				}
+				reload()
+				return nil
+			})
+			if ctx.Err() != nil {
+				return
+			}
+
+			var apiErr *client.Error
+			if errors.As(err, &apiErr) && !isUnreachable(err) {
+				// The stream is refused, e.g. the token expired, keep polling slowly in case it's fixed.
+				backoff = watchMaxBackoff
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > watchMaxBackoff {
+				backoff = watchMaxBackoff
+			}
+
+			// Changes may have been missed while disconnected.
+			reload()
+		}
+	}()
+	return w, nil
+}
+
+// Stop stops watching and waits for the watcher to exit.
+func (w *Watcher) Stop() {
+	w.cancel()
+	<-w.done
+}
+
+// changedKeys returns the sorted names of the keys added, updated or removed between the values.
+func changedKeys(previous, next map[string]string) []string {
+	var changed []string
+	for name, value := range next {
+		if old, ok := previous[name]; !ok || old != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := next[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}